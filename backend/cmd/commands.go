@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	application_iot "iiot_system/backend/internal/application/iot"
+	"iiot_system/backend/internal/infrastructure/configs"
+	"iiot_system/backend/internal/infrastructure/kafka"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	bobpgx "github.com/stephenafamo/bob/drivers/pgx"
+)
+
+const usage = `usage:
+  backend                          run the ingestion service
+  backend dlq replay <topic>       replay <topic>.dlq back to <topic>
+  backend db retention apply       apply the configured retention policies
+  backend config print [file]      print the effective configuration, secrets redacted
+  backend config validate [file]   validate the configuration
+
+The configuration is read from the YAML or TOML file named by [file] or
+CONFIG_FILE, then overridden by environment variables.`
+
+// runCommand runs the maintenance command named by args instead of the service.
+func runCommand(ctx context.Context, args []string) error {
+	switch {
+	case len(args) == 3 && args[0] == "dlq" && args[1] == "replay":
+		cfg, err := configs.Load()
+		if err != nil {
+			return fmt.Errorf("invalid configuration:\n%w", err)
+		}
+		if err := setupLogging(cfg.Logging); err != nil {
+			return err
+		}
+
+		topic := args[2]
+		replayed, err := kafka.ReplayDeadLetters(ctx, cfg, topic)
+		slog.Info("replayed dead-lettered records",
+			"records", replayed, "from", kafka.DeadLetterTopic(topic), "to", topic)
+		return err
+	case len(args) == 3 && args[0] == "db" && args[1] == "retention" && args[2] == "apply":
+		cfg, err := configs.Load()
+		if err != nil {
+			return fmt.Errorf("invalid configuration:\n%w", err)
+		}
+		if err := setupLogging(cfg.Logging); err != nil {
+			return err
+		}
+		return applyRetention(ctx, cfg)
+	case len(args) >= 2 && len(args) <= 3 && args[0] == "config":
+		path := os.Getenv(configs.ConfigFileEnv)
+		if len(args) == 3 {
+			path = args[2]
+		}
+		return runConfigCommand(args[1], path)
+	default:
+		return fmt.Errorf("unknown command %v\n%s", args, usage)
+	}
+}
+
+// runConfigCommand prints or validates the configuration. The effective
+// configuration is printed even if invalid, followed by its errors.
+func runConfigCommand(command, path string) error {
+	if command != "print" && command != "validate" {
+		return fmt.Errorf("unknown config command %q\n%s", command, usage)
+	}
+
+	cfg, err := configs.Read(path)
+	if err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	if command == "print" {
+		if err := cfg.Print(os.Stdout); err != nil {
+			return err
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	if command == "validate" {
+		fmt.Println("configuration is valid")
+	}
+	return nil
+}
+
+// applyRetention replaces the retention policies of the event tables by the
+// configured ones. The service never changes them itself, so replicas
+// starting together do not race on the schema.
+func applyRetention(ctx context.Context, cfg *configs.Config) error {
+	poolConfig, err := cfg.Database.PoolConfig()
+	if err != nil {
+		return fmt.Errorf("invalid database configuration: %w", err)
+	}
+	dbpool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return fmt.Errorf("unable to create connection pool: %w", err)
+	}
+	defer dbpool.Close()
+
+	policies := application_iot.EventRetentionPolicies(
+		cfg.Retention.Telemetry,
+		cfg.Retention.Alerts,
+		cfg.Retention.Production,
+		cfg.Retention.StatusUpdates,
+	)
+	changed, err := application_iot.ApplyRetentionPolicies(ctx, bobpgx.NewPool(dbpool), policies...)
+	slog.Info("applied retention policies", "changed", changed)
+	return err
+}
@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -13,121 +15,312 @@ import (
 
 	application_iot "iiot_system/backend/internal/application/iot"
 	"iiot_system/backend/internal/infrastructure/configs"
+	"iiot_system/backend/internal/infrastructure/kafka"
+	"iiot_system/backend/internal/infrastructure/logging"
+	"iiot_system/backend/internal/infrastructure/repositories"
 	"iiot_system/backend/internal/infrastructure/topics"
+	"iiot_system/backend/internal/infrastructure/tracing"
+	"iiot_system/backend/internal/presentation/presentation_api"
+	"iiot_system/backend/internal/presentation/presentation_health"
 	"iiot_system/backend/internal/presentation/presentation_iot"
+	"iiot_system/backend/internal/presentation/presentation_metrics"
 
 	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/labstack/echo/v4"
-	"github.com/stephenafamo/bob"
+	bobpgx "github.com/stephenafamo/bob/drivers/pgx"
 	"github.com/twmb/franz-go/pkg/kgo"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
 )
 
 func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
+	if len(os.Args) > 1 {
+		if err := runCommand(ctx, os.Args[1:]); err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+
 	cfg := configs.LoadConfig()
+	if err := setupLogging(cfg.Logging); err != nil {
+		log.Fatalln(err)
+	}
+	if err := run(ctx, cfg); err != nil {
+		slog.Error("application stopped with error", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("application shut down gracefully")
+}
+
+// setupLogging makes the logger described by cfg the default one, used by
+// every package.
+func setupLogging(cfg configs.LoggingConfig) error {
+	logger, err := logging.New(cfg, os.Stderr)
+	if err != nil {
+		return fmt.Errorf("unable to set up logging: %w", err)
+	}
+	slog.SetDefault(logger)
+	return nil
+}
+
+// run serves until ctx is done, then shuts down in order: the HTTP server
+// stops accepting requests, the consumers stop polling and store their
+// buffered batches, and the Kafka clients and database pool are closed.
+func run(ctx context.Context, cfg *configs.Config) error {
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing)
+	if err != nil {
+		return fmt.Errorf("unable to set up tracing: %w", err)
+	}
+	// Spans buffered at exit are flushed once everything else stopped.
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("unable to flush traces", "error", err)
+		}
+	}()
+
+	poolConfig, err := cfg.Database.PoolConfig()
+	if err != nil {
+		return fmt.Errorf("invalid database configuration: %w", err)
+	}
+	dbpool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return fmt.Errorf("unable to create connection pool: %w", err)
+	}
+	defer dbpool.Close()
+	db := bobpgx.NewPool(dbpool)
+
+	telemetryWriter, err := application_iot.ParseTelemetryWriter(cfg.Ingestion.TelemetryWriter)
+	if err != nil {
+		return fmt.Errorf("invalid telemetry writer: %w", err)
+	}
+
+	// In database offset storage mode, consumed offsets are written in the
+	// same transaction as the events and partitions resume from them.
+	offsetStore := application_iot.NewOffsetStore(db)
+	storeOffsets := cfg.Kafka.OffsetStorage == configs.OffsetStorageDatabase
 
-	var db bob.DB
-	{
-		dbpool, err := pgxpool.New(ctx, cfg.DatabaseURL)
+	// Each pipeline owns its own client and consumer group, so every record is
+	// polled by exactly one consumer.
+	// The topics of each pipeline come from the configuration; startup fails if
+	// one of them does not exist.
+	// The partitions assigned to each pipeline are tracked for readiness.
+	assignments := make(map[string]*kafka.Assignment, len(topics.Pipelines))
+	newConsumerClient := func(pipeline string) (*kgo.Client, error) {
+		var opts []kgo.Opt
+		if storeOffsets {
+			opts = append(opts, kafka.ResumeFromStoredOffsets(kafka.ConsumerGroupID(cfg.Kafka.GroupID, pipeline), offsetStore))
+		}
+		assignments[pipeline] = kafka.NewAssignment()
+
+		subs := cfg.Kafka.Topics[pipeline]
+		client, err := kafka.NewConsumerClient(cfg, pipeline, subs, assignments[pipeline], opts...)
 		if err != nil {
-			log.Printf("Unable to create connection pool: %v\n", err)
-			os.Exit(1)
+			return nil, fmt.Errorf("unable to create kafka client: %w", err)
 		}
-		defer dbpool.Close()
-		db = bob.NewDB(stdlib.OpenDBFromPool(dbpool))
-	}
-	defer db.Close()
-
-	kafka, err := kgo.NewClient(
-		kgo.SeedBrokers(cfg.KafkaBroker),
-		kgo.ConsumerGroup(cfg.KafkaGroupID),
-		kgo.ConsumeTopics(topics.AlertsTopic, topics.ProductionTopic, topics.StatusUpdateTopic, topics.TelemetryTopic),
-		kgo.AutoCommitMarks(),
-		kgo.AutoCommitInterval(5*time.Second),
-		kgo.OnPartitionsRevoked(func(ctx context.Context, c *kgo.Client, m map[string][]int32) {
-			if err := c.CommitMarkedOffsets(ctx); err != nil {
-				fmt.Printf("Failed to commit offsets: %v\n", err)
-			}
-		}),
-		kgo.BlockRebalanceOnPoll(),
-	)
+		if err := client.Ping(ctx); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("unable to ping kafka: %w", err)
+		}
+		if err := kafka.CheckSubscriptions(ctx, client, subs); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("invalid topics for %s pipeline: %w", pipeline, err)
+		}
+		return client, nil
+	}
+
+	alertsClient, err := newConsumerClient(topics.AlertsPipeline)
+	if err != nil {
+		return err
+	}
+	defer alertsClient.Close()
+	productionClient, err := newConsumerClient(topics.ProductionPipeline)
+	if err != nil {
+		return err
+	}
+	defer productionClient.Close()
+	statusUpdateClient, err := newConsumerClient(topics.StatusUpdatePipeline)
 	if err != nil {
-		log.Fatalf("Unable to create kafka client: %v\n", err)
+		return err
 	}
-	defer kafka.Close()
+	defer statusUpdateClient.Close()
+	telemetryClient, err := newConsumerClient(topics.TelemetryPipeline)
+	if err != nil {
+		return err
+	}
+	defer telemetryClient.Close()
 
-	if err := kafka.Ping(ctx); err != nil {
-		log.Fatalf("unable to ping kafka: %v\n", err)
+	var deadLetterProducer *kafka.DeadLetterProducer
+	if cfg.Features.DeadLetterQueue {
+		producerClient, err := kafka.NewProducerClient(cfg)
+		if err != nil {
+			return fmt.Errorf("unable to create kafka producer client: %w", err)
+		}
+		defer producerClient.Close()
+		deadLetterProducer = kafka.NewDeadLetterProducer(producerClient)
+	}
+	// Bounds the batches written concurrently by all pipelines.
+	flushLimiter := presentation_iot.NewFlushLimiter(cfg.Ingestion.Batch.MaxInFlight)
+	batching := presentation_iot.BatchOptions{
+		MaxRows:     cfg.Ingestion.Batch.MaxRows,
+		MaxWait:     cfg.Ingestion.Batch.MaxWait,
+		MaxBuffered: cfg.Ingestion.Batch.MaxBuffered,
 	}
+	metrics := presentation_metrics.New(dbpool)
 
-	insertAlertsHandler := application_iot.NewInsertAlertsCommandHandler(db)
-	insertProductionHandler := application_iot.NewInsertProductionCommandHandler(db)
-	insertStatusUpdateHandler := application_iot.NewInsertStatusUpdateCommandHandler(db)
-	insertTelemetryHandler := application_iot.NewInsertTelemetryCommandHandler(db)
+	timestamps := cfg.Ingestion.Timestamps
+	timestampUnit, err := presentation_iot.ParseTimeUnit(timestamps.Unit)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp unit: %w", err)
+	}
+	timestampPolicy := presentation_iot.TimestampPolicy{
+		Unit:                 timestampUnit,
+		FallbackToRecordTime: timestamps.FallbackToRecord,
+		MaxFuture:            timestamps.MaxFuture,
+		MaxPast:              timestamps.MaxPast,
+	}
 
-	iiotAlertsConsumer, err := presentation_iot.NewIiotAlertConsumer(insertAlertsHandler, kafka)
+	consumerOptions := func(pipeline string) []presentation_iot.ConsumerOption {
+		opts := []presentation_iot.ConsumerOption{
+			presentation_iot.WithStrictPayloads(cfg.Ingestion.StrictPayloads),
+			presentation_iot.WithTimestampPolicy(timestampPolicy),
+			presentation_iot.WithRejectSpoofedDevices(cfg.Ingestion.RejectSpoofedDevices),
+			presentation_iot.WithMaxPollRecords(cfg.Kafka.MaxPollRecords),
+			presentation_iot.WithBatching(batching),
+			presentation_iot.WithFlushLimiter(flushLimiter),
+			presentation_iot.WithDrainTimeout(cfg.Ingestion.Batch.DrainTimeout),
+			presentation_iot.WithAssignment(assignments[pipeline]),
+			presentation_iot.WithMetrics(metrics),
+		}
+		if deadLetterProducer != nil {
+			opts = append(opts, presentation_iot.WithDeadLetter(deadLetterProducer))
+		}
+		if storeOffsets {
+			opts = append(opts, presentation_iot.WithStoredOffsets(kafka.ConsumerGroupID(cfg.Kafka.GroupID, pipeline)))
+		}
+		return opts
+	}
+
+	insertObserver := application_iot.WithInsertObserver(metrics)
+	insertAlertsHandler := application_iot.NewInsertAlertsCommandHandler(db, insertObserver)
+	insertProductionHandler := application_iot.NewInsertProductionCommandHandler(db, insertObserver)
+	insertStatusUpdateHandler := application_iot.NewInsertStatusUpdateCommandHandler(db, insertObserver)
+	insertTelemetryHandler := application_iot.NewInsertTelemetryCommandHandler(db, telemetryWriter, insertObserver)
+
+	iiotAlertsConsumer, err := presentation_iot.NewIiotAlertConsumer(insertAlertsHandler, alertsClient, consumerOptions(topics.AlertsPipeline)...)
 	if err != nil {
-		log.Fatalf("Unable to create IIoT alerts consumer: %v\n", err)
+		return fmt.Errorf("unable to create IIoT alerts consumer: %w", err)
 	}
 
-	iiotProductionConsumer, err := presentation_iot.NewIiotProductionConsumer(insertProductionHandler, kafka)
+	iiotProductionConsumer, err := presentation_iot.NewIiotProductionConsumer(insertProductionHandler, productionClient, consumerOptions(topics.ProductionPipeline)...)
 	if err != nil {
-		log.Fatalf("Unable to create IIoT production consumer: %v\n", err)
+		return fmt.Errorf("unable to create IIoT production consumer: %w", err)
 	}
 
-	iiotStatusUpdateConsumer, err := presentation_iot.NewIiotStatusUpdateConsumer(insertStatusUpdateHandler, kafka)
+	iiotStatusUpdateConsumer, err := presentation_iot.NewIiotStatusUpdateConsumer(insertStatusUpdateHandler, statusUpdateClient, consumerOptions(topics.StatusUpdatePipeline)...)
 	if err != nil {
-		log.Fatalf("Unable to create IIoT status update consumer: %v\n", err)
+		return fmt.Errorf("unable to create IIoT status update consumer: %w", err)
 	}
 
-	iiotTelemetryConsumer, err := presentation_iot.NewIiotTelemetryConsumer(insertTelemetryHandler, kafka)
+	iiotTelemetryConsumer, err := presentation_iot.NewIiotTelemetryConsumer(insertTelemetryHandler, telemetryClient, consumerOptions(topics.TelemetryPipeline)...)
 	if err != nil {
-		log.Printf("Unable to create IIoT telemetry consumer: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("unable to create IIoT telemetry consumer: %w", err)
 	}
 
+	metrics.TrackLag(
+		iiotAlertsConsumer,
+		iiotProductionConsumer,
+		iiotStatusUpdateConsumer,
+		iiotTelemetryConsumer,
+	)
+
 	e := echo.New()
+	e.Server.ReadTimeout = cfg.HTTP.ReadTimeout
+	e.Server.WriteTimeout = cfg.HTTP.WriteTimeout
+	metrics.Register(e)
+	if cfg.Tracing.Enabled {
+		e.Use(otelecho.Middleware(cfg.Tracing.ServiceName))
+	}
 	e.GET("/", func(c echo.Context) error {
 		return c.String(http.StatusOK, "Hello, World!")
 	})
+	health := presentation_health.NewHandler(
+		map[string]presentation_health.Check{"database": dbpool.Ping},
+		iiotAlertsConsumer,
+		iiotProductionConsumer,
+		iiotStatusUpdateConsumer,
+		iiotTelemetryConsumer,
+	)
+	health.Register(e)
 
-	var wg sync.WaitGroup
+	api := e.Group("/api/v1")
+	presentation_api.NewTelemetryHandler(
+		application_iot.NewTelemetryQueryHandler(db),
+		application_iot.NewTelemetryAggregateQueryHandler(db),
+	).Register(api)
+	presentation_api.NewAlertsHandler(
+		application_iot.NewAlertsQueryHandler(repositories.NewIotAlertRepository(db)),
+	).Register(api)
+	presentation_api.NewProductionHandler(
+		application_iot.NewProductionEventsQueryHandler(db),
+		application_iot.NewProductionBatchQueryHandler(db),
+		application_iot.NewProductionRollupQueryHandler(db, application_iot.Shifts{
+			Start:  cfg.Production.ShiftStart,
+			Length: cfg.Production.ShiftLength,
+		}),
+	).Register(api)
+	presentation_api.NewStatusHandler(
+		application_iot.NewStatusTimelineQueryHandler(db, cfg.Status.Lookback),
+		application_iot.NewStatusSummaryQueryHandler(db, cfg.Status.Lookback),
+	).Register(api)
 
-	wg.Go(func() {
-		e.Logger.Fatal(e.Start(fmt.Sprintf(":%d", cfg.Port)))
-	})
+	var wg sync.WaitGroup
 
 	wg.Go(func() {
-		err := iiotAlertsConsumer.Start(ctx)
-		if err != nil {
-			log.Fatal("Iiot alert consumer stopped with error", err)
-		}
-	})
-	wg.Go(func() {
-		err := iiotProductionConsumer.Start(ctx)
-		if err != nil {
-			log.Fatal("Iiot production consumer stopped with error", err)
-		}
-	})
-	wg.Go(func() {
-		err := iiotStatusUpdateConsumer.Start(ctx)
-		if err != nil {
-			log.Fatal("Iiot status update consumer stopped with error", err)
-		}
-	})
-	wg.Go(func() {
-		err := iiotTelemetryConsumer.Start(ctx)
-		if err != nil {
-			log.Fatal("Iiot telemetry consumer stopped with error", err)
+		err := e.Start(fmt.Sprintf(":%d", cfg.HTTP.Port))
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("HTTP server stopped with error", "error", err)
 		}
 	})
 
-	log.Println("Application is running. Press Ctrl+C to stop.")
+	// Consumers drain their buffered batches once ctx is done; the first one
+	// failing also stops the others.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	consumerErrs := make(chan error, 4)
+	startConsumer := func(name string, consumer interface{ Start(context.Context) error }) {
+		wg.Go(func() {
+			if err := consumer.Start(ctx); err != nil {
+				consumerErrs <- fmt.Errorf("IIoT %s consumer stopped with error: %w", name, err)
+				cancel()
+			}
+		})
+	}
+	startConsumer("alert", iiotAlertsConsumer)
+	startConsumer("production", iiotProductionConsumer)
+	startConsumer("status update", iiotStatusUpdateConsumer)
+	startConsumer("telemetry", iiotTelemetryConsumer)
+
+	slog.Info("application is running, press Ctrl+C to stop", "port", cfg.HTTP.Port)
 	<-ctx.Done()
-	log.Println("Waiting for consumers to finish...")
+
+	slog.Info("shutting down HTTP server")
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
+	defer cancelShutdown()
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		slog.Error("unable to shut down HTTP server", "error", err)
+	}
+
+	slog.Info("waiting for consumers to finish")
 	wg.Wait()
-	log.Println("Application shutting down gracefully.")
+	close(consumerErrs)
+
+	var errs []error
+	for err := range consumerErrs {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
 }
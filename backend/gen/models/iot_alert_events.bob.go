@@ -16,12 +16,17 @@ import (
 
 // IotAlertEvent is an object representing the database table.
 type IotAlertEvent struct {
-	Time         time.Time                 `db:"time" `
-	DeviceID     string                    `db:"device_id" `
-	AlertType    string                    `db:"alert_type" `
-	Severity     string                    `db:"severity" `
-	Message      string                    `db:"message" `
-	CurrentValue null.Val[decimal.Decimal] `db:"current_value" `
+	Time             time.Time                 `db:"time" `
+	DeviceID         string                    `db:"device_id" `
+	AlertType        string                    `db:"alert_type" `
+	Severity         string                    `db:"severity" `
+	Message          string                    `db:"message" `
+	CurrentValue     null.Val[decimal.Decimal] `db:"current_value" `
+	MqttTopic        null.Val[string]          `db:"mqtt_topic" `
+	MqttClientID     null.Val[string]          `db:"mqtt_client_id" `
+	BrokerReceivedAt null.Val[time.Time]       `db:"broker_received_at" `
+	KafkaPartition   null.Val[int32]           `db:"kafka_partition" `
+	KafkaOffset      null.Val[int64]           `db:"kafka_offset" `
 }
 
 // IotAlertEventSlice is an alias for a slice of pointers to IotAlertEvent.
@@ -37,27 +42,37 @@ type IotAlertEventsQuery = *psql.ViewQuery[*IotAlertEvent, IotAlertEventSlice]
 func buildIotAlertEventColumns(alias string) iotAlertEventColumns {
 	return iotAlertEventColumns{
 		ColumnsExpr: expr.NewColumnsExpr(
-			"time", "device_id", "alert_type", "severity", "message", "current_value",
+			"time", "device_id", "alert_type", "severity", "message", "current_value", "mqtt_topic", "mqtt_client_id", "broker_received_at", "kafka_partition", "kafka_offset",
 		).WithParent("iot_alert_events"),
-		tableAlias:   alias,
-		Time:         psql.Quote(alias, "time"),
-		DeviceID:     psql.Quote(alias, "device_id"),
-		AlertType:    psql.Quote(alias, "alert_type"),
-		Severity:     psql.Quote(alias, "severity"),
-		Message:      psql.Quote(alias, "message"),
-		CurrentValue: psql.Quote(alias, "current_value"),
+		tableAlias:       alias,
+		Time:             psql.Quote(alias, "time"),
+		DeviceID:         psql.Quote(alias, "device_id"),
+		AlertType:        psql.Quote(alias, "alert_type"),
+		Severity:         psql.Quote(alias, "severity"),
+		Message:          psql.Quote(alias, "message"),
+		CurrentValue:     psql.Quote(alias, "current_value"),
+		MqttTopic:        psql.Quote(alias, "mqtt_topic"),
+		MqttClientID:     psql.Quote(alias, "mqtt_client_id"),
+		BrokerReceivedAt: psql.Quote(alias, "broker_received_at"),
+		KafkaPartition:   psql.Quote(alias, "kafka_partition"),
+		KafkaOffset:      psql.Quote(alias, "kafka_offset"),
 	}
 }
 
 type iotAlertEventColumns struct {
 	expr.ColumnsExpr
-	tableAlias   string
-	Time         psql.Expression
-	DeviceID     psql.Expression
-	AlertType    psql.Expression
-	Severity     psql.Expression
-	Message      psql.Expression
-	CurrentValue psql.Expression
+	tableAlias       string
+	Time             psql.Expression
+	DeviceID         psql.Expression
+	AlertType        psql.Expression
+	Severity         psql.Expression
+	Message          psql.Expression
+	CurrentValue     psql.Expression
+	MqttTopic        psql.Expression
+	MqttClientID     psql.Expression
+	BrokerReceivedAt psql.Expression
+	KafkaPartition   psql.Expression
+	KafkaOffset      psql.Expression
 }
 
 func (c iotAlertEventColumns) Alias() string {
@@ -93,12 +108,17 @@ func (o IotAlertEventSlice) AfterQueryHook(ctx context.Context, exec bob.Executo
 }
 
 type iotAlertEventWhere[Q psql.Filterable] struct {
-	Time         psql.WhereMod[Q, time.Time]
-	DeviceID     psql.WhereMod[Q, string]
-	AlertType    psql.WhereMod[Q, string]
-	Severity     psql.WhereMod[Q, string]
-	Message      psql.WhereMod[Q, string]
-	CurrentValue psql.WhereNullMod[Q, decimal.Decimal]
+	Time             psql.WhereMod[Q, time.Time]
+	DeviceID         psql.WhereMod[Q, string]
+	AlertType        psql.WhereMod[Q, string]
+	Severity         psql.WhereMod[Q, string]
+	Message          psql.WhereMod[Q, string]
+	CurrentValue     psql.WhereNullMod[Q, decimal.Decimal]
+	MqttTopic        psql.WhereNullMod[Q, string]
+	MqttClientID     psql.WhereNullMod[Q, string]
+	BrokerReceivedAt psql.WhereNullMod[Q, time.Time]
+	KafkaPartition   psql.WhereNullMod[Q, int32]
+	KafkaOffset      psql.WhereNullMod[Q, int64]
 }
 
 func (iotAlertEventWhere[Q]) AliasedAs(alias string) iotAlertEventWhere[Q] {
@@ -107,11 +127,16 @@ func (iotAlertEventWhere[Q]) AliasedAs(alias string) iotAlertEventWhere[Q] {
 
 func buildIotAlertEventWhere[Q psql.Filterable](cols iotAlertEventColumns) iotAlertEventWhere[Q] {
 	return iotAlertEventWhere[Q]{
-		Time:         psql.Where[Q, time.Time](cols.Time),
-		DeviceID:     psql.Where[Q, string](cols.DeviceID),
-		AlertType:    psql.Where[Q, string](cols.AlertType),
-		Severity:     psql.Where[Q, string](cols.Severity),
-		Message:      psql.Where[Q, string](cols.Message),
-		CurrentValue: psql.WhereNull[Q, decimal.Decimal](cols.CurrentValue),
+		Time:             psql.Where[Q, time.Time](cols.Time),
+		DeviceID:         psql.Where[Q, string](cols.DeviceID),
+		AlertType:        psql.Where[Q, string](cols.AlertType),
+		Severity:         psql.Where[Q, string](cols.Severity),
+		Message:          psql.Where[Q, string](cols.Message),
+		CurrentValue:     psql.WhereNull[Q, decimal.Decimal](cols.CurrentValue),
+		MqttTopic:        psql.WhereNull[Q, string](cols.MqttTopic),
+		MqttClientID:     psql.WhereNull[Q, string](cols.MqttClientID),
+		BrokerReceivedAt: psql.WhereNull[Q, time.Time](cols.BrokerReceivedAt),
+		KafkaPartition:   psql.WhereNull[Q, int32](cols.KafkaPartition),
+		KafkaOffset:      psql.WhereNull[Q, int64](cols.KafkaOffset),
 	}
 }
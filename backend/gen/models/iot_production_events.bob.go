@@ -7,6 +7,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/aarondl/opt/null"
 	"github.com/stephenafamo/bob"
 	"github.com/stephenafamo/bob/dialect/psql"
 	"github.com/stephenafamo/bob/expr"
@@ -14,13 +15,18 @@ import (
 
 // IotProductionEvent is an object representing the database table.
 type IotProductionEvent struct {
-	Time           time.Time `db:"time" `
-	DeviceID       string    `db:"device_id" `
-	ProductionType string    `db:"production_type" `
-	ProductSku     string    `db:"product_sku" `
-	UnitCount      int32     `db:"unit_count" `
-	BatchID        string    `db:"batch_id" `
-	QualityStatus  string    `db:"quality_status" `
+	Time             time.Time           `db:"time" `
+	DeviceID         string              `db:"device_id" `
+	ProductionType   string              `db:"production_type" `
+	ProductSku       string              `db:"product_sku" `
+	UnitCount        int32               `db:"unit_count" `
+	BatchID          string              `db:"batch_id" `
+	QualityStatus    string              `db:"quality_status" `
+	MqttTopic        null.Val[string]    `db:"mqtt_topic" `
+	MqttClientID     null.Val[string]    `db:"mqtt_client_id" `
+	BrokerReceivedAt null.Val[time.Time] `db:"broker_received_at" `
+	KafkaPartition   null.Val[int32]     `db:"kafka_partition" `
+	KafkaOffset      null.Val[int64]     `db:"kafka_offset" `
 }
 
 // IotProductionEventSlice is an alias for a slice of pointers to IotProductionEvent.
@@ -36,29 +42,39 @@ type IotProductionEventsQuery = *psql.ViewQuery[*IotProductionEvent, IotProducti
 func buildIotProductionEventColumns(alias string) iotProductionEventColumns {
 	return iotProductionEventColumns{
 		ColumnsExpr: expr.NewColumnsExpr(
-			"time", "device_id", "production_type", "product_sku", "unit_count", "batch_id", "quality_status",
+			"time", "device_id", "production_type", "product_sku", "unit_count", "batch_id", "quality_status", "mqtt_topic", "mqtt_client_id", "broker_received_at", "kafka_partition", "kafka_offset",
 		).WithParent("iot_production_events"),
-		tableAlias:     alias,
-		Time:           psql.Quote(alias, "time"),
-		DeviceID:       psql.Quote(alias, "device_id"),
-		ProductionType: psql.Quote(alias, "production_type"),
-		ProductSku:     psql.Quote(alias, "product_sku"),
-		UnitCount:      psql.Quote(alias, "unit_count"),
-		BatchID:        psql.Quote(alias, "batch_id"),
-		QualityStatus:  psql.Quote(alias, "quality_status"),
+		tableAlias:       alias,
+		Time:             psql.Quote(alias, "time"),
+		DeviceID:         psql.Quote(alias, "device_id"),
+		ProductionType:   psql.Quote(alias, "production_type"),
+		ProductSku:       psql.Quote(alias, "product_sku"),
+		UnitCount:        psql.Quote(alias, "unit_count"),
+		BatchID:          psql.Quote(alias, "batch_id"),
+		QualityStatus:    psql.Quote(alias, "quality_status"),
+		MqttTopic:        psql.Quote(alias, "mqtt_topic"),
+		MqttClientID:     psql.Quote(alias, "mqtt_client_id"),
+		BrokerReceivedAt: psql.Quote(alias, "broker_received_at"),
+		KafkaPartition:   psql.Quote(alias, "kafka_partition"),
+		KafkaOffset:      psql.Quote(alias, "kafka_offset"),
 	}
 }
 
 type iotProductionEventColumns struct {
 	expr.ColumnsExpr
-	tableAlias     string
-	Time           psql.Expression
-	DeviceID       psql.Expression
-	ProductionType psql.Expression
-	ProductSku     psql.Expression
-	UnitCount      psql.Expression
-	BatchID        psql.Expression
-	QualityStatus  psql.Expression
+	tableAlias       string
+	Time             psql.Expression
+	DeviceID         psql.Expression
+	ProductionType   psql.Expression
+	ProductSku       psql.Expression
+	UnitCount        psql.Expression
+	BatchID          psql.Expression
+	QualityStatus    psql.Expression
+	MqttTopic        psql.Expression
+	MqttClientID     psql.Expression
+	BrokerReceivedAt psql.Expression
+	KafkaPartition   psql.Expression
+	KafkaOffset      psql.Expression
 }
 
 func (c iotProductionEventColumns) Alias() string {
@@ -94,13 +110,18 @@ func (o IotProductionEventSlice) AfterQueryHook(ctx context.Context, exec bob.Ex
 }
 
 type iotProductionEventWhere[Q psql.Filterable] struct {
-	Time           psql.WhereMod[Q, time.Time]
-	DeviceID       psql.WhereMod[Q, string]
-	ProductionType psql.WhereMod[Q, string]
-	ProductSku     psql.WhereMod[Q, string]
-	UnitCount      psql.WhereMod[Q, int32]
-	BatchID        psql.WhereMod[Q, string]
-	QualityStatus  psql.WhereMod[Q, string]
+	Time             psql.WhereMod[Q, time.Time]
+	DeviceID         psql.WhereMod[Q, string]
+	ProductionType   psql.WhereMod[Q, string]
+	ProductSku       psql.WhereMod[Q, string]
+	UnitCount        psql.WhereMod[Q, int32]
+	BatchID          psql.WhereMod[Q, string]
+	QualityStatus    psql.WhereMod[Q, string]
+	MqttTopic        psql.WhereNullMod[Q, string]
+	MqttClientID     psql.WhereNullMod[Q, string]
+	BrokerReceivedAt psql.WhereNullMod[Q, time.Time]
+	KafkaPartition   psql.WhereNullMod[Q, int32]
+	KafkaOffset      psql.WhereNullMod[Q, int64]
 }
 
 func (iotProductionEventWhere[Q]) AliasedAs(alias string) iotProductionEventWhere[Q] {
@@ -109,12 +130,17 @@ func (iotProductionEventWhere[Q]) AliasedAs(alias string) iotProductionEventWher
 
 func buildIotProductionEventWhere[Q psql.Filterable](cols iotProductionEventColumns) iotProductionEventWhere[Q] {
 	return iotProductionEventWhere[Q]{
-		Time:           psql.Where[Q, time.Time](cols.Time),
-		DeviceID:       psql.Where[Q, string](cols.DeviceID),
-		ProductionType: psql.Where[Q, string](cols.ProductionType),
-		ProductSku:     psql.Where[Q, string](cols.ProductSku),
-		UnitCount:      psql.Where[Q, int32](cols.UnitCount),
-		BatchID:        psql.Where[Q, string](cols.BatchID),
-		QualityStatus:  psql.Where[Q, string](cols.QualityStatus),
+		Time:             psql.Where[Q, time.Time](cols.Time),
+		DeviceID:         psql.Where[Q, string](cols.DeviceID),
+		ProductionType:   psql.Where[Q, string](cols.ProductionType),
+		ProductSku:       psql.Where[Q, string](cols.ProductSku),
+		UnitCount:        psql.Where[Q, int32](cols.UnitCount),
+		BatchID:          psql.Where[Q, string](cols.BatchID),
+		QualityStatus:    psql.Where[Q, string](cols.QualityStatus),
+		MqttTopic:        psql.WhereNull[Q, string](cols.MqttTopic),
+		MqttClientID:     psql.WhereNull[Q, string](cols.MqttClientID),
+		BrokerReceivedAt: psql.WhereNull[Q, time.Time](cols.BrokerReceivedAt),
+		KafkaPartition:   psql.WhereNull[Q, int32](cols.KafkaPartition),
+		KafkaOffset:      psql.WhereNull[Q, int64](cols.KafkaOffset),
 	}
 }
@@ -7,6 +7,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/aarondl/opt/null"
 	"github.com/stephenafamo/bob"
 	"github.com/stephenafamo/bob/dialect/psql"
 	"github.com/stephenafamo/bob/expr"
@@ -14,11 +15,16 @@ import (
 
 // IotStatusEvent is an object representing the database table.
 type IotStatusEvent struct {
-	Time      time.Time `db:"time" `
-	DeviceID  string    `db:"device_id" `
-	OldStatus string    `db:"old_status" `
-	NewStatus string    `db:"new_status" `
-	Reason    string    `db:"reason" `
+	Time             time.Time           `db:"time" `
+	DeviceID         string              `db:"device_id" `
+	OldStatus        string              `db:"old_status" `
+	NewStatus        string              `db:"new_status" `
+	Reason           string              `db:"reason" `
+	MqttTopic        null.Val[string]    `db:"mqtt_topic" `
+	MqttClientID     null.Val[string]    `db:"mqtt_client_id" `
+	BrokerReceivedAt null.Val[time.Time] `db:"broker_received_at" `
+	KafkaPartition   null.Val[int32]     `db:"kafka_partition" `
+	KafkaOffset      null.Val[int64]     `db:"kafka_offset" `
 }
 
 // IotStatusEventSlice is an alias for a slice of pointers to IotStatusEvent.
@@ -34,25 +40,35 @@ type IotStatusEventsQuery = *psql.ViewQuery[*IotStatusEvent, IotStatusEventSlice
 func buildIotStatusEventColumns(alias string) iotStatusEventColumns {
 	return iotStatusEventColumns{
 		ColumnsExpr: expr.NewColumnsExpr(
-			"time", "device_id", "old_status", "new_status", "reason",
+			"time", "device_id", "old_status", "new_status", "reason", "mqtt_topic", "mqtt_client_id", "broker_received_at", "kafka_partition", "kafka_offset",
 		).WithParent("iot_status_events"),
-		tableAlias: alias,
-		Time:       psql.Quote(alias, "time"),
-		DeviceID:   psql.Quote(alias, "device_id"),
-		OldStatus:  psql.Quote(alias, "old_status"),
-		NewStatus:  psql.Quote(alias, "new_status"),
-		Reason:     psql.Quote(alias, "reason"),
+		tableAlias:       alias,
+		Time:             psql.Quote(alias, "time"),
+		DeviceID:         psql.Quote(alias, "device_id"),
+		OldStatus:        psql.Quote(alias, "old_status"),
+		NewStatus:        psql.Quote(alias, "new_status"),
+		Reason:           psql.Quote(alias, "reason"),
+		MqttTopic:        psql.Quote(alias, "mqtt_topic"),
+		MqttClientID:     psql.Quote(alias, "mqtt_client_id"),
+		BrokerReceivedAt: psql.Quote(alias, "broker_received_at"),
+		KafkaPartition:   psql.Quote(alias, "kafka_partition"),
+		KafkaOffset:      psql.Quote(alias, "kafka_offset"),
 	}
 }
 
 type iotStatusEventColumns struct {
 	expr.ColumnsExpr
-	tableAlias string
-	Time       psql.Expression
-	DeviceID   psql.Expression
-	OldStatus  psql.Expression
-	NewStatus  psql.Expression
-	Reason     psql.Expression
+	tableAlias       string
+	Time             psql.Expression
+	DeviceID         psql.Expression
+	OldStatus        psql.Expression
+	NewStatus        psql.Expression
+	Reason           psql.Expression
+	MqttTopic        psql.Expression
+	MqttClientID     psql.Expression
+	BrokerReceivedAt psql.Expression
+	KafkaPartition   psql.Expression
+	KafkaOffset      psql.Expression
 }
 
 func (c iotStatusEventColumns) Alias() string {
@@ -88,11 +104,16 @@ func (o IotStatusEventSlice) AfterQueryHook(ctx context.Context, exec bob.Execut
 }
 
 type iotStatusEventWhere[Q psql.Filterable] struct {
-	Time      psql.WhereMod[Q, time.Time]
-	DeviceID  psql.WhereMod[Q, string]
-	OldStatus psql.WhereMod[Q, string]
-	NewStatus psql.WhereMod[Q, string]
-	Reason    psql.WhereMod[Q, string]
+	Time             psql.WhereMod[Q, time.Time]
+	DeviceID         psql.WhereMod[Q, string]
+	OldStatus        psql.WhereMod[Q, string]
+	NewStatus        psql.WhereMod[Q, string]
+	Reason           psql.WhereMod[Q, string]
+	MqttTopic        psql.WhereNullMod[Q, string]
+	MqttClientID     psql.WhereNullMod[Q, string]
+	BrokerReceivedAt psql.WhereNullMod[Q, time.Time]
+	KafkaPartition   psql.WhereNullMod[Q, int32]
+	KafkaOffset      psql.WhereNullMod[Q, int64]
 }
 
 func (iotStatusEventWhere[Q]) AliasedAs(alias string) iotStatusEventWhere[Q] {
@@ -101,10 +122,15 @@ func (iotStatusEventWhere[Q]) AliasedAs(alias string) iotStatusEventWhere[Q] {
 
 func buildIotStatusEventWhere[Q psql.Filterable](cols iotStatusEventColumns) iotStatusEventWhere[Q] {
 	return iotStatusEventWhere[Q]{
-		Time:      psql.Where[Q, time.Time](cols.Time),
-		DeviceID:  psql.Where[Q, string](cols.DeviceID),
-		OldStatus: psql.Where[Q, string](cols.OldStatus),
-		NewStatus: psql.Where[Q, string](cols.NewStatus),
-		Reason:    psql.Where[Q, string](cols.Reason),
+		Time:             psql.Where[Q, time.Time](cols.Time),
+		DeviceID:         psql.Where[Q, string](cols.DeviceID),
+		OldStatus:        psql.Where[Q, string](cols.OldStatus),
+		NewStatus:        psql.Where[Q, string](cols.NewStatus),
+		Reason:           psql.Where[Q, string](cols.Reason),
+		MqttTopic:        psql.WhereNull[Q, string](cols.MqttTopic),
+		MqttClientID:     psql.WhereNull[Q, string](cols.MqttClientID),
+		BrokerReceivedAt: psql.WhereNull[Q, time.Time](cols.BrokerReceivedAt),
+		KafkaPartition:   psql.WhereNull[Q, int32](cols.KafkaPartition),
+		KafkaOffset:      psql.WhereNull[Q, int64](cols.KafkaOffset),
 	}
 }
@@ -16,15 +16,20 @@ import (
 
 // IotTelemetryEvent is an object representing the database table.
 type IotTelemetryEvent struct {
-	Time               time.Time        `db:"time" `
-	DeviceID           string           `db:"device_id" `
-	TemperatureCelcius decimal.Decimal  `db:"temperature_celcius" `
-	HumidityPercent    decimal.Decimal  `db:"humidity_percent" `
-	VibrationHZ        decimal.Decimal  `db:"vibration_hz" `
-	MotorRPM           int32            `db:"motor_rpm" `
-	CurrentAmps        decimal.Decimal  `db:"current_amps" `
-	MachineStatus      string           `db:"machine_status" `
-	ErrorCode          null.Val[string] `db:"error_code" `
+	Time               time.Time           `db:"time" `
+	DeviceID           string              `db:"device_id" `
+	TemperatureCelcius decimal.Decimal     `db:"temperature_celcius" `
+	HumidityPercent    decimal.Decimal     `db:"humidity_percent" `
+	VibrationHZ        decimal.Decimal     `db:"vibration_hz" `
+	MotorRPM           int32               `db:"motor_rpm" `
+	CurrentAmps        decimal.Decimal     `db:"current_amps" `
+	MachineStatus      string              `db:"machine_status" `
+	ErrorCode          null.Val[string]    `db:"error_code" `
+	MqttTopic          null.Val[string]    `db:"mqtt_topic" `
+	MqttClientID       null.Val[string]    `db:"mqtt_client_id" `
+	BrokerReceivedAt   null.Val[time.Time] `db:"broker_received_at" `
+	KafkaPartition     null.Val[int32]     `db:"kafka_partition" `
+	KafkaOffset        null.Val[int64]     `db:"kafka_offset" `
 }
 
 // IotTelemetryEventSlice is an alias for a slice of pointers to IotTelemetryEvent.
@@ -40,7 +45,7 @@ type IotTelemetryEventsQuery = *psql.ViewQuery[*IotTelemetryEvent, IotTelemetryE
 func buildIotTelemetryEventColumns(alias string) iotTelemetryEventColumns {
 	return iotTelemetryEventColumns{
 		ColumnsExpr: expr.NewColumnsExpr(
-			"time", "device_id", "temperature_celcius", "humidity_percent", "vibration_hz", "motor_rpm", "current_amps", "machine_status", "error_code",
+			"time", "device_id", "temperature_celcius", "humidity_percent", "vibration_hz", "motor_rpm", "current_amps", "machine_status", "error_code", "mqtt_topic", "mqtt_client_id", "broker_received_at", "kafka_partition", "kafka_offset",
 		).WithParent("iot_telemetry_events"),
 		tableAlias:         alias,
 		Time:               psql.Quote(alias, "time"),
@@ -52,6 +57,11 @@ func buildIotTelemetryEventColumns(alias string) iotTelemetryEventColumns {
 		CurrentAmps:        psql.Quote(alias, "current_amps"),
 		MachineStatus:      psql.Quote(alias, "machine_status"),
 		ErrorCode:          psql.Quote(alias, "error_code"),
+		MqttTopic:          psql.Quote(alias, "mqtt_topic"),
+		MqttClientID:       psql.Quote(alias, "mqtt_client_id"),
+		BrokerReceivedAt:   psql.Quote(alias, "broker_received_at"),
+		KafkaPartition:     psql.Quote(alias, "kafka_partition"),
+		KafkaOffset:        psql.Quote(alias, "kafka_offset"),
 	}
 }
 
@@ -67,6 +77,11 @@ type iotTelemetryEventColumns struct {
 	CurrentAmps        psql.Expression
 	MachineStatus      psql.Expression
 	ErrorCode          psql.Expression
+	MqttTopic          psql.Expression
+	MqttClientID       psql.Expression
+	BrokerReceivedAt   psql.Expression
+	KafkaPartition     psql.Expression
+	KafkaOffset        psql.Expression
 }
 
 func (c iotTelemetryEventColumns) Alias() string {
@@ -111,6 +126,11 @@ type iotTelemetryEventWhere[Q psql.Filterable] struct {
 	CurrentAmps        psql.WhereMod[Q, decimal.Decimal]
 	MachineStatus      psql.WhereMod[Q, string]
 	ErrorCode          psql.WhereNullMod[Q, string]
+	MqttTopic          psql.WhereNullMod[Q, string]
+	MqttClientID       psql.WhereNullMod[Q, string]
+	BrokerReceivedAt   psql.WhereNullMod[Q, time.Time]
+	KafkaPartition     psql.WhereNullMod[Q, int32]
+	KafkaOffset        psql.WhereNullMod[Q, int64]
 }
 
 func (iotTelemetryEventWhere[Q]) AliasedAs(alias string) iotTelemetryEventWhere[Q] {
@@ -128,5 +148,10 @@ func buildIotTelemetryEventWhere[Q psql.Filterable](cols iotTelemetryEventColumn
 		CurrentAmps:        psql.Where[Q, decimal.Decimal](cols.CurrentAmps),
 		MachineStatus:      psql.Where[Q, string](cols.MachineStatus),
 		ErrorCode:          psql.WhereNull[Q, string](cols.ErrorCode),
+		MqttTopic:          psql.WhereNull[Q, string](cols.MqttTopic),
+		MqttClientID:       psql.WhereNull[Q, string](cols.MqttClientID),
+		BrokerReceivedAt:   psql.WhereNull[Q, time.Time](cols.BrokerReceivedAt),
+		KafkaPartition:     psql.WhereNull[Q, int32](cols.KafkaPartition),
+		KafkaOffset:        psql.WhereNull[Q, int64](cols.KafkaOffset),
 	}
 }
@@ -0,0 +1,308 @@
+package application_iot
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"iiot_system/backend/gen/models"
+
+	"github.com/stephenafamo/bob"
+	"github.com/stephenafamo/bob/dialect/psql"
+	"github.com/stephenafamo/bob/dialect/psql/dialect"
+	"github.com/stephenafamo/bob/dialect/psql/sm"
+	bobpgx "github.com/stephenafamo/bob/drivers/pgx"
+	"github.com/stephenafamo/scan"
+)
+
+const (
+	// DefaultBucketPoints is how many buckets a range is split into when no
+	// bucket size is requested.
+	DefaultBucketPoints = 500
+	// MaxBuckets bounds the buckets of an aggregation.
+	MaxBuckets = 10000
+)
+
+// bucketSizes are the sizes picked from when splitting a range into a number
+// of buckets, so that charts get round boundaries.
+var bucketSizes = []time.Duration{
+	time.Second,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+	time.Minute,
+	5 * time.Minute,
+	10 * time.Minute,
+	15 * time.Minute,
+	30 * time.Minute,
+	time.Hour,
+	3 * time.Hour,
+	6 * time.Hour,
+	12 * time.Hour,
+	24 * time.Hour,
+	7 * 24 * time.Hour,
+}
+
+// AutoBucket returns the smallest bucket size of bucketSizes splitting r into
+// at most points buckets.
+func AutoBucket(r TimeRange, points int) time.Duration {
+	span := r.To.Sub(r.From)
+	for _, size := range bucketSizes {
+		if span <= size*time.Duration(points) {
+			return size
+		}
+	}
+	// Ranges of decades only fit in buckets of several weeks.
+	weeks := bucketSizes[len(bucketSizes)-1]
+	return (span/time.Duration(points)/weeks + 1) * weeks
+}
+
+// TelemetryAggregate is a function summarizing a measurement over a bucket.
+type TelemetryAggregate string
+
+const (
+	TelemetryAvg TelemetryAggregate = "avg"
+	TelemetryMin TelemetryAggregate = "min"
+	TelemetryMax TelemetryAggregate = "max"
+	TelemetryP50 TelemetryAggregate = "p50"
+	TelemetryP90 TelemetryAggregate = "p90"
+	TelemetryP95 TelemetryAggregate = "p95"
+	TelemetryP99 TelemetryAggregate = "p99"
+)
+
+// percentiles are the fractions of the percentile aggregates.
+var percentiles = map[TelemetryAggregate]string{
+	TelemetryP50: "0.5",
+	TelemetryP90: "0.9",
+	TelemetryP95: "0.95",
+	TelemetryP99: "0.99",
+}
+
+// ParseTelemetryAggregate returns the aggregate named s.
+func ParseTelemetryAggregate(s string) (TelemetryAggregate, error) {
+	switch a := TelemetryAggregate(s); a {
+	case TelemetryAvg, TelemetryMin, TelemetryMax, TelemetryP50, TelemetryP90, TelemetryP95, TelemetryP99:
+		return a, nil
+	}
+	return "", fmt.Errorf("%w: unknown aggregate: %s", ErrInvalidQuery, s)
+}
+
+// sql returns the aggregate of column, as a double so it can be interpolated.
+func (a TelemetryAggregate) sql(column string) string {
+	if fraction, ok := percentiles[a]; ok {
+		return fmt.Sprintf("percentile_cont(%s) WITHIN GROUP (ORDER BY %s)", fraction, column)
+	}
+	return fmt.Sprintf("%s(%s)::double precision", a, column)
+}
+
+// GapFill tells how the buckets without events are returned.
+type GapFill string
+
+const (
+	// GapFillNone omits the empty buckets.
+	GapFillNone GapFill = "none"
+	// GapFillNull returns the empty buckets without values.
+	GapFillNull GapFill = "null"
+	// GapFillLOCF carries the last value observed forward.
+	GapFillLOCF GapFill = "locf"
+	// GapFillInterpolate interpolates linearly between the values around.
+	GapFillInterpolate GapFill = "interpolate"
+)
+
+// ParseGapFill returns the gap filling named s, GapFillNone when empty.
+func ParseGapFill(s string) (GapFill, error) {
+	switch f := GapFill(s); f {
+	case "":
+		return GapFillNone, nil
+	case GapFillNone, GapFillNull, GapFillLOCF, GapFillInterpolate:
+		return f, nil
+	}
+	return "", fmt.Errorf("%w: unknown gap filling: %s", ErrInvalidQuery, s)
+}
+
+// numeric reports whether f can be aggregated.
+func (f TelemetryField) numeric() bool {
+	return f != TelemetryMachineStatus && f != TelemetryErrorCode
+}
+
+// TelemetryAggregateQuery downsamples the telemetry of a device by
+// aggregating its measurements over buckets of time.
+type TelemetryAggregateQuery struct {
+	DeviceID string
+	Range    TimeRange
+	// Bucket is the size of the buckets. When zero, the range is split into
+	// about Points buckets, DefaultBucketPoints when Points is zero too.
+	Bucket time.Duration
+	Points int
+	// Fields are the numeric measurements aggregated, every one when empty.
+	Fields []TelemetryField
+	// Aggregates are applied to every field, the average when empty.
+	Aggregates []TelemetryAggregate
+	Fill       GapFill
+}
+
+// TelemetryBucket holds the aggregates of the measurements of a bucket. They
+// are nil in empty buckets that could not be filled.
+type TelemetryBucket struct {
+	Time   time.Time
+	Values map[TelemetryField]map[TelemetryAggregate]*float64
+}
+
+// TelemetrySeries is the result of a TelemetryAggregateQuery.
+type TelemetrySeries struct {
+	Bucket     time.Duration
+	Fields     []TelemetryField
+	Aggregates []TelemetryAggregate
+	Fill       GapFill
+	Buckets    []TelemetryBucket
+}
+
+type TelemetryAggregateQueryHandler struct {
+	db bobpgx.Pool
+}
+
+func NewTelemetryAggregateQueryHandler(db bobpgx.Pool) *TelemetryAggregateQueryHandler {
+	return &TelemetryAggregateQueryHandler{
+		db: db,
+	}
+}
+
+func (h TelemetryAggregateQueryHandler) Handle(ctx context.Context, q TelemetryAggregateQuery) (TelemetrySeries, error) {
+	series, err := q.series()
+	if err != nil {
+		return TelemetrySeries{}, err
+	}
+
+	rows, err := bob.All(ctx, h.db, q.build(series), scan.MapMapper[any])
+	if err != nil {
+		return TelemetrySeries{}, err
+	}
+
+	series.Buckets = make([]TelemetryBucket, len(rows))
+	for i, row := range rows {
+		b := TelemetryBucket{
+			Values: make(map[TelemetryField]map[TelemetryAggregate]*float64, len(series.Fields)),
+		}
+		b.Time, _ = row["bucket"].(time.Time)
+		for _, f := range series.Fields {
+			values := make(map[TelemetryAggregate]*float64, len(series.Aggregates))
+			for _, a := range series.Aggregates {
+				if v, ok := row[aggregateColumn(f, a)].(float64); ok {
+					values[a] = &v
+				} else {
+					values[a] = nil
+				}
+			}
+			b.Values[f] = values
+		}
+		series.Buckets[i] = b
+	}
+	return series, nil
+}
+
+// series validates q and returns the series it selects, without buckets.
+func (q TelemetryAggregateQuery) series() (TelemetrySeries, error) {
+	if q.DeviceID == "" {
+		return TelemetrySeries{}, fmt.Errorf("%w: the device id is required", ErrInvalidQuery)
+	}
+	if err := q.Range.validate(); err != nil {
+		return TelemetrySeries{}, err
+	}
+
+	s := TelemetrySeries{
+		Bucket:     q.Bucket,
+		Fields:     unique(q.Fields),
+		Aggregates: unique(q.Aggregates),
+		Fill:       q.Fill,
+	}
+	if s.Bucket == 0 {
+		points := q.Points
+		if points <= 0 {
+			points = DefaultBucketPoints
+		}
+		if points > MaxBuckets {
+			return TelemetrySeries{}, fmt.Errorf("%w: the points must not exceed %d", ErrInvalidQuery, MaxBuckets)
+		}
+		s.Bucket = AutoBucket(q.Range, points)
+	}
+	if s.Bucket < time.Second {
+		return TelemetrySeries{}, fmt.Errorf("%w: the bucket must be at least a second", ErrInvalidQuery)
+	}
+	if q.Range.To.Sub(q.Range.From)/s.Bucket > MaxBuckets {
+		return TelemetrySeries{}, fmt.Errorf("%w: the range holds more than %d buckets of %s", ErrInvalidQuery, MaxBuckets, s.Bucket)
+	}
+
+	if len(s.Fields) == 0 {
+		for _, f := range TelemetryFields {
+			if f.numeric() {
+				s.Fields = append(s.Fields, f)
+			}
+		}
+	}
+	for _, f := range s.Fields {
+		if !f.numeric() {
+			return TelemetrySeries{}, fmt.Errorf("%w: %s cannot be aggregated", ErrInvalidQuery, f)
+		}
+	}
+	if len(s.Aggregates) == 0 {
+		s.Aggregates = []TelemetryAggregate{TelemetryAvg}
+	}
+	if s.Fill == "" {
+		s.Fill = GapFillNone
+	}
+	return s, nil
+}
+
+// build returns the query aggregating the buckets of s with TimescaleDB
+// time_bucket, or time_bucket_gapfill when the gaps are filled.
+func (q TelemetryAggregateQuery) build(s TelemetrySeries) bob.BaseQuery[*dialect.SelectQuery] {
+	interval := fmt.Sprintf("%d milliseconds", s.Bucket.Milliseconds())
+	bucket := psql.Raw("time_bucket(?::interval, time)", interval)
+	if s.Fill != GapFillNone {
+		bucket = psql.Raw("time_bucket_gapfill(?::interval, time, ?::timestamptz, ?::timestamptz)",
+			interval, q.Range.From, q.Range.To)
+	}
+
+	columns := []any{bucket.As("bucket")}
+	for _, f := range s.Fields {
+		for _, a := range s.Aggregates {
+			value := a.sql(`"` + string(f) + `"`)
+			switch s.Fill {
+			case GapFillLOCF:
+				value = "locf(" + value + ")"
+			case GapFillInterpolate:
+				value = "interpolate(" + value + ")"
+			}
+			columns = append(columns, psql.Raw(value).As(aggregateColumn(f, a)))
+		}
+	}
+
+	where := models.SelectWhere.IotTelemetryEvents
+	mods := []bob.Mod[*dialect.SelectQuery]{
+		sm.Columns(columns...),
+		sm.From(models.IotTelemetryEvents.NameAs()),
+		where.DeviceID.EQ(q.DeviceID),
+		where.Time.GTE(q.Range.From),
+		where.Time.LT(q.Range.To),
+		sm.GroupBy(psql.Quote("bucket")),
+		sm.OrderBy(psql.Quote("bucket")).Asc(),
+	}
+	return psql.Select(mods...)
+}
+
+// aggregateColumn names the column holding the aggregate a of f.
+func aggregateColumn(f TelemetryField, a TelemetryAggregate) string {
+	return string(f) + "_" + string(a)
+}
+
+// unique returns values without repetitions, in order.
+func unique[T comparable](values []T) []T {
+	var u []T
+	for _, v := range values {
+		if !slices.Contains(u, v) {
+			u = append(u, v)
+		}
+	}
+	return u
+}
@@ -0,0 +1,77 @@
+package application_iot
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAutoBucket(t *testing.T) {
+	from := time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		span   time.Duration
+		points int
+		want   time.Duration
+	}{
+		{time.Hour, 500, 10 * time.Second},
+		{24 * time.Hour, 500, 5 * time.Minute},
+		{90 * 24 * time.Hour, 500, 6 * time.Hour},
+		{90 * 24 * time.Hour, 100, 24 * time.Hour},
+		{time.Minute, 500, time.Second},
+		{20 * 365 * 24 * time.Hour, 100, 11 * 7 * 24 * time.Hour},
+	} {
+		r := TimeRange{From: from, To: from.Add(tt.span)}
+		if got := AutoBucket(r, tt.points); got != tt.want {
+			t.Errorf("AutoBucket(%s, %d) = %s, want %s", tt.span, tt.points, got, tt.want)
+		}
+	}
+}
+
+func TestTelemetryAggregateQuery(t *testing.T) {
+	from := time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+	q := TelemetryAggregateQuery{
+		DeviceID:   "press-1",
+		Range:      TimeRange{From: from, To: from.Add(90 * 24 * time.Hour)},
+		Fields:     []TelemetryField{TelemetryVibration},
+		Aggregates: []TelemetryAggregate{TelemetryAvg, TelemetryP95, TelemetryAvg},
+		Fill:       GapFillLOCF,
+	}
+
+	s, err := q.series()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Bucket != 6*time.Hour || len(s.Aggregates) != 2 {
+		t.Fatalf("got buckets of %s with aggregates %v", s.Bucket, s.Aggregates)
+	}
+
+	sql, args, err := q.build(s).Build(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"time_bucket_gapfill($1::interval, time, $2::timestamptz, $3::timestamptz) AS \"bucket\"",
+		"locf(avg(\"vibration_hz\")::double precision) AS \"vibration_hz_avg\"",
+		"locf(percentile_cont(0.95) WITHIN GROUP (ORDER BY \"vibration_hz\")) AS \"vibration_hz_p95\"",
+		"GROUP BY \"bucket\"",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("query does not contain %s:\n%s", want, sql)
+		}
+	}
+	if args[0] != "21600000 milliseconds" {
+		t.Errorf("bucket interval %v", args[0])
+	}
+
+	for _, invalid := range []TelemetryAggregateQuery{
+		{DeviceID: "press-1", Range: q.Range, Bucket: time.Second},
+		{DeviceID: "press-1", Range: q.Range, Fields: []TelemetryField{TelemetryMachineStatus}},
+		{DeviceID: "press-1", Range: TimeRange{From: from, To: from}},
+	} {
+		if _, err := invalid.series(); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("%+v: got %v, want an invalid query", invalid, err)
+		}
+	}
+}
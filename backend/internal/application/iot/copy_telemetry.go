@@ -0,0 +1,81 @@
+package application_iot
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// telemetryColumns are the iot_telemetry_events columns in the order
+// telemetryRows and insertTelemetry produce them.
+var telemetryColumns = append([]string{
+	"time",
+	"device_id",
+	"temperature_celcius",
+	"humidity_percent",
+	"vibration_hz",
+	"motor_rpm",
+	"current_amps",
+	"machine_status",
+	"error_code",
+}, ingestionColumns...)
+
+const (
+	telemetryTable        = "iot_telemetry_events"
+	telemetryStagingTable = "iot_telemetry_events_staging"
+)
+
+func telemetryRows(command []InsertTelemetryCommand) pgx.CopyFromSource {
+	return pgx.CopyFromSlice(len(command), func(i int) ([]any, error) {
+		v := command[i]
+		return append([]any{
+			v.Time,
+			v.DeviceID,
+			v.TemperatureCelcius,
+			v.HumidityPercent,
+			v.VibrationHZ,
+			v.MotorRPM,
+			v.CurrentAmps,
+			v.MachineStatus,
+			v.ErrorCode,
+		}, v.Ingestion.values()...), nil
+	})
+}
+
+// copyTelemetry streams command into iot_telemetry_events with COPY, which is
+// not bound by the 65535 bind parameters limit of a multi-row INSERT.
+func copyTelemetry(ctx context.Context, exec Executor, command []InsertTelemetryCommand) (int64, error) {
+	return exec.CopyFrom(ctx, pgx.Identifier{telemetryTable}, telemetryColumns, telemetryRows(command))
+}
+
+// copyTelemetryStaging streams command into a per-connection staging table
+// and moves it into iot_telemetry_events with ON CONFLICT DO NOTHING, so rows
+// that already exist are skipped instead of failing the COPY.
+func copyTelemetryStaging(ctx context.Context, exec Executor, command []InsertTelemetryCommand) (int64, error) {
+	if _, err := exec.ExecContext(ctx, fmt.Sprintf(
+		"CREATE TEMP TABLE IF NOT EXISTS %s (LIKE %s INCLUDING DEFAULTS) ON COMMIT DELETE ROWS",
+		telemetryStagingTable, telemetryTable,
+	)); err != nil {
+		return 0, err
+	}
+
+	if _, err := exec.CopyFrom(ctx, pgx.Identifier{telemetryStagingTable}, telemetryColumns, telemetryRows(command)); err != nil {
+		return 0, err
+	}
+
+	res, err := exec.ExecContext(ctx, fmt.Sprintf(
+		"INSERT INTO %s SELECT * FROM %s ON CONFLICT DO NOTHING",
+		telemetryTable, telemetryStagingTable,
+	))
+	if err != nil {
+		return 0, err
+	}
+
+	// Empty the staging table for the next statement of the transaction.
+	if _, err := exec.ExecContext(ctx, "TRUNCATE "+telemetryStagingTable); err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -0,0 +1,42 @@
+package application_iot
+
+import (
+	"time"
+
+	"github.com/aarondl/opt/null"
+)
+
+// ingestionColumns are the event table columns holding IngestionMetadata,
+// in the order IngestionMetadata.values produces them.
+var ingestionColumns = []string{
+	"mqtt_topic",
+	"mqtt_client_id",
+	"broker_received_at",
+	"kafka_partition",
+	"kafka_offset",
+}
+
+// IngestionMetadata records how an event reached the backend, to audit device
+// identities and measure device-to-broker latency.
+type IngestionMetadata struct {
+	// MQTTTopic is the topic the device published the event to.
+	MQTTTopic string
+	// MQTTClientID is the client id the device was connected to the broker
+	// with. It should match the device id of the event.
+	MQTTClientID string
+	// BrokerReceivedAt is when the MQTT broker received the event.
+	BrokerReceivedAt time.Time
+	KafkaPartition   int32
+	KafkaOffset      int64
+}
+
+// values returns the ingestionColumns values, NULL where unknown.
+func (m IngestionMetadata) values() []any {
+	return []any{
+		null.FromCond(m.MQTTTopic, m.MQTTTopic != ""),
+		null.FromCond(m.MQTTClientID, m.MQTTClientID != ""),
+		null.FromCond(m.BrokerReceivedAt, !m.BrokerReceivedAt.IsZero()),
+		m.KafkaPartition,
+		m.KafkaOffset,
+	}
+}
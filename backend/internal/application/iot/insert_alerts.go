@@ -11,8 +11,20 @@ import (
 	"github.com/stephenafamo/bob/dialect/psql"
 	"github.com/stephenafamo/bob/dialect/psql/dialect"
 	"github.com/stephenafamo/bob/dialect/psql/im"
+	bobpgx "github.com/stephenafamo/bob/drivers/pgx"
 )
 
+// alertColumns are the iot_alert_events columns in the order insertAlerts
+// produces them.
+var alertColumns = append([]string{
+	"time",
+	"device_id",
+	"alert_type",
+	"severity",
+	"message",
+	"current_value",
+}, ingestionColumns...)
+
 type InsertAlertsCommand struct {
 	Time         time.Time
 	DeviceID     string
@@ -20,29 +32,26 @@ type InsertAlertsCommand struct {
 	Severity     string
 	Message      string
 	CurrentValue null.Val[float64]
+	Ingestion    IngestionMetadata
 }
 
 type InsertAlertsCommandHandler struct {
-	db bob.DB
+	batch batchInserter
 }
 
-func NewInsertAlertsCommandHandler(db bob.DB) *InsertAlertsCommandHandler {
+func NewInsertAlertsCommandHandler(db bobpgx.Pool, opts ...HandlerOption) *InsertAlertsCommandHandler {
 	return &InsertAlertsCommandHandler{
-		db: db,
+		batch: newBatchInserter(db, alertsTable, opts),
 	}
 }
 
-func (h InsertAlertsCommandHandler) Handle(ctx context.Context, command ...InsertAlertsCommand) error {
-	if len(command) == 0 {
-		return nil
-	}
+func (h InsertAlertsCommandHandler) Handle(ctx context.Context, command ...InsertAlertsCommand) (InsertResult, error) {
+	return insertBatch(ctx, h.batch, command, insertAlerts)
+}
 
-	t, err := h.db.BeginTx(ctx, nil)
-	if err != nil {
-		return err
-	}
+func insertAlerts(ctx context.Context, exec Executor, command []InsertAlertsCommand) (int64, error) {
 	q := psql.Insert(
-		im.Into(models.IotAlertEvents.Name()),
+		im.Into(models.IotAlertEvents.Name(), alertColumns...),
 	)
 
 	for _, v := range command {
@@ -51,41 +60,40 @@ func (h InsertAlertsCommandHandler) Handle(ctx context.Context, command ...Inser
 		var value bob.Mod[*dialect.InsertQuery]
 		if exist {
 			value = im.Values(
-				psql.Arg(v.Time,
+				psql.Arg(append([]any{v.Time,
 					v.DeviceID,
 					v.AlertType,
 					v.Severity,
 					v.Message,
-					val),
+					val}, v.Ingestion.values()...)...),
 			)
 		} else {
 			value = im.Values(
-				psql.Arg(v.Time,
+				psql.Arg(append([]any{v.Time,
 					v.DeviceID,
 					v.AlertType,
 					v.Severity,
 					v.Message,
-					nil),
+					nil}, v.Ingestion.values()...)...),
 			)
 		}
 
 		q.Apply(value)
 	}
 
-	query, args, err := q.Build(ctx)
-	if err != nil {
-		return err
-	}
+	// Replayed and redelivered events hit the natural key unique index and
+	// are skipped.
+	q.Apply(im.OnConflict().DoNothing())
 
-	_, err = t.ExecContext(ctx, query, args...)
+	query, args, err := q.Build(ctx)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	err = t.Commit(ctx)
+	res, err := exec.ExecContext(ctx, query, args...)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	return err
+	return res.RowsAffected()
 }
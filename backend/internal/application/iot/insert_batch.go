@@ -0,0 +1,256 @@
+package application_iot
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stephenafamo/bob"
+	bobpgx "github.com/stephenafamo/bob/drivers/pgx"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("iiot_system/backend/internal/application/iot")
+
+// RetryPolicy controls how transient database errors are retried.
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: 200 * time.Millisecond,
+	MaxBackoff:     5 * time.Second,
+}
+
+// backoff returns the delay before the given retry, doubling from
+// InitialBackoff up to MaxBackoff.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.InitialBackoff
+	for i := 1; i < attempt && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, p.MaxBackoff)
+}
+
+// RejectedCommand is a command the database permanently refused, identified
+// by its index in the batch passed to Handle.
+type RejectedCommand struct {
+	Index int
+	Err   error
+}
+
+// InsertResult describes the outcome of a batch insert. Rows that were not
+// rejected are stored even when Rejected is not empty. Duplicates counts the
+// commands skipped because their natural key was already stored.
+type InsertResult struct {
+	Inserted   int64
+	Duplicates int64
+	Rejected   []RejectedCommand
+}
+
+// Executor is the transaction a batch is written in. It runs bob queries as
+// well as pgx COPY statements.
+type Executor interface {
+	bob.Executor
+	CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
+}
+
+// InsertObserver is notified of every batch a command handler wrote to table
+// or failed to, after any retry.
+type InsertObserver interface {
+	ObserveInsert(table string, result InsertResult, duration time.Duration, err error)
+}
+
+// HandlerOption configures a command handler.
+type HandlerOption func(*batchInserter)
+
+// WithInsertObserver makes a command handler report its batches to observer.
+func WithInsertObserver(observer InsertObserver) HandlerOption {
+	return func(b *batchInserter) {
+		b.observer = observer
+	}
+}
+
+// insertFunc inserts commands with a single statement and returns the number
+// of rows stored.
+type insertFunc[C any] func(ctx context.Context, exec Executor, command []C) (int64, error)
+
+// batchInserter runs insert functions in a transaction, retrying transient
+// errors with exponential backoff and bisecting batches that fail
+// permanently, so that only the offending rows are rejected.
+type batchInserter struct {
+	db       bobpgx.Pool
+	table    string
+	retry    RetryPolicy
+	observer InsertObserver
+}
+
+func newBatchInserter(db bobpgx.Pool, table string, opts []HandlerOption) batchInserter {
+	b := batchInserter{
+		db:    db,
+		table: table,
+		retry: DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(&b)
+	}
+	return b
+}
+
+func insertBatch[C any](ctx context.Context, b batchInserter, command []C, insert insertFunc[C]) (InsertResult, error) {
+	offsets, storeOffsets := sourceOffsetsFromContext(ctx)
+	if len(command) == 0 && !storeOffsets {
+		return InsertResult{}, nil
+	}
+
+	ctx, span := tracer.Start(ctx, "insert "+b.table, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		semconv.DBSystemNamePostgreSQL,
+		semconv.DBCollectionName(b.table),
+		semconv.DBOperationBatchSize(len(command)),
+	))
+	defer span.End()
+
+	started := time.Now()
+	result, err := retryBatch(ctx, b, command, insert, offsets, storeOffsets)
+	if b.observer != nil && len(command) > 0 {
+		b.observer.ObserveInsert(b.table, result, time.Since(started), err)
+	}
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else {
+		span.SetAttributes(
+			attribute.Int64("iiot.rows.inserted", result.Inserted),
+			attribute.Int64("iiot.rows.duplicate", result.Duplicates),
+			attribute.Int("iiot.rows.rejected", len(result.Rejected)),
+		)
+	}
+	return result, err
+}
+
+func retryBatch[C any](ctx context.Context, b batchInserter, command []C, insert insertFunc[C], offsets sourceOffsets, storeOffsets bool) (InsertResult, error) {
+	for attempt := 1; ; attempt++ {
+		result, err := insertInTx(ctx, b.db, command, insert, offsets, storeOffsets)
+		if err == nil {
+			return result, nil
+		}
+		if ctx.Err() != nil || attempt >= b.retry.MaxAttempts {
+			return InsertResult{}, err
+		}
+
+		backoff := b.retry.backoff(attempt)
+		slog.WarnContext(ctx, "retrying batch insert",
+			"table", b.table, "rows", len(command), "attempt", attempt, "backoff", backoff, "error", err)
+		select {
+		case <-ctx.Done():
+			return InsertResult{}, errors.Join(err, ctx.Err())
+		case <-time.After(backoff):
+		}
+	}
+}
+
+func insertInTx[C any](ctx context.Context, db bobpgx.Pool, command []C, insert insertFunc[C], offsets sourceOffsets, storeOffsets bool) (InsertResult, error) {
+	t, err := db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return InsertResult{}, err
+	}
+	defer t.Rollback(ctx)
+
+	var result InsertResult
+	if len(command) > 0 {
+		if err := bisect(ctx, t, command, 0, insert, &result); err != nil {
+			return InsertResult{}, err
+		}
+	}
+
+	if storeOffsets {
+		if err := saveSourceOffsets(ctx, t, offsets); err != nil {
+			return InsertResult{}, err
+		}
+	}
+
+	if err := t.Commit(ctx); err != nil {
+		return InsertResult{}, err
+	}
+
+	return result, nil
+}
+
+// bisect inserts command inside a savepoint. When the database rejects the
+// statement permanently, the savepoint is rolled back and both halves are
+// retried until the failing rows are isolated. Transient errors abort the
+// whole transaction so the batch can be retried as a unit.
+func bisect[C any](ctx context.Context, exec Executor, command []C, base int, insert insertFunc[C], result *InsertResult) error {
+	if _, err := exec.ExecContext(ctx, "SAVEPOINT batch_insert"); err != nil {
+		return err
+	}
+
+	inserted, err := insert(ctx, exec, command)
+	if err == nil {
+		result.Inserted += inserted
+		result.Duplicates += int64(len(command)) - inserted
+		_, err = exec.ExecContext(ctx, "RELEASE SAVEPOINT batch_insert")
+		return err
+	}
+	if !IsPermanent(err) {
+		return err
+	}
+
+	if _, rbErr := exec.ExecContext(ctx, "ROLLBACK TO SAVEPOINT batch_insert"); rbErr != nil {
+		return rbErr
+	}
+	if _, rbErr := exec.ExecContext(ctx, "RELEASE SAVEPOINT batch_insert"); rbErr != nil {
+		return rbErr
+	}
+
+	if len(command) == 1 {
+		// Writers that cannot skip conflicts, such as COPY, report an
+		// already stored row as a unique violation.
+		if isUniqueViolation(err) {
+			result.Duplicates++
+			return nil
+		}
+		result.Rejected = append(result.Rejected, RejectedCommand{Index: base, Err: err})
+		return nil
+	}
+
+	mid := len(command) / 2
+	if err := bisect(ctx, exec, command[:mid], base, insert, result); err != nil {
+		return err
+	}
+	return bisect(ctx, exec, command[mid:], base+mid, insert, result)
+}
+
+// IsPermanent reports whether the database refused err because of the data
+// itself (values out of range, strings too long, constraint violations, ...).
+// Retrying such a statement cannot succeed, while any other error - lost
+// connections, serialization failures, deadlocks - may go away on retry.
+func IsPermanent(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || len(pgErr.Code) < 2 {
+		return false
+	}
+
+	switch pgErr.Code[:2] {
+	case "22", // data exception
+		"23": // integrity constraint violation
+		return true
+	}
+	return false
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
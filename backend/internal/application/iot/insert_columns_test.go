@@ -0,0 +1,80 @@
+package application_iot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stephenafamo/scan"
+)
+
+// recordingExecutor records the statements it is given without running
+// them.
+type recordingExecutor struct {
+	query string
+	args  []any
+}
+
+func (e *recordingExecutor) ExecContext(_ context.Context, query string, args ...any) (sql.Result, error) {
+	e.query, e.args = query, args
+	return driverResult(0), nil
+}
+
+func (e *recordingExecutor) QueryContext(context.Context, string, ...any) (scan.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+func (e *recordingExecutor) CopyFrom(context.Context, pgx.Identifier, []string, pgx.CopyFromSource) (int64, error) {
+	return 0, errors.New("not supported")
+}
+
+type driverResult int64
+
+func (r driverResult) LastInsertId() (int64, error) { return 0, nil }
+func (r driverResult) RowsAffected() (int64, error) { return int64(r), nil }
+
+func TestInsertsNameTheirColumns(t *testing.T) {
+	now := time.Now()
+	for _, tt := range []struct {
+		table   string
+		columns []string
+		insert  func(ctx context.Context, exec Executor) (int64, error)
+	}{
+		{telemetryTable, telemetryColumns, func(ctx context.Context, exec Executor) (int64, error) {
+			return insertTelemetry(ctx, exec, []InsertTelemetryCommand{{Time: now}, {Time: now}})
+		}},
+		{alertsTable, alertColumns, func(ctx context.Context, exec Executor) (int64, error) {
+			return insertAlerts(ctx, exec, []InsertAlertsCommand{{Time: now}, {Time: now}})
+		}},
+		{productionTable, productionColumns, func(ctx context.Context, exec Executor) (int64, error) {
+			return insertProduction(ctx, exec, []InsertProductionCommand{{Time: now}, {Time: now}})
+		}},
+		{statusUpdateTable, statusUpdateColumns, func(ctx context.Context, exec Executor) (int64, error) {
+			return insertStatusUpdates(ctx, exec, []InsertStatusUpdateCommand{{Time: now}, {Time: now}})
+		}},
+	} {
+		t.Run(tt.table, func(t *testing.T) {
+			exec := &recordingExecutor{}
+			if _, err := tt.insert(context.Background(), exec); err != nil {
+				t.Fatal(err)
+			}
+
+			quoted := make([]string, len(tt.columns))
+			for i, c := range tt.columns {
+				quoted[i] = fmt.Sprintf("%q", c)
+			}
+			want := fmt.Sprintf("INSERT INTO %q(%s)", tt.table, strings.Join(quoted, ", "))
+			if !strings.Contains(exec.query, want) {
+				t.Errorf("query does not contain %s:\n%s", want, exec.query)
+			}
+			if len(exec.args) != 2*len(tt.columns) {
+				t.Errorf("%d arguments for 2 rows of %d columns", len(exec.args), len(tt.columns))
+			}
+		})
+	}
+}
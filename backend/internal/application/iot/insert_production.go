@@ -6,11 +6,23 @@ import (
 
 	"iiot_system/backend/gen/models"
 
-	"github.com/stephenafamo/bob"
 	"github.com/stephenafamo/bob/dialect/psql"
 	"github.com/stephenafamo/bob/dialect/psql/im"
+	bobpgx "github.com/stephenafamo/bob/drivers/pgx"
 )
 
+// productionColumns are the iot_production_events columns in the order
+// insertProduction produces them.
+var productionColumns = append([]string{
+	"time",
+	"device_id",
+	"production_type",
+	"product_sku",
+	"unit_count",
+	"batch_id",
+	"quality_status",
+}, ingestionColumns...)
+
 type InsertProductionCommand struct {
 	Time           time.Time
 	DeviceID       string
@@ -19,55 +31,56 @@ type InsertProductionCommand struct {
 	UnitCount      int32
 	BatchID        string
 	QualityStatus  string
+	Ingestion      IngestionMetadata
 }
 
 type InsertProductionCommandHandler struct {
-	db bob.DB
+	batch batchInserter
 }
 
-func NewInsertProductionCommandHandler(db bob.DB) *InsertProductionCommandHandler {
+func NewInsertProductionCommandHandler(db bobpgx.Pool, opts ...HandlerOption) *InsertProductionCommandHandler {
 	return &InsertProductionCommandHandler{
-		db: db,
+		batch: newBatchInserter(db, productionTable, opts),
 	}
 }
 
-func (h InsertProductionCommandHandler) Handle(ctx context.Context, command ...InsertProductionCommand) error {
-	if len(command) == 0 {
-		return nil
-	}
+func (h InsertProductionCommandHandler) Handle(ctx context.Context, command ...InsertProductionCommand) (InsertResult, error) {
+	return insertBatch(ctx, h.batch, command, insertProduction)
+}
 
-	t, err := h.db.BeginTx(ctx, nil)
-	if err != nil {
-		return err
-	}
+func insertProduction(ctx context.Context, exec Executor, command []InsertProductionCommand) (int64, error) {
 	q := psql.Insert(
-		im.Into(models.IotProductionEvents.Name()),
+		im.Into(models.IotProductionEvents.Name(), productionColumns...),
 	)
 
 	for _, v := range command {
 		value := im.Values(
-			psql.Arg(v.Time,
+			psql.Arg(append([]any{v.Time,
 				v.DeviceID,
 				v.ProductionType,
 				v.ProductSku,
 				v.UnitCount,
 				v.BatchID,
 				v.QualityStatus,
-			),
+			}, v.Ingestion.values()...)...),
 		)
 
 		q.Apply(value)
 	}
 
+	// Replayed and redelivered events hit the natural key unique index and
+	// are skipped.
+	q.Apply(im.OnConflict().DoNothing())
+
 	query, args, err := q.Build(ctx)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	_, err = t.ExecContext(ctx, query, args...)
+	res, err := exec.ExecContext(ctx, query, args...)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	return t.Commit(ctx)
+	return res.RowsAffected()
 }
@@ -6,69 +6,75 @@ import (
 
 	"iiot_system/backend/gen/models"
 
-	"github.com/stephenafamo/bob"
 	"github.com/stephenafamo/bob/dialect/psql"
 	"github.com/stephenafamo/bob/dialect/psql/im"
+	bobpgx "github.com/stephenafamo/bob/drivers/pgx"
 )
 
+// statusUpdateColumns are the iot_status_events columns in the order
+// insertStatusUpdates produces them.
+var statusUpdateColumns = append([]string{
+	"time",
+	"device_id",
+	"old_status",
+	"new_status",
+	"reason",
+}, ingestionColumns...)
+
 type InsertStatusUpdateCommand struct {
 	Time      time.Time
 	DeviceID  string
 	OldStatus string
 	NewStatus string
 	Reason    string
+	Ingestion IngestionMetadata
 }
 
 type InsertStatusUpdateCommandHandler struct {
-	db bob.DB
+	batch batchInserter
 }
 
-func NewInsertStatusUpdateCommandHandler(db bob.DB) *InsertStatusUpdateCommandHandler {
+func NewInsertStatusUpdateCommandHandler(db bobpgx.Pool, opts ...HandlerOption) *InsertStatusUpdateCommandHandler {
 	return &InsertStatusUpdateCommandHandler{
-		db: db,
+		batch: newBatchInserter(db, statusUpdateTable, opts),
 	}
 }
 
-func (h InsertStatusUpdateCommandHandler) Handle(ctx context.Context, command ...InsertStatusUpdateCommand) error {
-	if len(command) == 0 {
-		return nil
-	}
+func (h InsertStatusUpdateCommandHandler) Handle(ctx context.Context, command ...InsertStatusUpdateCommand) (InsertResult, error) {
+	return insertBatch(ctx, h.batch, command, insertStatusUpdates)
+}
 
-	t, err := h.db.BeginTx(ctx, nil)
-	if err != nil {
-		return err
-	}
+func insertStatusUpdates(ctx context.Context, exec Executor, command []InsertStatusUpdateCommand) (int64, error) {
 	q := psql.Insert(
-		im.Into(models.IotStatusEvents.Name()),
+		im.Into(models.IotStatusEvents.Name(), statusUpdateColumns...),
 	)
 
 	for _, v := range command {
 		value := im.Values(
-			psql.Arg(v.Time,
+			psql.Arg(append([]any{v.Time,
 				v.DeviceID,
 				v.OldStatus,
 				v.NewStatus,
 				v.Reason,
-			),
+			}, v.Ingestion.values()...)...),
 		)
 
 		q.Apply(value)
 	}
 
-	query, args, err := q.Build(ctx)
-	if err != nil {
-		return err
-	}
+	// Replayed and redelivered events hit the natural key unique index and
+	// are skipped.
+	q.Apply(im.OnConflict().DoNothing())
 
-	_, err = t.ExecContext(ctx, query, args...)
+	query, args, err := q.Build(ctx)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	err = t.Commit(ctx)
+	res, err := exec.ExecContext(ctx, query, args...)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	return err
+	return res.RowsAffected()
 }
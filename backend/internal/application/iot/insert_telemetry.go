@@ -2,15 +2,16 @@ package application_iot
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"iiot_system/backend/gen/models"
 
 	"github.com/aarondl/opt/null"
 	"github.com/shopspring/decimal"
-	"github.com/stephenafamo/bob"
 	"github.com/stephenafamo/bob/dialect/psql"
 	"github.com/stephenafamo/bob/dialect/psql/im"
+	bobpgx "github.com/stephenafamo/bob/drivers/pgx"
 )
 
 type InsertTelemetryCommand struct {
@@ -23,34 +24,67 @@ type InsertTelemetryCommand struct {
 	CurrentAmps        decimal.Decimal
 	MachineStatus      string
 	ErrorCode          null.Val[string]
+	Ingestion          IngestionMetadata
+}
+
+// TelemetryWriter selects how InsertTelemetryCommandHandler writes a batch.
+type TelemetryWriter string
+
+const (
+	// TelemetryWriterInsert writes a batch with a single multi-row INSERT.
+	TelemetryWriterInsert TelemetryWriter = "insert"
+	// TelemetryWriterCopy streams a batch with COPY FROM STDIN. COPY cannot
+	// skip conflicts, so batches holding duplicates are bisected down to them.
+	TelemetryWriterCopy TelemetryWriter = "copy"
+	// TelemetryWriterCopyStaging streams a batch into a temporary staging
+	// table, then moves it with INSERT ... ON CONFLICT DO NOTHING.
+	TelemetryWriterCopyStaging TelemetryWriter = "copy_staging"
+)
+
+// ParseTelemetryWriter returns the writer named s.
+func ParseTelemetryWriter(s string) (TelemetryWriter, error) {
+	switch w := TelemetryWriter(s); w {
+	case TelemetryWriterInsert, TelemetryWriterCopy, TelemetryWriterCopyStaging:
+		return w, nil
+	}
+	return "", fmt.Errorf("unknown telemetry writer: %s", s)
+}
+
+func (w TelemetryWriter) insertFunc() insertFunc[InsertTelemetryCommand] {
+	switch w {
+	case TelemetryWriterCopy:
+		return copyTelemetry
+	case TelemetryWriterCopyStaging:
+		return copyTelemetryStaging
+	default:
+		return insertTelemetry
+	}
 }
 
 type InsertTelemetryCommandHandler struct {
-	db bob.DB
+	batch  batchInserter
+	insert insertFunc[InsertTelemetryCommand]
 }
 
-func NewInsertTelemetryCommandHandler(db bob.DB) *InsertTelemetryCommandHandler {
+func NewInsertTelemetryCommandHandler(db bobpgx.Pool, writer TelemetryWriter, opts ...HandlerOption) *InsertTelemetryCommandHandler {
 	return &InsertTelemetryCommandHandler{
-		db: db,
+		batch:  newBatchInserter(db, telemetryTable, opts),
+		insert: writer.insertFunc(),
 	}
 }
 
-func (h InsertTelemetryCommandHandler) Handle(ctx context.Context, command ...InsertTelemetryCommand) error {
-	if len(command) == 0 {
-		return nil
-	}
+func (h InsertTelemetryCommandHandler) Handle(ctx context.Context, command ...InsertTelemetryCommand) (InsertResult, error) {
+	return insertBatch(ctx, h.batch, command, h.insert)
+}
 
-	t, err := h.db.BeginTx(ctx, nil)
-	if err != nil {
-		return err
-	}
+func insertTelemetry(ctx context.Context, exec Executor, command []InsertTelemetryCommand) (int64, error) {
 	q := psql.Insert(
-		im.Into(models.IotTelemetryEvents.Name()),
+		im.Into(models.IotTelemetryEvents.Name(), telemetryColumns...),
 	)
 
 	for _, v := range command {
 		value := im.Values(
-			psql.Arg(v.Time,
+			psql.Arg(append([]any{v.Time,
 				v.DeviceID,
 				v.TemperatureCelcius,
 				v.HumidityPercent,
@@ -59,26 +93,25 @@ func (h InsertTelemetryCommandHandler) Handle(ctx context.Context, command ...In
 				v.CurrentAmps,
 				v.MachineStatus,
 				v.ErrorCode,
-			),
+			}, v.Ingestion.values()...)...),
 		)
 
 		q.Apply(value)
 	}
 
-	query, args, err := q.Build(ctx)
-	if err != nil {
-		return err
-	}
+	// Replayed and redelivered events hit the natural key unique index and
+	// are skipped.
+	q.Apply(im.OnConflict().DoNothing())
 
-	_, err = t.ExecContext(ctx, query, args...)
+	query, args, err := q.Build(ctx)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	err = t.Commit(ctx)
+	res, err := exec.ExecContext(ctx, query, args...)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	return err
+	return res.RowsAffected()
 }
@@ -0,0 +1,103 @@
+package application_iot
+
+import (
+	"context"
+
+	"github.com/stephenafamo/bob"
+	"github.com/stephenafamo/bob/dialect/psql"
+	"github.com/stephenafamo/bob/dialect/psql/im"
+	"github.com/stephenafamo/bob/dialect/psql/sm"
+	bobpgx "github.com/stephenafamo/bob/drivers/pgx"
+	"github.com/stephenafamo/scan"
+)
+
+const kafkaOffsetsTable = "kafka_offsets"
+
+// SourceOffset is the next Kafka offset to consume from a partition once a
+// batch is stored.
+type SourceOffset struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+}
+
+type sourceOffsetsKey struct{}
+
+type sourceOffsets struct {
+	group   string
+	offsets []SourceOffset
+}
+
+// ContextWithSourceOffsets asks the command handlers to store offsets for the
+// consumer group in the same transaction as the commands they insert, so the
+// events and the position they were consumed up to are committed atomically.
+// A handler called without commands then only stores the offsets.
+func ContextWithSourceOffsets(ctx context.Context, group string, offsets []SourceOffset) context.Context {
+	return context.WithValue(ctx, sourceOffsetsKey{}, sourceOffsets{group: group, offsets: offsets})
+}
+
+func sourceOffsetsFromContext(ctx context.Context) (sourceOffsets, bool) {
+	o, ok := ctx.Value(sourceOffsetsKey{}).(sourceOffsets)
+	return o, ok && len(o.offsets) > 0
+}
+
+func saveSourceOffsets(ctx context.Context, exec Executor, o sourceOffsets) error {
+	q := psql.Insert(
+		im.Into(kafkaOffsetsTable, "consumer_group", "topic", "partition", "next_offset"),
+	)
+
+	for _, v := range o.offsets {
+		q.Apply(im.Values(psql.Arg(o.group, v.Topic, v.Partition, v.Offset)))
+	}
+
+	q.Apply(im.OnConflict("consumer_group", "topic", "partition").DoUpdate(
+		im.SetExcluded("next_offset"),
+		im.Set(psql.Quote("updated_at").EQ(psql.Raw("now()"))),
+	))
+
+	query, args, err := q.Build(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = exec.ExecContext(ctx, query, args...)
+	return err
+}
+
+// OffsetStore reads the offsets stored alongside the events.
+type OffsetStore struct {
+	db bobpgx.Pool
+}
+
+func NewOffsetStore(db bobpgx.Pool) *OffsetStore {
+	return &OffsetStore{
+		db: db,
+	}
+}
+
+// Load returns the next offset to consume for each partition of topic that
+// has stored offsets for the consumer group.
+func (s *OffsetStore) Load(ctx context.Context, group, topic string) (map[int32]int64, error) {
+	q := psql.Select(
+		sm.Columns("partition", "next_offset"),
+		sm.From(kafkaOffsetsTable),
+		sm.Where(psql.Quote("consumer_group").EQ(psql.Arg(group))),
+		sm.Where(psql.Quote("topic").EQ(psql.Arg(topic))),
+	)
+
+	type row struct {
+		Partition  int32 `db:"partition"`
+		NextOffset int64 `db:"next_offset"`
+	}
+
+	rows, err := bob.All(ctx, s.db, q, scan.StructMapper[row]())
+	if err != nil {
+		return nil, err
+	}
+
+	offsets := make(map[int32]int64, len(rows))
+	for _, r := range rows {
+		offsets[r.Partition] = r.NextOffset
+	}
+	return offsets, nil
+}
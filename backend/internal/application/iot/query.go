@@ -0,0 +1,49 @@
+package application_iot
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Bounds of the rows returned by a query page.
+const (
+	DefaultQueryLimit = 1000
+	MaxQueryLimit     = 10000
+)
+
+// ErrInvalidQuery is wrapped by the errors of queries asking for something
+// that cannot be answered, such as an empty time range.
+var ErrInvalidQuery = errors.New("invalid query")
+
+// ErrNotFound is wrapped by the errors of queries for a single thing that
+// does not exist.
+var ErrNotFound = errors.New("not found")
+
+// TimeRange selects the events from From, inclusive, to To, exclusive.
+type TimeRange struct {
+	From time.Time
+	To   time.Time
+}
+
+func (r TimeRange) validate() error {
+	if r.From.IsZero() || r.To.IsZero() {
+		return fmt.Errorf("%w: the time range must have a start and an end", ErrInvalidQuery)
+	}
+	if !r.From.Before(r.To) {
+		return fmt.Errorf("%w: the start of the time range must be before its end", ErrInvalidQuery)
+	}
+	return nil
+}
+
+// queryLimit returns limit, defaulted when not positive, or an error when it
+// exceeds MaxQueryLimit.
+func queryLimit(limit int) (int, error) {
+	if limit <= 0 {
+		return DefaultQueryLimit, nil
+	}
+	if limit > MaxQueryLimit {
+		return 0, fmt.Errorf("%w: the limit must not exceed %d", ErrInvalidQuery, MaxQueryLimit)
+	}
+	return limit, nil
+}
@@ -0,0 +1,70 @@
+package application_iot
+
+import (
+	"context"
+	"fmt"
+
+	iotalerts "iiot_system/backend/internal/domain/iot/iot_alerts"
+)
+
+// AlertsQuery selects a page of the alerts matching a specification.
+type AlertsQuery struct {
+	Spec iotalerts.AlertSpec
+	// Page sorts the alerts by time, latest first, unless told otherwise.
+	Page iotalerts.AlertPage
+}
+
+// AlertsPage is a page of the alerts selected by an AlertsQuery, along with
+// the counts of every alert matching its specification.
+type AlertsPage struct {
+	Alerts []*iotalerts.IotAlert
+	Counts iotalerts.AlertCounts
+	Limit  int
+	Offset int
+}
+
+type AlertsQueryHandler struct {
+	alerts iotalerts.IotAlertRepository
+}
+
+func NewAlertsQueryHandler(alerts iotalerts.IotAlertRepository) *AlertsQueryHandler {
+	return &AlertsQueryHandler{
+		alerts: alerts,
+	}
+}
+
+func (h AlertsQueryHandler) Handle(ctx context.Context, q AlertsQuery) (AlertsPage, error) {
+	if !q.Spec.From.IsZero() && !q.Spec.To.IsZero() {
+		if err := (TimeRange{From: q.Spec.From, To: q.Spec.To}).validate(); err != nil {
+			return AlertsPage{}, err
+		}
+	}
+	if q.Page.Offset < 0 {
+		return AlertsPage{}, fmt.Errorf("%w: the offset must not be negative", ErrInvalidQuery)
+	}
+	limit, err := queryLimit(q.Page.Limit)
+	if err != nil {
+		return AlertsPage{}, err
+	}
+	q.Page.Limit = limit
+	if q.Page.SortBy == "" {
+		q.Page.SortBy = iotalerts.SortByTime
+		q.Page.Descending = true
+	}
+
+	alerts, err := h.alerts.FindBySpec(ctx, q.Spec, q.Page)
+	if err != nil {
+		return AlertsPage{}, err
+	}
+	counts, err := h.alerts.CountBySpec(ctx, q.Spec)
+	if err != nil {
+		return AlertsPage{}, err
+	}
+
+	return AlertsPage{
+		Alerts: alerts,
+		Counts: counts,
+		Limit:  q.Page.Limit,
+		Offset: q.Page.Offset,
+	}, nil
+}
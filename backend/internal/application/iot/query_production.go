@@ -0,0 +1,394 @@
+package application_iot
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"iiot_system/backend/gen/models"
+
+	"github.com/stephenafamo/bob"
+	"github.com/stephenafamo/bob/dialect/psql"
+	"github.com/stephenafamo/bob/dialect/psql/dialect"
+	"github.com/stephenafamo/bob/dialect/psql/sm"
+	bobpgx "github.com/stephenafamo/bob/drivers/pgx"
+	"github.com/stephenafamo/scan"
+)
+
+// ProductionEventsQuery selects production events, latest first. Its empty
+// filters do not restrict the selection.
+type ProductionEventsQuery struct {
+	Range         TimeRange
+	DeviceID      string
+	SKU           string
+	BatchID       string
+	QualityStatus string
+	Limit         int
+	Offset        int
+}
+
+// ProductionEventsPage is a page of the events selected by a
+// ProductionEventsQuery, Total counting every one of them.
+type ProductionEventsPage struct {
+	Events models.IotProductionEventSlice
+	Total  int64
+	Limit  int
+	Offset int
+}
+
+type ProductionEventsQueryHandler struct {
+	db bobpgx.Pool
+}
+
+func NewProductionEventsQueryHandler(db bobpgx.Pool) *ProductionEventsQueryHandler {
+	return &ProductionEventsQueryHandler{
+		db: db,
+	}
+}
+
+func (h ProductionEventsQueryHandler) Handle(ctx context.Context, q ProductionEventsQuery) (ProductionEventsPage, error) {
+	if err := q.Range.validate(); err != nil {
+		return ProductionEventsPage{}, err
+	}
+	if q.Offset < 0 {
+		return ProductionEventsPage{}, fmt.Errorf("%w: the offset must not be negative", ErrInvalidQuery)
+	}
+	limit, err := queryLimit(q.Limit)
+	if err != nil {
+		return ProductionEventsPage{}, err
+	}
+
+	where := models.SelectWhere.IotProductionEvents
+	mods := []bob.Mod[*dialect.SelectQuery]{
+		where.Time.GTE(q.Range.From),
+		where.Time.LT(q.Range.To),
+	}
+	if q.DeviceID != "" {
+		mods = append(mods, where.DeviceID.EQ(q.DeviceID))
+	}
+	if q.SKU != "" {
+		mods = append(mods, where.ProductSku.EQ(q.SKU))
+	}
+	if q.BatchID != "" {
+		mods = append(mods, where.BatchID.EQ(q.BatchID))
+	}
+	if q.QualityStatus != "" {
+		mods = append(mods, where.QualityStatus.EQ(q.QualityStatus))
+	}
+
+	total, err := models.IotProductionEvents.Query(mods...).Count(ctx, h.db)
+	if err != nil {
+		return ProductionEventsPage{}, err
+	}
+
+	cols := models.IotProductionEvents.Columns
+	mods = append(mods,
+		sm.OrderBy(cols.Time).Desc(),
+		sm.OrderBy(cols.DeviceID).Asc(),
+		sm.OrderBy(cols.BatchID).Asc(),
+		sm.OrderBy(cols.ProductSku).Asc(),
+		sm.Limit(limit),
+		sm.Offset(q.Offset),
+	)
+	events, err := models.IotProductionEvents.Query(mods...).All(ctx, h.db)
+	if err != nil {
+		return ProductionEventsPage{}, err
+	}
+
+	return ProductionEventsPage{
+		Events: events,
+		Total:  total,
+		Limit:  limit,
+		Offset: q.Offset,
+	}, nil
+}
+
+// ProductionBatch traces a production batch to the devices that produced it.
+type ProductionBatch struct {
+	BatchID string
+	SKUs    []string
+	// First and Last are the times of the first and last events of the batch.
+	First      time.Time
+	Last       time.Time
+	Events     int64
+	TotalUnits int64
+	// Quality counts the units of the batch by quality status.
+	Quality map[string]int64
+	Devices []ProductionBatchDevice
+}
+
+// ProductionBatchDevice is the share of a batch produced by a device.
+type ProductionBatchDevice struct {
+	DeviceID string
+	First    time.Time
+	Last     time.Time
+	Events   int64
+	Units    int64
+	Quality  map[string]int64
+}
+
+// productionGroup is the production of a batch by a device for a SKU and a
+// quality status.
+type productionGroup struct {
+	DeviceID      string    `db:"device_id"`
+	ProductSku    string    `db:"product_sku"`
+	QualityStatus string    `db:"quality_status"`
+	Events        int64     `db:"events"`
+	Units         int64     `db:"units"`
+	First         time.Time `db:"first"`
+	Last          time.Time `db:"last"`
+}
+
+type ProductionBatchQueryHandler struct {
+	db bobpgx.Pool
+}
+
+func NewProductionBatchQueryHandler(db bobpgx.Pool) *ProductionBatchQueryHandler {
+	return &ProductionBatchQueryHandler{
+		db: db,
+	}
+}
+
+// Handle returns the batch batchID, or ErrNotFound if no event belongs to it.
+func (h ProductionBatchQueryHandler) Handle(ctx context.Context, batchID string) (ProductionBatch, error) {
+	groups, err := bob.All(ctx, h.db, h.build(batchID), scan.StructMapper[productionGroup]())
+	if err != nil {
+		return ProductionBatch{}, err
+	}
+	if len(groups) == 0 {
+		return ProductionBatch{}, fmt.Errorf("%w: no production event of batch %s", ErrNotFound, batchID)
+	}
+	return mergeProductionBatch(batchID, groups), nil
+}
+
+// build returns the query summing the production of a batch by device, SKU
+// and quality status, ordered by device. Batches are looked up through the
+// iot_production_events_batch index of every chunk.
+func (h ProductionBatchQueryHandler) build(batchID string) bob.BaseQuery[*dialect.SelectQuery] {
+	cols := models.IotProductionEvents.Columns
+	return psql.Select(
+		sm.Columns(
+			cols.DeviceID,
+			cols.ProductSku,
+			cols.QualityStatus,
+			psql.Raw("count(*)").As("events"),
+			psql.Raw("sum(unit_count)").As("units"),
+			psql.Raw("min(time)").As("first"),
+			psql.Raw("max(time)").As("last"),
+		),
+		sm.From(models.IotProductionEvents.NameAs()),
+		models.SelectWhere.IotProductionEvents.BatchID.EQ(batchID),
+		sm.GroupBy(cols.DeviceID),
+		sm.GroupBy(cols.ProductSku),
+		sm.GroupBy(cols.QualityStatus),
+		sm.OrderBy(cols.DeviceID),
+	)
+}
+
+// mergeProductionBatch sums the groups of a batch, ordered by device, into
+// the batch and the share of each device.
+func mergeProductionBatch(batchID string, groups []productionGroup) ProductionBatch {
+	batch := ProductionBatch{
+		BatchID: batchID,
+		Quality: make(map[string]int64),
+	}
+	for _, g := range groups {
+		if !slices.Contains(batch.SKUs, g.ProductSku) {
+			batch.SKUs = append(batch.SKUs, g.ProductSku)
+		}
+		batch.First = earliest(batch.First, g.First)
+		batch.Last = latest(batch.Last, g.Last)
+		batch.Events += g.Events
+		batch.TotalUnits += g.Units
+		batch.Quality[g.QualityStatus] += g.Units
+
+		if n := len(batch.Devices); n == 0 || batch.Devices[n-1].DeviceID != g.DeviceID {
+			batch.Devices = append(batch.Devices, ProductionBatchDevice{
+				DeviceID: g.DeviceID,
+				Quality:  make(map[string]int64),
+			})
+		}
+		d := &batch.Devices[len(batch.Devices)-1]
+		d.First = earliest(d.First, g.First)
+		d.Last = latest(d.Last, g.Last)
+		d.Events += g.Events
+		d.Units += g.Units
+		d.Quality[g.QualityStatus] += g.Units
+	}
+	slices.Sort(batch.SKUs)
+	return batch
+}
+
+// ProductionPeriod is the period production is rolled up by.
+type ProductionPeriod string
+
+const (
+	ProductionByDay   ProductionPeriod = "day"
+	ProductionByShift ProductionPeriod = "shift"
+)
+
+// ParseProductionPeriod returns the period named s, ProductionByDay when
+// empty.
+func ParseProductionPeriod(s string) (ProductionPeriod, error) {
+	switch p := ProductionPeriod(s); p {
+	case "":
+		return ProductionByDay, nil
+	case ProductionByDay, ProductionByShift:
+		return p, nil
+	}
+	return "", fmt.Errorf("%w: unknown production period: %s", ErrInvalidQuery, s)
+}
+
+// Shifts splits the days, in UTC, into shifts of Length starting at Start
+// after midnight.
+type Shifts struct {
+	Start  time.Duration
+	Length time.Duration
+}
+
+// number returns the number of the shift starting at t, from 1 for the
+// shift starting at Start.
+func (s Shifts) number(t time.Time) int {
+	sinceMidnight := t.Sub(t.Truncate(24 * time.Hour))
+	sinceStart := (sinceMidnight - s.Start + 24*time.Hour) % (24 * time.Hour)
+	return int(sinceStart/s.Length) + 1
+}
+
+// ProductionRollupQuery sums the production of every SKU of every device by
+// period. Its empty filters do not restrict the selection.
+type ProductionRollupQuery struct {
+	Range    TimeRange
+	Period   ProductionPeriod
+	DeviceID string
+	SKU      string
+}
+
+// ProductionRollup is the production of a SKU by a device over a period.
+type ProductionRollup struct {
+	PeriodStart time.Time
+	// Shift numbers the shift of the day rolled up by shift, zero otherwise.
+	Shift    int
+	DeviceID string
+	SKU      string
+	Events   int64
+	Units    int64
+	// Quality counts the units by quality status.
+	Quality map[string]int64
+}
+
+type ProductionRollupQueryHandler struct {
+	db     bobpgx.Pool
+	shifts Shifts
+}
+
+func NewProductionRollupQueryHandler(db bobpgx.Pool, shifts Shifts) *ProductionRollupQueryHandler {
+	return &ProductionRollupQueryHandler{
+		db:     db,
+		shifts: shifts,
+	}
+}
+
+func (h ProductionRollupQueryHandler) Handle(ctx context.Context, q ProductionRollupQuery) ([]ProductionRollup, error) {
+	if err := q.Range.validate(); err != nil {
+		return nil, err
+	}
+
+	rows, err := bob.All(ctx, h.db, h.build(q), scan.StructMapper[productionRollupRow]())
+	if err != nil {
+		return nil, err
+	}
+	return h.rollup(q.Period, rows), nil
+}
+
+// productionRollupRow is the production of a SKU by a device over a period
+// for a quality status.
+type productionRollupRow struct {
+	Period        time.Time `db:"period"`
+	DeviceID      string    `db:"device_id"`
+	ProductSku    string    `db:"product_sku"`
+	QualityStatus string    `db:"quality_status"`
+	Events        int64     `db:"events"`
+	Units         int64     `db:"units"`
+}
+
+// rollup merges the rows of the quality statuses of a SKU by a device over a
+// period. Rows are ordered by period, device and SKU.
+func (h ProductionRollupQueryHandler) rollup(period ProductionPeriod, rows []productionRollupRow) []ProductionRollup {
+	var rollups []ProductionRollup
+	for _, row := range rows {
+		n := len(rollups)
+		if n == 0 || !rollups[n-1].PeriodStart.Equal(row.Period) || rollups[n-1].DeviceID != row.DeviceID || rollups[n-1].SKU != row.ProductSku {
+			r := ProductionRollup{
+				PeriodStart: row.Period,
+				DeviceID:    row.DeviceID,
+				SKU:         row.ProductSku,
+				Quality:     make(map[string]int64),
+			}
+			if period == ProductionByShift {
+				r.Shift = h.shifts.number(row.Period)
+			}
+			rollups = append(rollups, r)
+		}
+		r := &rollups[len(rollups)-1]
+		r.Events += row.Events
+		r.Units += row.Units
+		r.Quality[row.QualityStatus] += row.Units
+	}
+	return rollups
+}
+
+// build returns the query summing the production by period with TimescaleDB
+// time_bucket, shifted to the start of the first shift for shifts.
+func (h ProductionRollupQueryHandler) build(q ProductionRollupQuery) bob.BaseQuery[*dialect.SelectQuery] {
+	period := psql.Raw("time_bucket('1 day', time)")
+	if q.Period == ProductionByShift {
+		period = psql.Raw("time_bucket(?::interval, time, ?::interval)",
+			fmt.Sprintf("%d milliseconds", h.shifts.Length.Milliseconds()),
+			fmt.Sprintf("%d milliseconds", h.shifts.Start.Milliseconds()))
+	}
+
+	cols := models.IotProductionEvents.Columns
+	where := models.SelectWhere.IotProductionEvents
+	mods := []bob.Mod[*dialect.SelectQuery]{
+		sm.Columns(
+			period.As("period"),
+			cols.DeviceID,
+			cols.ProductSku,
+			cols.QualityStatus,
+			psql.Raw("count(*)").As("events"),
+			psql.Raw("sum(unit_count)").As("units"),
+		),
+		sm.From(models.IotProductionEvents.NameAs()),
+		where.Time.GTE(q.Range.From),
+		where.Time.LT(q.Range.To),
+		sm.GroupBy(psql.Quote("period")),
+		sm.GroupBy(cols.DeviceID),
+		sm.GroupBy(cols.ProductSku),
+		sm.GroupBy(cols.QualityStatus),
+		sm.OrderBy(psql.Quote("period")),
+		sm.OrderBy(cols.DeviceID),
+		sm.OrderBy(cols.ProductSku),
+	}
+	if q.DeviceID != "" {
+		mods = append(mods, where.DeviceID.EQ(q.DeviceID))
+	}
+	if q.SKU != "" {
+		mods = append(mods, where.ProductSku.EQ(q.SKU))
+	}
+	return psql.Select(mods...)
+}
+
+func earliest(a, b time.Time) time.Time {
+	if a.IsZero() || b.Before(a) {
+		return b
+	}
+	return a
+}
+
+func latest(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
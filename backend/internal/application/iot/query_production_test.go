@@ -0,0 +1,194 @@
+package application_iot
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShiftsNumber(t *testing.T) {
+	day := time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		name   string
+		shifts Shifts
+		at     time.Duration
+		want   int
+	}{
+		{"morning", Shifts{Start: 6 * time.Hour, Length: 8 * time.Hour}, 6 * time.Hour, 1},
+		{"afternoon", Shifts{Start: 6 * time.Hour, Length: 8 * time.Hour}, 14 * time.Hour, 2},
+		{"night", Shifts{Start: 6 * time.Hour, Length: 8 * time.Hour}, 22 * time.Hour, 3},
+		// The night shift started the day before.
+		{"night of the day before", Shifts{Start: 6 * time.Hour, Length: 8 * time.Hour}, -2 * time.Hour, 3},
+		// The first shift starts before midnight and ends after it.
+		{"first before midnight", Shifts{Start: 22 * time.Hour, Length: 8 * time.Hour}, 22 * time.Hour, 1},
+		{"first after midnight", Shifts{Start: 22 * time.Hour, Length: 8 * time.Hour}, 2 * time.Hour, 1},
+		{"second", Shifts{Start: 22 * time.Hour, Length: 8 * time.Hour}, 6 * time.Hour, 2},
+		{"third", Shifts{Start: 22 * time.Hour, Length: 8 * time.Hour}, 14 * time.Hour, 3},
+		{"twelve hours", Shifts{Start: 19 * time.Hour, Length: 12 * time.Hour}, 7 * time.Hour, 2},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.shifts.number(day.Add(tt.at)); got != tt.want {
+				t.Errorf("shift at %s: got %d, want %d", day.Add(tt.at), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeProductionBatch(t *testing.T) {
+	start := time.Date(2026, 7, 1, 6, 0, 0, 0, time.UTC)
+	at := func(h int) time.Time { return start.Add(time.Duration(h) * time.Hour) }
+
+	for _, tt := range []struct {
+		name   string
+		groups []productionGroup
+		want   ProductionBatch
+	}{
+		{
+			name: "single device",
+			groups: []productionGroup{
+				{DeviceID: "press-1", ProductSku: "SKU-A", QualityStatus: "pass", Events: 3, Units: 30, First: at(0), Last: at(2)},
+			},
+			want: ProductionBatch{
+				BatchID: "B-1", SKUs: []string{"SKU-A"}, First: at(0), Last: at(2), Events: 3, TotalUnits: 30,
+				Quality: map[string]int64{"pass": 30},
+				Devices: []ProductionBatchDevice{
+					{DeviceID: "press-1", First: at(0), Last: at(2), Events: 3, Units: 30, Quality: map[string]int64{"pass": 30}},
+				},
+			},
+		},
+		{
+			name: "devices, SKUs and quality statuses",
+			groups: []productionGroup{
+				{DeviceID: "press-1", ProductSku: "SKU-B", QualityStatus: "pass", Events: 2, Units: 20, First: at(1), Last: at(3)},
+				{DeviceID: "press-1", ProductSku: "SKU-B", QualityStatus: "fail", Events: 1, Units: 2, First: at(0), Last: at(0)},
+				{DeviceID: "press-1", ProductSku: "SKU-A", QualityStatus: "pass", Events: 1, Units: 5, First: at(4), Last: at(4)},
+				{DeviceID: "press-2", ProductSku: "SKU-B", QualityStatus: "pass", Events: 4, Units: 40, First: at(2), Last: at(6)},
+				{DeviceID: "press-2", ProductSku: "SKU-B", QualityStatus: "rework", Events: 1, Units: 3, First: at(5), Last: at(5)},
+			},
+			want: ProductionBatch{
+				BatchID: "B-1", SKUs: []string{"SKU-A", "SKU-B"}, First: at(0), Last: at(6), Events: 9, TotalUnits: 70,
+				Quality: map[string]int64{"pass": 65, "fail": 2, "rework": 3},
+				Devices: []ProductionBatchDevice{
+					{DeviceID: "press-1", First: at(0), Last: at(4), Events: 4, Units: 27, Quality: map[string]int64{"pass": 25, "fail": 2}},
+					{DeviceID: "press-2", First: at(2), Last: at(6), Events: 5, Units: 43, Quality: map[string]int64{"pass": 40, "rework": 3}},
+				},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeProductionBatch("B-1", tt.groups); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProductionBatchQuery(t *testing.T) {
+	sql, args, err := ProductionBatchQueryHandler{}.build("B-1").Build(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"WHERE (\"iot_production_events\".\"batch_id\" = $1)",
+		"GROUP BY \"iot_production_events\".\"device_id\", \"iot_production_events\".\"product_sku\", \"iot_production_events\".\"quality_status\"",
+		"ORDER BY \"iot_production_events\".\"device_id\"",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("query does not contain %s:\n%s", want, sql)
+		}
+	}
+	if len(args) != 1 || args[0] != "B-1" {
+		t.Errorf("arguments %v", args)
+	}
+}
+
+func TestProductionRollup(t *testing.T) {
+	// Night shifts start before midnight.
+	h := ProductionRollupQueryHandler{shifts: Shifts{Start: 22 * time.Hour, Length: 8 * time.Hour}}
+	night := time.Date(2026, 6, 30, 22, 0, 0, 0, time.UTC)
+	morning := night.Add(8 * time.Hour)
+	rows := []productionRollupRow{
+		{Period: night, DeviceID: "press-1", ProductSku: "SKU-A", QualityStatus: "fail", Events: 1, Units: 2},
+		{Period: night, DeviceID: "press-1", ProductSku: "SKU-A", QualityStatus: "pass", Events: 3, Units: 30},
+		{Period: night, DeviceID: "press-1", ProductSku: "SKU-B", QualityStatus: "pass", Events: 1, Units: 10},
+		{Period: night, DeviceID: "press-2", ProductSku: "SKU-A", QualityStatus: "pass", Events: 2, Units: 20},
+		{Period: morning, DeviceID: "press-1", ProductSku: "SKU-A", QualityStatus: "pass", Events: 4, Units: 40},
+		{Period: morning, DeviceID: "press-1", ProductSku: "SKU-A", QualityStatus: "rework", Events: 1, Units: 1},
+	}
+
+	for _, tt := range []struct {
+		period ProductionPeriod
+		shifts [2]int
+	}{
+		{ProductionByShift, [2]int{1, 2}},
+		{ProductionByDay, [2]int{0, 0}},
+	} {
+		t.Run(string(tt.period), func(t *testing.T) {
+			want := []ProductionRollup{
+				{PeriodStart: night, Shift: tt.shifts[0], DeviceID: "press-1", SKU: "SKU-A", Events: 4, Units: 32, Quality: map[string]int64{"fail": 2, "pass": 30}},
+				{PeriodStart: night, Shift: tt.shifts[0], DeviceID: "press-1", SKU: "SKU-B", Events: 1, Units: 10, Quality: map[string]int64{"pass": 10}},
+				{PeriodStart: night, Shift: tt.shifts[0], DeviceID: "press-2", SKU: "SKU-A", Events: 2, Units: 20, Quality: map[string]int64{"pass": 20}},
+				{PeriodStart: morning, Shift: tt.shifts[1], DeviceID: "press-1", SKU: "SKU-A", Events: 5, Units: 41, Quality: map[string]int64{"pass": 40, "rework": 1}},
+			}
+			if got := h.rollup(tt.period, rows); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+
+	if got := h.rollup(ProductionByDay, nil); got != nil {
+		t.Errorf("got %+v without rows", got)
+	}
+}
+
+func TestProductionRollupQuery(t *testing.T) {
+	from := time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+	h := ProductionRollupQueryHandler{shifts: Shifts{Start: 6 * time.Hour, Length: 8 * time.Hour}}
+	q := ProductionRollupQuery{
+		Range:    TimeRange{From: from, To: from.Add(7 * 24 * time.Hour)},
+		Period:   ProductionByShift,
+		DeviceID: "press-1",
+	}
+
+	sql, args, err := h.build(q).Build(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"time_bucket($1::interval, time, $2::interval) AS \"period\"",
+		"sum(unit_count) AS \"units\"",
+		"GROUP BY \"period\"",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("query does not contain %s:\n%s", want, sql)
+		}
+	}
+	if args[0] != "28800000 milliseconds" || args[1] != "21600000 milliseconds" {
+		t.Errorf("shift intervals %v", args[:2])
+	}
+
+	q.Period = ProductionByDay
+	sql, _, err = h.build(q).Build(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(sql, "time_bucket('1 day', time) AS \"period\"") {
+		t.Errorf("query does not bucket by day:\n%s", sql)
+	}
+
+	// Shifts starting before midnight offset the buckets by their start.
+	h.shifts = Shifts{Start: 22 * time.Hour, Length: 8 * time.Hour}
+	q.Period = ProductionByShift
+	_, args, err = h.build(q).Build(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args[0] != "28800000 milliseconds" || args[1] != "79200000 milliseconds" {
+		t.Errorf("shift intervals %v", args[:2])
+	}
+	if len(args) != 5 || args[2] != q.Range.From || args[3] != q.Range.To || args[4] != "press-1" {
+		t.Errorf("arguments %v", args)
+	}
+}
@@ -0,0 +1,262 @@
+package application_iot
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"iiot_system/backend/gen/models"
+
+	"github.com/stephenafamo/bob"
+	"github.com/stephenafamo/bob/dialect/psql/dialect"
+	"github.com/stephenafamo/bob/dialect/psql/sm"
+	bobpgx "github.com/stephenafamo/bob/drivers/pgx"
+)
+
+// StatusInterval is a stretch of time a device spent in a single status.
+type StatusInterval struct {
+	Status   string
+	From     time.Time
+	To       time.Time
+	Duration time.Duration
+}
+
+// StatusTimelineQuery selects the status intervals of a device.
+type StatusTimelineQuery struct {
+	DeviceID string
+	Range    TimeRange
+}
+
+// StatusTimeline is the contiguous status intervals of a device, clipped to
+// the range queried and to the present. The timeline starts at the first
+// status known within the range, so it may not cover all of it.
+type StatusTimeline struct {
+	DeviceID  string
+	Range     TimeRange
+	Intervals []StatusInterval
+}
+
+type StatusTimelineQueryHandler struct {
+	db       bobpgx.Pool
+	lookback time.Duration
+}
+
+// NewStatusTimelineQueryHandler returns a handler searching the status a
+// device starts a range in up to lookback before it.
+func NewStatusTimelineQueryHandler(db bobpgx.Pool, lookback time.Duration) *StatusTimelineQueryHandler {
+	return &StatusTimelineQueryHandler{
+		db:       db,
+		lookback: lookback,
+	}
+}
+
+func (h StatusTimelineQueryHandler) Handle(ctx context.Context, q StatusTimelineQuery) (StatusTimeline, error) {
+	if err := q.Range.validate(); err != nil {
+		return StatusTimeline{}, err
+	}
+
+	seeds, events, err := statusEvents(ctx, h.db, q.Range, q.DeviceID, h.lookback)
+	if err != nil {
+		return StatusTimeline{}, err
+	}
+
+	return StatusTimeline{
+		DeviceID:  q.DeviceID,
+		Range:     q.Range,
+		Intervals: statusIntervals(q.Range.From, statusEnd(q.Range), seeds[q.DeviceID], events[q.DeviceID]),
+	}, nil
+}
+
+// StatusSummaryQuery summarizes the time spent in each status by every
+// device over a period.
+type StatusSummaryQuery struct {
+	Range TimeRange
+}
+
+// StatusSummary is the time a device spent in each status over a period,
+// up to the present. Percent is relative to the whole period, so the
+// percentages add up to less than 100 when the status of the device is not
+// known for part of it.
+type StatusSummary struct {
+	DeviceID  string
+	Observed  time.Duration
+	Durations map[string]time.Duration
+	Percent   map[string]float64
+}
+
+type StatusSummaryQueryHandler struct {
+	db       bobpgx.Pool
+	lookback time.Duration
+}
+
+// NewStatusSummaryQueryHandler returns a handler searching the status the
+// devices start a range in up to lookback before it.
+func NewStatusSummaryQueryHandler(db bobpgx.Pool, lookback time.Duration) *StatusSummaryQueryHandler {
+	return &StatusSummaryQueryHandler{
+		db:       db,
+		lookback: lookback,
+	}
+}
+
+// Handle returns the summaries of the devices whose status is known within
+// the range, ordered by device.
+func (h StatusSummaryQueryHandler) Handle(ctx context.Context, q StatusSummaryQuery) ([]StatusSummary, error) {
+	if err := q.Range.validate(); err != nil {
+		return nil, err
+	}
+
+	seeds, events, err := statusEvents(ctx, h.db, q.Range, "", h.lookback)
+	if err != nil {
+		return nil, err
+	}
+
+	devices := make([]string, 0, len(seeds)+len(events))
+	for id := range seeds {
+		devices = append(devices, id)
+	}
+	for id := range events {
+		if _, ok := seeds[id]; !ok {
+			devices = append(devices, id)
+		}
+	}
+	slices.Sort(devices)
+
+	end := statusEnd(q.Range)
+	summaries := make([]StatusSummary, 0, len(devices))
+	for _, id := range devices {
+		summaries = append(summaries, summarizeStatus(id, end.Sub(q.Range.From),
+			statusIntervals(q.Range.From, end, seeds[id], events[id])))
+	}
+	return summaries, nil
+}
+
+// statusEvents returns, by device, the last status event within lookback
+// before the range and the status events within it, oldest first. An empty
+// deviceID selects every device.
+func statusEvents(ctx context.Context, db bobpgx.Pool, r TimeRange, deviceID string, lookback time.Duration) (map[string]*models.IotStatusEvent, map[string]models.IotStatusEventSlice, error) {
+	last, err := statusSeedQuery(r, deviceID, lookback).All(ctx, db)
+	if err != nil {
+		return nil, nil, err
+	}
+	seeds := make(map[string]*models.IotStatusEvent, len(last))
+	for _, e := range last {
+		seeds[e.DeviceID] = e
+	}
+
+	cols := models.IotStatusEvents.Columns
+	where := models.SelectWhere.IotStatusEvents
+	within, err := models.IotStatusEvents.Query(append(statusDeviceMods(deviceID),
+		where.Time.GTE(r.From),
+		where.Time.LT(r.To),
+		sm.OrderBy(cols.DeviceID),
+		sm.OrderBy(cols.Time),
+	)...).All(ctx, db)
+	if err != nil {
+		return nil, nil, err
+	}
+	events := make(map[string]models.IotStatusEventSlice)
+	for _, e := range within {
+		events[e.DeviceID] = append(events[e.DeviceID], e)
+	}
+	return seeds, events, nil
+}
+
+// statusSeedQuery returns the query selecting the last status event of each
+// device before the range, which tells the status the range starts in. The
+// search is bounded to lookback before the range so that TimescaleDB only
+// scans the chunks within it.
+func statusSeedQuery(r TimeRange, deviceID string, lookback time.Duration) models.IotStatusEventsQuery {
+	cols := models.IotStatusEvents.Columns
+	where := models.SelectWhere.IotStatusEvents
+	return models.IotStatusEvents.Query(append(statusDeviceMods(deviceID),
+		sm.Distinct(cols.DeviceID),
+		where.Time.GTE(r.From.Add(-lookback)),
+		where.Time.LT(r.From),
+		sm.OrderBy(cols.DeviceID),
+		sm.OrderBy(cols.Time).Desc(),
+	)...)
+}
+
+// statusDeviceMods selects the events of deviceID, or of every device if
+// empty.
+func statusDeviceMods(deviceID string) []bob.Mod[*dialect.SelectQuery] {
+	if deviceID == "" {
+		return nil
+	}
+	return []bob.Mod[*dialect.SelectQuery]{models.SelectWhere.IotStatusEvents.DeviceID.EQ(deviceID)}
+}
+
+// statusEnd returns the end of the range, or the present if it is earlier.
+func statusEnd(r TimeRange) time.Time {
+	if now := time.Now(); now.Before(r.To) {
+		return now
+	}
+	return r.To
+}
+
+// statusIntervals returns the contiguous status intervals from start to end
+// given the last event before start, if any, and the events in between,
+// oldest first. Without a previous event, the status before the first event
+// is its old status. Transitions to the current status extend its interval.
+func statusIntervals(start, end time.Time, seed *models.IotStatusEvent, events models.IotStatusEventSlice) []StatusInterval {
+	if !start.Before(end) {
+		return nil
+	}
+
+	var intervals []StatusInterval
+	extend := func(status string, from time.Time) {
+		// A transition at the very start of an interval replaces it.
+		if n := len(intervals); n > 0 && intervals[n-1].From.Equal(from) {
+			intervals = intervals[:n-1]
+		}
+		if n := len(intervals); n > 0 {
+			last := &intervals[n-1]
+			if last.Status == status {
+				return
+			}
+			last.To = from
+			last.Duration = from.Sub(last.From)
+		}
+		intervals = append(intervals, StatusInterval{Status: status, From: from})
+	}
+
+	switch {
+	case seed != nil:
+		extend(seed.NewStatus, start)
+	case len(events) > 0 && events[0].Time.After(start):
+		extend(events[0].OldStatus, start)
+	}
+	for _, e := range events {
+		if !e.Time.Before(end) {
+			break
+		}
+		extend(e.NewStatus, e.Time)
+	}
+
+	if n := len(intervals); n > 0 {
+		last := &intervals[n-1]
+		last.To = end
+		last.Duration = end.Sub(last.From)
+	}
+	return intervals
+}
+
+// summarizeStatus sums the intervals of a device by status over a period of
+// the given length.
+func summarizeStatus(deviceID string, period time.Duration, intervals []StatusInterval) StatusSummary {
+	s := StatusSummary{
+		DeviceID:  deviceID,
+		Durations: make(map[string]time.Duration),
+		Percent:   make(map[string]float64),
+	}
+	for _, i := range intervals {
+		s.Observed += i.Duration
+		s.Durations[i.Status] += i.Duration
+	}
+	if period > 0 {
+		for status, d := range s.Durations {
+			s.Percent[status] = 100 * float64(d) / float64(period)
+		}
+	}
+	return s
+}
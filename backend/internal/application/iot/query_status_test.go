@@ -0,0 +1,115 @@
+package application_iot
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"iiot_system/backend/gen/models"
+)
+
+func TestStatusIntervals(t *testing.T) {
+	start := time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(10 * time.Hour)
+	at := func(h int) time.Time { return start.Add(time.Duration(h) * time.Hour) }
+	event := func(h int, from, to string) *models.IotStatusEvent {
+		return &models.IotStatusEvent{Time: at(h), OldStatus: from, NewStatus: to}
+	}
+	interval := func(status string, from, to int) StatusInterval {
+		return StatusInterval{Status: status, From: at(from), To: at(to), Duration: at(to).Sub(at(from))}
+	}
+
+	for _, tt := range []struct {
+		name   string
+		seed   *models.IotStatusEvent
+		events models.IotStatusEventSlice
+		want   []StatusInterval
+	}{
+		{
+			name: "unknown",
+		},
+		{
+			name: "steady",
+			seed: event(-5, "idle", "running"),
+			want: []StatusInterval{interval("running", 0, 10)},
+		},
+		{
+			name:   "seeded",
+			seed:   event(-5, "idle", "running"),
+			events: models.IotStatusEventSlice{event(2, "running", "fault"), event(3, "fault", "maintenance"), event(6, "maintenance", "running")},
+			want:   []StatusInterval{interval("running", 0, 2), interval("fault", 2, 3), interval("maintenance", 3, 6), interval("running", 6, 10)},
+		},
+		{
+			name:   "old status before the first event",
+			events: models.IotStatusEventSlice{event(4, "idle", "running")},
+			want:   []StatusInterval{interval("idle", 0, 4), interval("running", 4, 10)},
+		},
+		{
+			name:   "repeated and simultaneous transitions",
+			seed:   event(-1, "idle", "running"),
+			events: models.IotStatusEventSlice{event(0, "running", "fault"), event(5, "fault", "fault"), event(5, "fault", "idle")},
+			want:   []StatusInterval{interval("fault", 0, 5), interval("idle", 5, 10)},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := statusIntervals(start, end, tt.seed, tt.events); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if got := statusIntervals(end, start, event(-1, "idle", "running"), nil); got != nil {
+		t.Errorf("got %+v for an empty window", got)
+	}
+}
+
+func TestSummarizeStatus(t *testing.T) {
+	start := time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+	s := summarizeStatus("press-1", 10*time.Hour, []StatusInterval{
+		{Status: "idle", From: start.Add(2 * time.Hour), Duration: 2 * time.Hour},
+		{Status: "running", From: start.Add(4 * time.Hour), Duration: 3 * time.Hour},
+		{Status: "idle", From: start.Add(7 * time.Hour), Duration: 3 * time.Hour},
+	})
+	if s.Observed != 8*time.Hour || s.Durations["idle"] != 5*time.Hour {
+		t.Fatalf("got %+v", s)
+	}
+	if s.Percent["idle"] != 50 || s.Percent["running"] != 30 {
+		t.Fatalf("percentages %v", s.Percent)
+	}
+}
+
+func TestStatusSeedQuery(t *testing.T) {
+	from := time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+	r := TimeRange{From: from, To: from.Add(24 * time.Hour)}
+
+	for _, tt := range []struct {
+		name     string
+		deviceID string
+		args     []any
+	}{
+		{"every device", "", []any{from.Add(-72 * time.Hour), from}},
+		{"device", "press-1", []any{"press-1", from.Add(-72 * time.Hour), from}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, args, err := statusSeedQuery(r, tt.deviceID, 72*time.Hour).Build(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range []string{
+				`SELECT DISTINCT ON ("iot_status_events"."device_id")`,
+				`("iot_status_events"."time" >= $`,
+				`("iot_status_events"."time" < $`,
+				`ORDER BY "iot_status_events"."device_id", "iot_status_events"."time" DESC`,
+			} {
+				if !strings.Contains(sql, want) {
+					t.Errorf("query does not contain %s:\n%s", want, sql)
+				}
+			}
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("arguments %v, want %v", args, tt.args)
+			}
+		})
+	}
+}
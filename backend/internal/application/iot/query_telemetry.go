@@ -0,0 +1,149 @@
+package application_iot
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"iiot_system/backend/gen/models"
+
+	"github.com/stephenafamo/bob"
+	"github.com/stephenafamo/bob/dialect/psql"
+	"github.com/stephenafamo/bob/dialect/psql/dialect"
+	"github.com/stephenafamo/bob/dialect/psql/sm"
+	bobpgx "github.com/stephenafamo/bob/drivers/pgx"
+)
+
+// TelemetryField is a measurement of the telemetry events.
+type TelemetryField string
+
+const (
+	TelemetryTemperature   TelemetryField = "temperature_celcius"
+	TelemetryHumidity      TelemetryField = "humidity_percent"
+	TelemetryVibration     TelemetryField = "vibration_hz"
+	TelemetryMotorRPM      TelemetryField = "motor_rpm"
+	TelemetryCurrent       TelemetryField = "current_amps"
+	TelemetryMachineStatus TelemetryField = "machine_status"
+	TelemetryErrorCode     TelemetryField = "error_code"
+)
+
+// TelemetryFields are every field of the telemetry events, in column order.
+var TelemetryFields = []TelemetryField{
+	TelemetryTemperature,
+	TelemetryHumidity,
+	TelemetryVibration,
+	TelemetryMotorRPM,
+	TelemetryCurrent,
+	TelemetryMachineStatus,
+	TelemetryErrorCode,
+}
+
+// ParseTelemetryField returns the field named s.
+func ParseTelemetryField(s string) (TelemetryField, error) {
+	for _, f := range TelemetryFields {
+		if string(f) == s {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("%w: unknown telemetry field: %s", ErrInvalidQuery, s)
+}
+
+// Value returns the value of f in e, with numeric measurements as float64.
+func (f TelemetryField) Value(e *models.IotTelemetryEvent) any {
+	switch f {
+	case TelemetryTemperature:
+		return e.TemperatureCelcius.InexactFloat64()
+	case TelemetryHumidity:
+		return e.HumidityPercent.InexactFloat64()
+	case TelemetryVibration:
+		return e.VibrationHZ.InexactFloat64()
+	case TelemetryMotorRPM:
+		return e.MotorRPM
+	case TelemetryCurrent:
+		return e.CurrentAmps.InexactFloat64()
+	case TelemetryMachineStatus:
+		return e.MachineStatus
+	case TelemetryErrorCode:
+		return e.ErrorCode.Ptr()
+	}
+	return nil
+}
+
+// TelemetryQuery selects the telemetry events of a device, oldest first.
+type TelemetryQuery struct {
+	DeviceID string
+	Range    TimeRange
+	// Fields are the measurements returned besides the time, every one when
+	// empty.
+	Fields []TelemetryField
+	Limit  int
+	// After resumes a previous page from the time of its last event. Events
+	// are unique per device and time, so no event is skipped or repeated.
+	After time.Time
+}
+
+// TelemetryPage is a page of the events selected by a TelemetryQuery.
+type TelemetryPage struct {
+	Events models.IotTelemetryEventSlice
+	Fields []TelemetryField
+	// Next is the After of the following page, zero on the last page.
+	Next time.Time
+}
+
+type TelemetryQueryHandler struct {
+	db bobpgx.Pool
+}
+
+func NewTelemetryQueryHandler(db bobpgx.Pool) *TelemetryQueryHandler {
+	return &TelemetryQueryHandler{
+		db: db,
+	}
+}
+
+func (h TelemetryQueryHandler) Handle(ctx context.Context, q TelemetryQuery) (TelemetryPage, error) {
+	if q.DeviceID == "" {
+		return TelemetryPage{}, fmt.Errorf("%w: the device id is required", ErrInvalidQuery)
+	}
+	if err := q.Range.validate(); err != nil {
+		return TelemetryPage{}, err
+	}
+	limit, err := queryLimit(q.Limit)
+	if err != nil {
+		return TelemetryPage{}, err
+	}
+
+	fields := q.Fields
+	if len(fields) == 0 {
+		fields = TelemetryFields
+	}
+	columns := []any{models.IotTelemetryEvents.Columns.Time}
+	for _, f := range fields {
+		columns = append(columns, psql.Quote(models.IotTelemetryEvents.Columns.Alias(), string(f)))
+	}
+
+	where := models.SelectWhere.IotTelemetryEvents
+	mods := []bob.Mod[*dialect.SelectQuery]{
+		sm.Columns(columns...),
+		where.DeviceID.EQ(q.DeviceID),
+		where.Time.GTE(q.Range.From),
+		where.Time.LT(q.Range.To),
+		sm.OrderBy(models.IotTelemetryEvents.Columns.Time).Asc(),
+		// One more event than the limit tells whether another page follows.
+		sm.Limit(limit + 1),
+	}
+	if !q.After.IsZero() {
+		mods = append(mods, where.Time.GT(q.After))
+	}
+
+	events, err := models.IotTelemetryEvents.Query(mods...).All(ctx, h.db)
+	if err != nil {
+		return TelemetryPage{}, err
+	}
+
+	page := TelemetryPage{Events: events, Fields: fields}
+	if len(events) > limit {
+		page.Events = events[:limit]
+		page.Next = page.Events[limit-1].Time
+	}
+	return page, nil
+}
@@ -0,0 +1,88 @@
+package application_iot
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	bobpgx "github.com/stephenafamo/bob/drivers/pgx"
+)
+
+// Event hypertables, besides telemetryTable.
+const (
+	alertsTable       = "iot_alert_events"
+	productionTable   = "iot_production_events"
+	statusUpdateTable = "iot_status_events"
+)
+
+// RetentionPolicy keeps the events of a hypertable for Interval.
+type RetentionPolicy struct {
+	Table    string
+	Interval time.Duration
+}
+
+// EventRetentionPolicies returns the retention policies of the event tables.
+func EventRetentionPolicies(telemetry, alerts, production, statusUpdates time.Duration) []RetentionPolicy {
+	return []RetentionPolicy{
+		{Table: telemetryTable, Interval: telemetry},
+		{Table: alertsTable, Interval: alerts},
+		{Table: productionTable, Interval: production},
+		{Table: statusUpdateTable, Interval: statusUpdates},
+	}
+}
+
+// ApplyRetentionPolicies replaces the TimescaleDB retention policies of the
+// tables of policies and returns the tables whose policy changed. Policies
+// with a zero interval are skipped, keeping the policy created by the
+// migrations, and so are tables whose policy already drops events after the
+// same interval.
+func ApplyRetentionPolicies(ctx context.Context, db bobpgx.Pool, policies ...RetentionPolicy) ([]string, error) {
+	var changed []string
+	for _, p := range policies {
+		if p.Interval <= 0 {
+			continue
+		}
+
+		ok, err := applyRetentionPolicy(ctx, db, p)
+		if err != nil {
+			return changed, fmt.Errorf("unable to set the retention policy of %s: %w", p.Table, err)
+		}
+		if ok {
+			changed = append(changed, p.Table)
+		}
+	}
+	return changed, nil
+}
+
+func applyRetentionPolicy(ctx context.Context, db bobpgx.Pool, p RetentionPolicy) (bool, error) {
+	t, err := db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return false, err
+	}
+	defer t.Rollback(ctx)
+
+	seconds := int64(p.Interval / time.Second)
+	// Concurrent runs replace the policy of a table one after the other.
+	if _, err := t.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext('retention_policy:' || $1))", p.Table); err != nil {
+		return false, err
+	}
+	var current bool
+	err = t.QueryRow(ctx, `SELECT EXISTS (
+		SELECT FROM timescaledb_information.jobs
+		WHERE proc_name = 'policy_retention' AND hypertable_name = $1
+			AND (config->>'drop_after')::interval = $2 * INTERVAL '1 second'
+	)`, p.Table, seconds).Scan(&current)
+	if err != nil || current {
+		return false, err
+	}
+
+	if _, err := t.ExecContext(ctx, "SELECT remove_retention_policy($1, if_exists => true)", p.Table); err != nil {
+		return false, err
+	}
+	if _, err := t.ExecContext(ctx, "SELECT add_retention_policy($1, $2 * INTERVAL '1 second')", p.Table, seconds); err != nil {
+		return false, err
+	}
+
+	return true, t.Commit(ctx)
+}
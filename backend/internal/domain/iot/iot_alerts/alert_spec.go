@@ -0,0 +1,53 @@
+package iotalerts
+
+import (
+	"fmt"
+	"time"
+)
+
+// AlertSpec selects alerts. Its zero fields do not restrict the selection.
+type AlertSpec struct {
+	DeviceID   string
+	AlertTypes []AlertType
+	Severities []Severity
+	// From is inclusive, To exclusive.
+	From time.Time
+	To   time.Time
+	// Text is searched for in the messages, ignoring case.
+	Text string
+}
+
+// AlertSortField is what alerts are sorted by.
+type AlertSortField string
+
+const (
+	SortByTime      AlertSortField = "time"
+	SortBySeverity  AlertSortField = "severity"
+	SortByDeviceID  AlertSortField = "device_id"
+	SortByAlertType AlertSortField = "alert_type"
+)
+
+// AlertSortFieldFromString converts a string to an AlertSortField.
+func AlertSortFieldFromString(s string) (AlertSortField, error) {
+	switch f := AlertSortField(s); f {
+	case SortByTime, SortBySeverity, SortByDeviceID, SortByAlertType:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown sort field: %s", s)
+}
+
+// AlertPage selects a page of sorted alerts. Alerts sorted the same are
+// sorted by time, latest first.
+type AlertPage struct {
+	Limit      int
+	Offset     int
+	SortBy     AlertSortField
+	Descending bool
+}
+
+// AlertCounts counts alerts by severity and alert type.
+type AlertCounts struct {
+	Total      int64
+	BySeverity map[string]int64
+	ByType     map[string]int64
+}
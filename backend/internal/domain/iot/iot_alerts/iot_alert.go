@@ -10,5 +10,5 @@ type IotAlert struct {
 	AlertType    string
 	Severity     string
 	Message      string
-	CurrentValue int
+	CurrentValue *float64
 }
@@ -1,5 +1,10 @@
 package iotalerts
 
+import "context"
+
 type IotAlertRepository interface {
-	FindBySpec() []*IotAlert
+	// FindBySpec returns a page of the alerts selected by spec.
+	FindBySpec(ctx context.Context, spec AlertSpec, page AlertPage) ([]*IotAlert, error)
+	// CountBySpec counts the alerts selected by spec.
+	CountBySpec(ctx context.Context, spec AlertSpec) (AlertCounts, error)
 }
@@ -0,0 +1,49 @@
+package iotalerts
+
+import (
+	"fmt"
+)
+
+type Severity int
+
+// Use a slice to define the string representations in order.
+var severityNames = []string{
+	"LOW",
+	"MEDIUM",
+	"HIGH",
+	"CRITICAL",
+}
+
+// Define the enum values using iota.
+const (
+	LOW Severity = iota
+	MEDIUM
+	HIGH
+	CRITICAL
+)
+
+// The reverse map is generated automatically.
+var severityValues map[string]Severity
+
+func init() {
+	severityValues = make(map[string]Severity, len(severityNames))
+	for i, name := range severityNames {
+		severityValues[name] = Severity(i)
+	}
+}
+
+// String provides the string representation of the severity.
+func (s Severity) String() string {
+	if s >= 0 && int(s) < len(severityNames) {
+		return severityNames[s]
+	}
+	return "Unknown Severity"
+}
+
+// SeverityFromString converts a string to a Severity.
+func SeverityFromString(s string) (Severity, error) {
+	if sev, ok := severityValues[s]; ok {
+		return sev, nil
+	}
+	return -1, fmt.Errorf("unknown severity: %s", s)
+}
@@ -0,0 +1,49 @@
+package domain_iot_telemetry
+
+import (
+	"fmt"
+)
+
+type MachineStatus int
+
+// Use a slice to define the string representations in order.
+var machineStatusNames = []string{
+	"running",
+	"idle",
+	"fault",
+	"maintenance",
+}
+
+// Define the enum values using iota.
+const (
+	Running MachineStatus = iota
+	Idle
+	Fault
+	Maintenance
+)
+
+// The reverse map is generated automatically.
+var machineStatusValues map[string]MachineStatus
+
+func init() {
+	machineStatusValues = make(map[string]MachineStatus, len(machineStatusNames))
+	for i, name := range machineStatusNames {
+		machineStatusValues[name] = MachineStatus(i)
+	}
+}
+
+// String provides the string representation of the machine status.
+func (ms MachineStatus) String() string {
+	if ms >= 0 && int(ms) < len(machineStatusNames) {
+		return machineStatusNames[ms]
+	}
+	return "Unknown Machine Status"
+}
+
+// MachineStatusFromString converts a string to a MachineStatus.
+func MachineStatusFromString(s string) (MachineStatus, error) {
+	if ms, ok := machineStatusValues[s]; ok {
+		return ms, nil
+	}
+	return -1, fmt.Errorf("unknown machine status: %s", s)
+}
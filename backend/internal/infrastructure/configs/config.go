@@ -1,50 +1,339 @@
 package configs
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"os"
-	"strconv"
+	"path/filepath"
 	"strings"
+	"time"
+
+	"iiot_system/backend/internal/infrastructure/topics"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Where consumed Kafka offsets are stored.
+const (
+	OffsetStorageKafka    = "kafka"
+	OffsetStorageDatabase = "database"
 )
 
+// SASL mechanisms supported to authenticate to Kafka.
+const (
+	SASLMechanismPlain       = "PLAIN"
+	SASLMechanismScramSHA256 = "SCRAM-SHA-256"
+	SASLMechanismScramSHA512 = "SCRAM-SHA-512"
+)
+
+// ConfigFileEnv names the environment variable holding the path of the
+// configuration file. Without it only defaults and environment variables are
+// used.
+const ConfigFileEnv = "CONFIG_FILE"
+
+// Config is the configuration of the backend. It is read from the defaults,
+// then an optional YAML or TOML file, then environment variables, each
+// overriding the previous one. The `env` tag of a field names its variable.
 type Config struct {
-	Port         int
-	DatabaseURL  string
-	KafkaBroker  string
-	KafkaTopics  []string
-	KafkaGroupID string
+	HTTP       HTTPConfig       `yaml:"http" toml:"http"`
+	Database   DatabaseConfig   `yaml:"database" toml:"database"`
+	Kafka      KafkaConfig      `yaml:"kafka" toml:"kafka"`
+	Ingestion  IngestionConfig  `yaml:"ingestion" toml:"ingestion"`
+	Retention  RetentionConfig  `yaml:"retention" toml:"retention"`
+	Production ProductionConfig `yaml:"production" toml:"production"`
+	Status     StatusConfig     `yaml:"status" toml:"status"`
+	Logging    LoggingConfig    `yaml:"logging" toml:"logging"`
+	Tracing    TracingConfig    `yaml:"tracing" toml:"tracing"`
+	Features   FeaturesConfig   `yaml:"features" toml:"features"`
 }
 
-func LoadConfig() *Config {
-	port, err := strconv.Atoi(os.Getenv("PORT"))
+type HTTPConfig struct {
+	Port         int           `yaml:"port" toml:"port" env:"PORT"`
+	ReadTimeout  time.Duration `yaml:"read_timeout" toml:"read_timeout" env:"HTTP_READ_TIMEOUT"`
+	WriteTimeout time.Duration `yaml:"write_timeout" toml:"write_timeout" env:"HTTP_WRITE_TIMEOUT"`
+	// ShutdownTimeout bounds how long in-flight requests are waited for on
+	// shutdown.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"HTTP_SHUTDOWN_TIMEOUT"`
+}
+
+// DatabaseConfig configures the PostgreSQL connection pool. Zero pool
+// settings keep the pgxpool defaults.
+type DatabaseConfig struct {
+	URL             string        `yaml:"url" toml:"url" env:"DATABASE_URL" secret:"url"`
+	MaxConns        int32         `yaml:"max_conns" toml:"max_conns" env:"DATABASE_MAX_CONNS"`
+	MinConns        int32         `yaml:"min_conns" toml:"min_conns" env:"DATABASE_MIN_CONNS"`
+	MaxConnLifetime time.Duration `yaml:"max_conn_lifetime" toml:"max_conn_lifetime" env:"DATABASE_MAX_CONN_LIFETIME"`
+	MaxConnIdleTime time.Duration `yaml:"max_conn_idle_time" toml:"max_conn_idle_time" env:"DATABASE_MAX_CONN_IDLE_TIME"`
+}
+
+type KafkaConfig struct {
+	Brokers []string `yaml:"brokers" toml:"brokers" env:"KAFKA_BROKER"`
+	GroupID string   `yaml:"group_id" toml:"group_id" env:"KAFKA_GROUP_ID"`
+	// Topics maps every pipeline to the topics it consumes.
+	Topics topics.Mapping `yaml:"topics" toml:"topics" env:"KAFKA_TOPICS"`
+	// OffsetStorage is where consumed offsets are stored: kafka commits them
+	// to the consumer group, database stores them with the events for
+	// exactly-once ingestion and commits nothing to the consumer group.
+	OffsetStorage string `yaml:"offset_storage" toml:"offset_storage" env:"KAFKA_OFFSET_STORAGE"`
+	// MaxPollRecords caps the records handled per poll, zero polls whatever
+	// has been fetched.
+	MaxPollRecords int           `yaml:"max_poll_records" toml:"max_poll_records" env:"KAFKA_MAX_POLL_RECORDS"`
+	FetchMaxBytes  int32         `yaml:"fetch_max_bytes" toml:"fetch_max_bytes" env:"KAFKA_FETCH_MAX_BYTES"`
+	FetchMaxWait   time.Duration `yaml:"fetch_max_wait" toml:"fetch_max_wait" env:"KAFKA_FETCH_MAX_WAIT"`
+
+	SASL KafkaSASLConfig `yaml:"sasl" toml:"sasl"`
+	TLS  KafkaTLSConfig  `yaml:"tls" toml:"tls"`
+}
+
+// KafkaSASLConfig authenticates to Kafka when Mechanism is set.
+type KafkaSASLConfig struct {
+	Mechanism string `yaml:"mechanism" toml:"mechanism" env:"KAFKA_SASL_MECHANISM"`
+	Username  string `yaml:"username" toml:"username" env:"KAFKA_SASL_USERNAME"`
+	Password  string `yaml:"password" toml:"password" env:"KAFKA_SASL_PASSWORD" secret:"true"`
+}
+
+type KafkaTLSConfig struct {
+	Enabled bool `yaml:"enabled" toml:"enabled" env:"KAFKA_TLS_ENABLED"`
+	// CAFile verifies the brokers instead of the system roots. CertFile and
+	// KeyFile authenticate the client.
+	CAFile             string `yaml:"ca_file" toml:"ca_file" env:"KAFKA_TLS_CA_FILE"`
+	CertFile           string `yaml:"cert_file" toml:"cert_file" env:"KAFKA_TLS_CERT_FILE"`
+	KeyFile            string `yaml:"key_file" toml:"key_file" env:"KAFKA_TLS_KEY_FILE"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify" toml:"insecure_skip_verify" env:"KAFKA_TLS_INSECURE_SKIP_VERIFY"`
+}
+
+// IngestionConfig controls how device payloads are decoded and stored.
+type IngestionConfig struct {
+	// TelemetryWriter selects how telemetry batches are written: insert, copy
+	// or copy_staging.
+	TelemetryWriter string `yaml:"telemetry_writer" toml:"telemetry_writer" env:"TELEMETRY_WRITER"`
+	// StrictPayloads rejects device payloads holding unknown fields.
+	StrictPayloads bool `yaml:"strict_payloads" toml:"strict_payloads" env:"STRICT_PAYLOADS"`
+	// RejectSpoofedDevices rejects events whose device id does not match the
+	// MQTT client id they were published with, instead of only logging them.
+	RejectSpoofedDevices bool             `yaml:"reject_spoofed_devices" toml:"reject_spoofed_devices" env:"REJECT_SPOOFED_DEVICES"`
+	Timestamps           TimestampsConfig `yaml:"timestamps" toml:"timestamps"`
+	Batch                BatchConfig      `yaml:"batch" toml:"batch"`
+}
+
+// BatchConfig controls how records are accumulated before being written.
+type BatchConfig struct {
+	// MaxRows and MaxWait flush a batch once it holds that many records or its
+	// oldest record waited that long.
+	MaxRows int           `yaml:"max_rows" toml:"max_rows" env:"BATCH_MAX_ROWS"`
+	MaxWait time.Duration `yaml:"max_wait" toml:"max_wait" env:"BATCH_MAX_WAIT"`
+	// MaxBuffered pauses fetching a pipeline while that many of its records
+	// wait to be written.
+	MaxBuffered int `yaml:"max_buffered" toml:"max_buffered" env:"BATCH_MAX_BUFFERED"`
+	// MaxInFlight bounds the batches written concurrently by all pipelines.
+	MaxInFlight int `yaml:"max_in_flight" toml:"max_in_flight" env:"BATCH_MAX_IN_FLIGHT"`
+	// DrainTimeout bounds how long the buffered batches are waited for on
+	// shutdown, those left unwritten are consumed again on restart.
+	DrainTimeout time.Duration `yaml:"drain_timeout" toml:"drain_timeout" env:"BATCH_DRAIN_TIMEOUT"`
+}
+
+type TimestampsConfig struct {
+	// Unit is the unit of numeric payload timestamps: s, ms, us, ns or auto
+	// to detect it from the magnitude.
+	Unit string `yaml:"unit" toml:"unit" env:"TIMESTAMP_UNIT"`
+	// FallbackToRecord uses the broker or Kafka record timestamp for payloads
+	// without a usable timestamp.
+	FallbackToRecord bool `yaml:"fallback_to_record" toml:"fallback_to_record" env:"TIMESTAMP_FALLBACK_TO_RECORD"`
+	// MaxFuture and MaxPast reject events further than this from now. Zero
+	// disables the check.
+	MaxFuture time.Duration `yaml:"max_future" toml:"max_future" env:"TIMESTAMP_MAX_FUTURE"`
+	MaxPast   time.Duration `yaml:"max_past" toml:"max_past" env:"TIMESTAMP_MAX_PAST"`
+}
+
+// RetentionConfig sets how long the events of each table are kept, applied by
+// the `db retention apply` command. Zero keeps the retention policy created by
+// the migrations.
+type RetentionConfig struct {
+	Telemetry     time.Duration `yaml:"telemetry" toml:"telemetry" env:"RETENTION_TELEMETRY"`
+	Alerts        time.Duration `yaml:"alerts" toml:"alerts" env:"RETENTION_ALERTS"`
+	Production    time.Duration `yaml:"production" toml:"production" env:"RETENTION_PRODUCTION"`
+	StatusUpdates time.Duration `yaml:"status_updates" toml:"status_updates" env:"RETENTION_STATUS_UPDATES"`
+}
+
+// ProductionConfig describes the plant schedule production is rolled up by.
+type ProductionConfig struct {
+	// ShiftStart is the time after midnight UTC the first shift of the day
+	// starts at. Shifts of ShiftLength follow each other around the clock.
+	ShiftStart  time.Duration `yaml:"shift_start" toml:"shift_start" env:"PRODUCTION_SHIFT_START"`
+	ShiftLength time.Duration `yaml:"shift_length" toml:"shift_length" env:"PRODUCTION_SHIFT_LENGTH"`
+}
+
+// StatusConfig controls the machine status history queries.
+type StatusConfig struct {
+	// Lookback bounds how far before a queried range the status a device
+	// starts it in is searched for. A device whose status last changed
+	// earlier starts the range in an unknown status.
+	Lookback time.Duration `yaml:"lookback" toml:"lookback" env:"STATUS_LOOKBACK"`
+}
+
+// LoggingConfig controls the structured logs written to stderr.
+type LoggingConfig struct {
+	// Level is the minimum level logged: debug, info, warn or error.
+	Level string `yaml:"level" toml:"level" env:"LOG_LEVEL"`
+	// Format is text or json.
+	Format string `yaml:"format" toml:"format" env:"LOG_FORMAT"`
+	// A warning or error repeated more than RateLimitBurst times within
+	// RateLimitInterval is suppressed until the interval ends, and the next
+	// one reports how many were. A zero burst logs every repetition.
+	RateLimitBurst    int           `yaml:"rate_limit_burst" toml:"rate_limit_burst" env:"LOG_RATE_LIMIT_BURST"`
+	RateLimitInterval time.Duration `yaml:"rate_limit_interval" toml:"rate_limit_interval" env:"LOG_RATE_LIMIT_INTERVAL"`
+}
+
+// TracingConfig exports OpenTelemetry traces to a collector over OTLP/gRPC.
+type TracingConfig struct {
+	Enabled bool `yaml:"enabled" toml:"enabled" env:"TRACING_ENABLED"`
+	// Endpoint is the host:port of the collector.
+	Endpoint    string `yaml:"endpoint" toml:"endpoint" env:"TRACING_ENDPOINT"`
+	Insecure    bool   `yaml:"insecure" toml:"insecure" env:"TRACING_INSECURE"`
+	ServiceName string `yaml:"service_name" toml:"service_name" env:"TRACING_SERVICE_NAME"`
+	// SampleRatio is the fraction of traces started by the backend that are
+	// sampled. Traces started upstream keep their sampling decision.
+	SampleRatio float64 `yaml:"sample_ratio" toml:"sample_ratio" env:"TRACING_SAMPLE_RATIO"`
+}
+
+type FeaturesConfig struct {
+	// DeadLetterQueue routes records that cannot be stored to <topic>.dlq
+	// instead of retrying them forever.
+	DeadLetterQueue bool `yaml:"dead_letter_queue" toml:"dead_letter_queue" env:"FEATURE_DEAD_LETTER_QUEUE"`
+}
+
+// Default returns the configuration used for settings that are not set.
+func Default() *Config {
+	return &Config{
+		HTTP: HTTPConfig{
+			Port:            8080,
+			ReadTimeout:     15 * time.Second,
+			WriteTimeout:    30 * time.Second,
+			ShutdownTimeout: 30 * time.Second,
+		},
+		Kafka: KafkaConfig{
+			Topics:        topics.DefaultMapping(),
+			OffsetStorage: OffsetStorageKafka,
+		},
+		Ingestion: IngestionConfig{
+			TelemetryWriter: "insert",
+			Batch: BatchConfig{
+				MaxRows:      1000,
+				MaxWait:      500 * time.Millisecond,
+				MaxBuffered:  10000,
+				MaxInFlight:  4,
+				DrainTimeout: 20 * time.Second,
+			},
+		},
+		Production: ProductionConfig{
+			ShiftStart:  6 * time.Hour,
+			ShiftLength: 8 * time.Hour,
+		},
+		Status: StatusConfig{
+			Lookback: 30 * 24 * time.Hour,
+		},
+		Logging: LoggingConfig{
+			Level:             "info",
+			Format:            "text",
+			RateLimitBurst:    5,
+			RateLimitInterval: time.Minute,
+		},
+		Tracing: TracingConfig{
+			Endpoint:    "localhost:4317",
+			Insecure:    true,
+			ServiceName: "iiot-backend",
+			SampleRatio: 1,
+		},
+		Features: FeaturesConfig{
+			DeadLetterQueue: true,
+		},
+	}
+}
+
+// Load reads the configuration from the defaults, the file named by
+// CONFIG_FILE if set and the environment, and validates it. Every invalid
+// setting is reported in the returned error.
+func Load() (*Config, error) {
+	return LoadFile(os.Getenv(ConfigFileEnv))
+}
+
+// LoadFile is Load with the configuration file at path, if not empty.
+func LoadFile(path string) (*Config, error) {
+	cfg, err := Read(path)
 	if err != nil {
-		log.Fatalln("PORT environment variable is not set")
+		return nil, err
 	}
-	cfg := &Config{
-		Port:         port,
-		DatabaseURL:  os.Getenv("DATABASE_URL"),
-		KafkaBroker:  os.Getenv("KAFKA_BROKER"),
-		KafkaGroupID: os.Getenv("KAFKA_GROUP_ID"),
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
 	}
+	return cfg, nil
+}
 
-	topicsStr := os.Getenv("KAFKA_TOPICS")
-	cfg.KafkaTopics = strings.Split(topicsStr, ",")
-	// Trim spaces from each topic name
-	for i, topic := range cfg.KafkaTopics {
-		cfg.KafkaTopics[i] = strings.TrimSpace(topic)
+// Read reads the configuration like LoadFile without validating it.
+func Read(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		if err := cfg.readFile(path); err != nil {
+			return nil, err
+		}
 	}
 
-	if cfg.DatabaseURL == "" {
-		log.Fatalln("DATABASE_URL environment variable is not set")
+	if err := applyEnv(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// LoadConfig is Load, exiting the process when the configuration is invalid.
+func LoadConfig() *Config {
+	cfg, err := Load()
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v\n", err)
 	}
+	return cfg
+}
 
-	if cfg.KafkaBroker == "" {
-		log.Fatalln("KAFKA_BROKER environment variable is not set")
+// readFile decodes the YAML or TOML file at path, chosen by its extension,
+// into cfg. Unknown settings are rejected so typos do not go unnoticed.
+func (cfg *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to read config file: %w", err)
 	}
 
-	if cfg.KafkaGroupID == "" {
-		log.Fatalln("KAFKA_GROUP_ID environment variable is not set")
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("unable to parse config file %s: %w", path, err)
+		}
+	case ".toml":
+		md, err := toml.Decode(string(data), cfg)
+		if err != nil {
+			return fmt.Errorf("unable to parse config file %s: %w", path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("unknown settings in config file %s: %v", path, undecoded)
+		}
+	default:
+		return fmt.Errorf("unsupported config file extension %q, expected .yaml, .yml or .toml", ext)
 	}
+	return nil
+}
 
-	return cfg
+// Print writes the configuration as YAML with its secrets redacted.
+func (cfg *Config) Print(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(cfg.Redacted()); err != nil {
+		return err
+	}
+	return enc.Close()
 }
@@ -0,0 +1,28 @@
+package configs
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PoolConfig returns the pgxpool configuration of the database URL with the
+// configured pool settings.
+func (d DatabaseConfig) PoolConfig() (*pgxpool.Config, error) {
+	cfg, err := pgxpool.ParseConfig(d.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.MaxConns > 0 {
+		cfg.MaxConns = d.MaxConns
+	}
+	if d.MinConns > 0 {
+		cfg.MinConns = d.MinConns
+	}
+	if d.MaxConnLifetime > 0 {
+		cfg.MaxConnLifetime = d.MaxConnLifetime
+	}
+	if d.MaxConnIdleTime > 0 {
+		cfg.MaxConnIdleTime = d.MaxConnIdleTime
+	}
+	return cfg, nil
+}
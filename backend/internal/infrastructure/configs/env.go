@@ -0,0 +1,110 @@
+package configs
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"iiot_system/backend/internal/infrastructure/topics"
+)
+
+var (
+	durationType = reflect.TypeFor[time.Duration]()
+	mappingType  = reflect.TypeFor[topics.Mapping]()
+)
+
+// applyEnv overrides the fields of cfg whose `env` variable is set and not
+// empty. Every variable that cannot be parsed is reported.
+func applyEnv(cfg *Config) error {
+	return errors.Join(applyEnvFields(reflect.ValueOf(cfg).Elem())...)
+}
+
+func applyEnvFields(v reflect.Value) []error {
+	var errs []error
+	for i := range v.NumField() {
+		field, value := v.Type().Field(i), v.Field(i)
+
+		name, ok := field.Tag.Lookup("env")
+		if !ok {
+			if value.Kind() == reflect.Struct {
+				errs = append(errs, applyEnvFields(value)...)
+			}
+			continue
+		}
+
+		raw := strings.TrimSpace(os.Getenv(name))
+		if raw == "" {
+			continue
+		}
+		if err := setFromString(value, raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errs
+}
+
+// setFromString parses raw into v. Lists are comma separated.
+func setFromString(v reflect.Value, raw string) error {
+	switch {
+	case v.Type() == mappingType:
+		m, err := topics.ParseMapping(raw)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(m))
+		return nil
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return errors.New("must be a duration, such as 30s or 5m")
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(raw))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return errors.New("must be a boolean")
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return errors.New("must be an integer")
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return errors.New("must be a number")
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		parts := strings.Split(raw, ",")
+		s := reflect.MakeSlice(v.Type(), 0, len(parts))
+		for _, part := range parts {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := setFromString(elem, strings.TrimSpace(part)); err != nil {
+				return err
+			}
+			s = reflect.Append(s, elem)
+		}
+		v.Set(s)
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package configs
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Validate reports every invalid setting of cfg.
+func (cfg *Config) Validate() error {
+	var errs []error
+	fail := func(setting, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", setting, fmt.Sprintf(format, args...)))
+	}
+	oneOf := func(setting, value string, allowed ...string) {
+		if !slices.Contains(allowed, value) {
+			fail(setting, "must be one of %s, got %q", strings.Join(allowed, ", "), value)
+		}
+	}
+	nonNegative := func(setting string, value int64) {
+		if value < 0 {
+			fail(setting, "must not be negative")
+		}
+	}
+	positive := func(setting string, value int64) {
+		if value <= 0 {
+			fail(setting, "must be positive")
+		}
+	}
+
+	if cfg.HTTP.Port <= 0 || cfg.HTTP.Port > 65535 {
+		fail("http.port", "must be between 1 and 65535, got %d", cfg.HTTP.Port)
+	}
+	nonNegative("http.read_timeout", int64(cfg.HTTP.ReadTimeout))
+	nonNegative("http.write_timeout", int64(cfg.HTTP.WriteTimeout))
+	nonNegative("http.shutdown_timeout", int64(cfg.HTTP.ShutdownTimeout))
+
+	if cfg.Database.URL == "" {
+		fail("database.url", "is required")
+	}
+	nonNegative("database.max_conns", int64(cfg.Database.MaxConns))
+	nonNegative("database.min_conns", int64(cfg.Database.MinConns))
+	if cfg.Database.MaxConns > 0 && cfg.Database.MinConns > cfg.Database.MaxConns {
+		fail("database.min_conns", "must not exceed database.max_conns")
+	}
+	nonNegative("database.max_conn_lifetime", int64(cfg.Database.MaxConnLifetime))
+	nonNegative("database.max_conn_idle_time", int64(cfg.Database.MaxConnIdleTime))
+
+	if len(cfg.Kafka.Brokers) == 0 || slices.Contains(cfg.Kafka.Brokers, "") {
+		fail("kafka.brokers", "is required")
+	}
+	if cfg.Kafka.GroupID == "" {
+		fail("kafka.group_id", "is required")
+	}
+	if err := cfg.Kafka.Topics.Validate(); err != nil {
+		fail("kafka.topics", "%v", err)
+	}
+	oneOf("kafka.offset_storage", cfg.Kafka.OffsetStorage, OffsetStorageKafka, OffsetStorageDatabase)
+	nonNegative("kafka.max_poll_records", int64(cfg.Kafka.MaxPollRecords))
+	nonNegative("kafka.fetch_max_bytes", int64(cfg.Kafka.FetchMaxBytes))
+	nonNegative("kafka.fetch_max_wait", int64(cfg.Kafka.FetchMaxWait))
+	if sasl := cfg.Kafka.SASL; sasl.Mechanism != "" {
+		oneOf("kafka.sasl.mechanism", sasl.Mechanism, SASLMechanismPlain, SASLMechanismScramSHA256, SASLMechanismScramSHA512)
+		if sasl.Username == "" || sasl.Password == "" {
+			fail("kafka.sasl", "username and password are required")
+		}
+	}
+	if tls := cfg.Kafka.TLS; (tls.CertFile == "") != (tls.KeyFile == "") {
+		fail("kafka.tls", "cert_file and key_file must be set together")
+	}
+
+	oneOf("ingestion.telemetry_writer", cfg.Ingestion.TelemetryWriter, "insert", "copy", "copy_staging")
+	oneOf("ingestion.timestamps.unit", cfg.Ingestion.Timestamps.Unit, "", "auto", "s", "ms", "us", "ns")
+	nonNegative("ingestion.timestamps.max_future", int64(cfg.Ingestion.Timestamps.MaxFuture))
+	nonNegative("ingestion.timestamps.max_past", int64(cfg.Ingestion.Timestamps.MaxPast))
+	positive("ingestion.batch.max_rows", int64(cfg.Ingestion.Batch.MaxRows))
+	positive("ingestion.batch.max_wait", int64(cfg.Ingestion.Batch.MaxWait))
+	positive("ingestion.batch.max_in_flight", int64(cfg.Ingestion.Batch.MaxInFlight))
+	positive("ingestion.batch.drain_timeout", int64(cfg.Ingestion.Batch.DrainTimeout))
+	if batch := cfg.Ingestion.Batch; batch.MaxBuffered < batch.MaxRows {
+		fail("ingestion.batch.max_buffered", "must be at least ingestion.batch.max_rows")
+	}
+
+	nonNegative("retention.telemetry", int64(cfg.Retention.Telemetry))
+	nonNegative("retention.alerts", int64(cfg.Retention.Alerts))
+	nonNegative("retention.production", int64(cfg.Retention.Production))
+	nonNegative("retention.status_updates", int64(cfg.Retention.StatusUpdates))
+
+	if start := cfg.Production.ShiftStart; start < 0 || start >= 24*time.Hour {
+		fail("production.shift_start", "must be between 0 and 24h, got %s", start)
+	}
+	if length := cfg.Production.ShiftLength; length <= 0 || (24*time.Hour)%length != 0 {
+		fail("production.shift_length", "must divide a day, got %s", length)
+	}
+
+	positive("status.lookback", int64(cfg.Status.Lookback))
+
+	oneOf("logging.level", cfg.Logging.Level, "debug", "info", "warn", "error")
+	oneOf("logging.format", cfg.Logging.Format, "text", "json")
+	nonNegative("logging.rate_limit_burst", int64(cfg.Logging.RateLimitBurst))
+	if cfg.Logging.RateLimitBurst > 0 {
+		positive("logging.rate_limit_interval", int64(cfg.Logging.RateLimitInterval))
+	}
+
+	if cfg.Tracing.Enabled {
+		if cfg.Tracing.Endpoint == "" {
+			fail("tracing.endpoint", "is required")
+		}
+		if cfg.Tracing.ServiceName == "" {
+			fail("tracing.service_name", "is required")
+		}
+	}
+	if r := cfg.Tracing.SampleRatio; r < 0 || r > 1 {
+		fail("tracing.sample_ratio", "must be between 0 and 1, got %g", r)
+	}
+
+	return errors.Join(errs...)
+}
+
+// redacted replaces a secret.
+const redacted = "REDACTED"
+
+// Redacted returns a copy of cfg with the fields tagged `secret` redacted.
+// Secret URLs only have their password redacted.
+func (cfg *Config) Redacted() *Config {
+	c := *cfg
+	c.Kafka.Brokers = slices.Clone(cfg.Kafka.Brokers)
+	redactFields(reflect.ValueOf(&c).Elem())
+	return &c
+}
+
+func redactFields(v reflect.Value) {
+	for i := range v.NumField() {
+		field, value := v.Type().Field(i), v.Field(i)
+
+		switch secret := field.Tag.Get("secret"); {
+		case value.Kind() == reflect.Struct:
+			redactFields(value)
+		case value.Kind() != reflect.String || value.String() == "":
+		case secret == "url":
+			value.SetString(redactURL(value.String()))
+		case secret != "":
+			value.SetString(redacted)
+		}
+	}
+}
+
+// redactURL redacts the password of a URL, or the whole value if it is not a
+// URL, such as a key/value connection string.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" {
+		return redacted
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	}
+	return u.String()
+}
@@ -0,0 +1,72 @@
+package kafka
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// Assignment tracks the partitions assigned to a consumer client. It is safe
+// for concurrent use.
+type Assignment struct {
+	mu         sync.Mutex
+	partitions map[string][]int32
+}
+
+// NewAssignment returns an Assignment with no partition assigned yet.
+func NewAssignment() *Assignment {
+	return &Assignment{
+		partitions: make(map[string][]int32),
+	}
+}
+
+// Partitions returns the partitions currently assigned, by topic.
+func (a *Assignment) Partitions() map[string][]int32 {
+	if a == nil {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	partitions := make(map[string][]int32, len(a.partitions))
+	for topic, ps := range a.partitions {
+		partitions[topic] = slices.Clone(ps)
+	}
+	return partitions
+}
+
+func (a *Assignment) assigned(_ context.Context, _ *kgo.Client, assigned map[string][]int32) {
+	if a == nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for topic, ps := range assigned {
+		a.partitions[topic] = append(a.partitions[topic], ps...)
+		slices.Sort(a.partitions[topic])
+		a.partitions[topic] = slices.Compact(a.partitions[topic])
+	}
+}
+
+func (a *Assignment) revoked(revoked map[string][]int32) {
+	if a == nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for topic, ps := range revoked {
+		a.partitions[topic] = slices.DeleteFunc(a.partitions[topic], func(p int32) bool {
+			return slices.Contains(ps, p)
+		})
+		if len(a.partitions[topic]) == 0 {
+			delete(a.partitions, topic)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"time"
+
+	"iiot_system/backend/internal/infrastructure/configs"
+	"iiot_system/backend/internal/infrastructure/topics"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// ConsumerGroupID returns the consumer group used by the named pipeline.
+// Every pipeline gets its own group so that pipelines never compete for records.
+func ConsumerGroupID(baseGroupID, name string) string {
+	return fmt.Sprintf("%s.%s", baseGroupID, name)
+}
+
+// NewConsumerClient creates a client that owns the subscriptions of a single
+// pipeline. Offsets are only committed for records explicitly marked by the
+// caller, and never when they are stored in the database: the client must
+// then resume from them, see ResumeFromStoredOffsets. The partitions assigned
+// to the client are tracked by assignment, which may be nil.
+func NewConsumerClient(cfg *configs.Config, pipeline string, subs []topics.Subscription, assignment *Assignment, opts ...kgo.Opt) (*kgo.Client, error) {
+	base, err := connectionOpts(cfg)
+	if err != nil {
+		return nil, err
+	}
+	base = append(base,
+		kgo.ConsumerGroup(ConsumerGroupID(cfg.Kafka.GroupID, pipeline)),
+		kgo.OnPartitionsAssigned(assignment.assigned),
+		kgo.OnPartitionsRevoked(func(ctx context.Context, c *kgo.Client, m map[string][]int32) {
+			if err := c.CommitMarkedOffsets(ctx); err != nil {
+				slog.Error("unable to commit offsets", "pipeline", pipeline, "error", err)
+			}
+			assignment.revoked(m)
+		}),
+		kgo.OnPartitionsLost(func(_ context.Context, _ *kgo.Client, m map[string][]int32) {
+			assignment.revoked(m)
+		}),
+		kgo.BlockRebalanceOnPoll(),
+		// Retriable errors, such as a consumed topic missing, are returned
+		// by polls so that the consumers report those that persist.
+		kgo.KeepRetryableFetchErrors(),
+	)
+	if cfg.Kafka.OffsetStorage == configs.OffsetStorageDatabase {
+		// Records are never marked, so nothing is committed to Kafka.
+		base = append(base, kgo.DisableAutoCommit())
+	} else {
+		base = append(base, kgo.AutoCommitMarks(), kgo.AutoCommitInterval(5*time.Second))
+	}
+	if cfg.Kafka.FetchMaxBytes > 0 {
+		base = append(base, kgo.FetchMaxBytes(cfg.Kafka.FetchMaxBytes))
+	}
+	if cfg.Kafka.FetchMaxWait > 0 {
+		base = append(base, kgo.FetchMaxWait(cfg.Kafka.FetchMaxWait))
+	}
+	base = append(base, subscriptionOpts(subs)...)
+
+	client, err := kgo.NewClient(append(base, opts...)...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create kafka client for pipeline %s: %w", pipeline, err)
+	}
+
+	return client, nil
+}
+
+// subscriptionOpts consumes subs. With any regular expression among them,
+// every subscription is consumed as one, and dead-letter topics are excluded
+// so they are never consumed as events.
+func subscriptionOpts(subs []topics.Subscription) []kgo.Opt {
+	if !topics.HasRegex(subs) {
+		names := make([]string, len(subs))
+		for i, sub := range subs {
+			names[i] = sub.Topic
+		}
+		return []kgo.Opt{kgo.ConsumeTopics(names...)}
+	}
+
+	patterns := make([]string, len(subs))
+	for i, sub := range subs {
+		patterns[i] = sub.Pattern()
+	}
+	return []kgo.Opt{
+		kgo.ConsumeTopics(patterns...),
+		kgo.ConsumeRegex(),
+		kgo.ConsumeExcludeTopics(regexp.QuoteMeta(deadLetterSuffix) + "$"),
+	}
+}
+
+// CheckSubscriptions fails if a subscribed topic does not exist, or if a
+// regular expression does not match any existing topic.
+func CheckSubscriptions(ctx context.Context, client *kgo.Client, subs []topics.Subscription) error {
+	details, err := kadm.NewClient(client).ListTopics(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to list kafka topics: %w", err)
+	}
+
+	var errs []error
+	for _, sub := range subs {
+		if !sub.Regex {
+			if !details.Has(sub.Topic) {
+				errs = append(errs, fmt.Errorf("topic %s does not exist", sub.Topic))
+			}
+			continue
+		}
+
+		re := regexp.MustCompile(sub.Topic)
+		matched := false
+		for _, topic := range details.Names() {
+			if re.MatchString(topic) && !isDeadLetterTopic(topic) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			errs = append(errs, fmt.Errorf("no topic matches %s", sub))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,272 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"iiot_system/backend/internal/infrastructure/configs"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// Headers attached to every dead-lettered record.
+const (
+	HeaderDeadLetterError     = "dlq.error"
+	HeaderDeadLetterTopic     = "dlq.source.topic"
+	HeaderDeadLetterPartition = "dlq.source.partition"
+	HeaderDeadLetterOffset    = "dlq.source.offset"
+	HeaderDeadLetterAttempts  = "dlq.attempts"
+	HeaderDeadLetterFailedAt  = "dlq.failed_at"
+)
+
+// deadLetterSuffix is appended to a topic to name its dead-letter topic.
+const deadLetterSuffix = ".dlq"
+
+// DeadLetterTopic returns the topic records from topic are dead-lettered to.
+func DeadLetterTopic(topic string) string {
+	return topic + deadLetterSuffix
+}
+
+func isDeadLetterTopic(topic string) bool {
+	return strings.HasSuffix(topic, deadLetterSuffix)
+}
+
+// NewProducerClient creates a client used only to produce records.
+func NewProducerClient(cfg *configs.Config, opts ...kgo.Opt) (*kgo.Client, error) {
+	base, err := connectionOpts(cfg)
+	if err != nil {
+		return nil, err
+	}
+	base = append(base, kgo.AllowAutoTopicCreation())
+
+	client, err := kgo.NewClient(append(base, opts...)...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create kafka producer client: %w", err)
+	}
+
+	return client, nil
+}
+
+// DeadLetterProducer moves records that cannot be decoded or stored to the
+// dead-letter topic of their source topic.
+type DeadLetterProducer struct {
+	client *kgo.Client
+}
+
+func NewDeadLetterProducer(client *kgo.Client) *DeadLetterProducer {
+	return &DeadLetterProducer{
+		client: client,
+	}
+}
+
+// Send produces rec to its dead-letter topic, keeping its key, value and
+// headers and describing the failure in the dlq.* headers.
+func (p *DeadLetterProducer) Send(ctx context.Context, rec *kgo.Record, cause error, attempts int) error {
+	headers := make([]kgo.RecordHeader, 0, len(rec.Headers)+6)
+	for _, h := range rec.Headers {
+		if !isDeadLetterHeader(h.Key) {
+			headers = append(headers, h)
+		}
+	}
+	headers = append(headers,
+		kgo.RecordHeader{Key: HeaderDeadLetterError, Value: []byte(cause.Error())},
+		kgo.RecordHeader{Key: HeaderDeadLetterTopic, Value: []byte(rec.Topic)},
+		kgo.RecordHeader{Key: HeaderDeadLetterPartition, Value: []byte(strconv.FormatInt(int64(rec.Partition), 10))},
+		kgo.RecordHeader{Key: HeaderDeadLetterOffset, Value: []byte(strconv.FormatInt(rec.Offset, 10))},
+		kgo.RecordHeader{Key: HeaderDeadLetterAttempts, Value: []byte(strconv.Itoa(PreviousAttempts(rec) + attempts))},
+		kgo.RecordHeader{Key: HeaderDeadLetterFailedAt, Value: []byte(time.Now().UTC().Format(time.RFC3339Nano))},
+	)
+
+	dead := &kgo.Record{
+		Topic:   DeadLetterTopic(rec.Topic),
+		Key:     rec.Key,
+		Value:   rec.Value,
+		Headers: headers,
+	}
+
+	if err := p.client.ProduceSync(ctx, dead).FirstErr(); err != nil {
+		return fmt.Errorf("unable to produce record to %s: %w", dead.Topic, err)
+	}
+
+	return nil
+}
+
+// PreviousAttempts returns the attempts a replayed record already went
+// through before it was dead-lettered, or 0 for a fresh record.
+func PreviousAttempts(rec *kgo.Record) int {
+	for _, h := range rec.Headers {
+		if h.Key == HeaderDeadLetterAttempts {
+			attempts, err := strconv.Atoi(string(h.Value))
+			if err != nil {
+				return 0
+			}
+			return attempts
+		}
+	}
+	return 0
+}
+
+// ReplayDeadLetters consumes the dead-letter topic of topic and produces every
+// record back to its source topic so it goes through the normal pipeline
+// again. Only the records present when the replay starts are replayed: it
+// returns once the offsets committed by the replay group reach the high
+// watermarks read then, leaving records dead-lettered again meanwhile to the
+// next replay.
+func ReplayDeadLetters(ctx context.Context, cfg *configs.Config, topic string) (int, error) {
+	dlqTopic := DeadLetterTopic(topic)
+	group := ConsumerGroupID(cfg.Kafka.GroupID, dlqTopic+".replay")
+
+	opts, err := connectionOpts(cfg)
+	if err != nil {
+		return 0, err
+	}
+
+	client, err := kgo.NewClient(append(opts,
+		kgo.ConsumerGroup(group),
+		kgo.ConsumeTopics(dlqTopic),
+		kgo.DisableAutoCommit(),
+	)...)
+	if err != nil {
+		return 0, fmt.Errorf("unable to create kafka client for topic %s: %w", dlqTopic, err)
+	}
+	defer client.Close()
+	admin := kadm.NewClient(client)
+
+	ends, err := replayEnds(ctx, admin, dlqTopic)
+	if err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+	for {
+		done, err := replayDone(ctx, admin, group, dlqTopic, ends)
+		if err != nil || done {
+			return replayed, err
+		}
+
+		fetches := client.PollFetches(ctx)
+		if err := ctx.Err(); err != nil {
+			return replayed, err
+		}
+		if fetches.IsClientClosed() {
+			return replayed, nil
+		}
+		if err := fetches.Err(); err != nil {
+			return replayed, err
+		}
+
+		var records []*kgo.Record
+		for _, rec := range fetches.Records() {
+			if rec.Offset < ends[rec.Partition] {
+				records = append(records, rec)
+			}
+		}
+		if len(records) == 0 {
+			continue
+		}
+
+		replays := make([]*kgo.Record, 0, len(records))
+		for _, rec := range records {
+			replays = append(replays, replayRecord(rec, topic))
+		}
+
+		if err := client.ProduceSync(ctx, replays...).FirstErr(); err != nil {
+			return replayed, fmt.Errorf("unable to replay records to %s: %w", topic, err)
+		}
+		if err := client.CommitRecords(ctx, records...); err != nil {
+			return replayed, fmt.Errorf("unable to commit replayed records: %w", err)
+		}
+
+		replayed += len(records)
+	}
+}
+
+// replayEnds returns the high watermark of every partition of dlqTopic
+// holding records.
+func replayEnds(ctx context.Context, admin *kadm.Client, dlqTopic string) (map[int32]int64, error) {
+	starts, err := admin.ListStartOffsets(ctx, dlqTopic)
+	if err == nil {
+		err = starts.Error()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to list start offsets of %s: %w", dlqTopic, err)
+	}
+
+	listed, err := admin.ListEndOffsets(ctx, dlqTopic)
+	if err == nil {
+		err = listed.Error()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to list end offsets of %s: %w", dlqTopic, err)
+	}
+
+	ends := make(map[int32]int64)
+	listed.Each(func(end kadm.ListedOffset) {
+		if start, ok := starts.Lookup(end.Topic, end.Partition); !ok || start.Offset < end.Offset {
+			ends[end.Partition] = end.Offset
+		}
+	})
+	return ends, nil
+}
+
+// replayDone reports whether group committed every partition of dlqTopic up
+// to its end in ends.
+func replayDone(ctx context.Context, admin *kadm.Client, group, dlqTopic string, ends map[int32]int64) (bool, error) {
+	if len(ends) == 0 {
+		return true, nil
+	}
+
+	committed, err := admin.FetchOffsets(ctx, group)
+	if err == nil {
+		err = committed.Error()
+	}
+	if err != nil {
+		return false, fmt.Errorf("unable to fetch offsets of group %s: %w", group, err)
+	}
+
+	for partition, end := range ends {
+		if o, ok := committed.Lookup(dlqTopic, partition); !ok || o.At < end {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// replayRecord builds the record produced back to the source topic. The
+// attempt count is kept so a record failing again accumulates its attempts.
+func replayRecord(rec *kgo.Record, topic string) *kgo.Record {
+	source := topic
+	headers := make([]kgo.RecordHeader, 0, len(rec.Headers))
+	for _, h := range rec.Headers {
+		switch h.Key {
+		case HeaderDeadLetterTopic:
+			source = string(h.Value)
+		case HeaderDeadLetterAttempts:
+			headers = append(headers, h)
+		default:
+			if !isDeadLetterHeader(h.Key) {
+				headers = append(headers, h)
+			}
+		}
+	}
+
+	return &kgo.Record{
+		Topic:   source,
+		Key:     rec.Key,
+		Value:   rec.Value,
+		Headers: headers,
+	}
+}
+
+func isDeadLetterHeader(key string) bool {
+	switch key {
+	case HeaderDeadLetterError, HeaderDeadLetterTopic, HeaderDeadLetterPartition,
+		HeaderDeadLetterOffset, HeaderDeadLetterAttempts, HeaderDeadLetterFailedAt:
+		return true
+	}
+	return false
+}
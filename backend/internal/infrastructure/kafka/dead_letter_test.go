@@ -0,0 +1,74 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"iiot_system/backend/internal/infrastructure/configs"
+
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestReplayDeadLetters(t *testing.T) {
+	const topic = "oee.test"
+	cluster, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.SeedTopics(2, topic, DeadLetterTopic(topic)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cluster.Close)
+
+	cfg := configs.Default()
+	cfg.Kafka.Brokers = cluster.ListenAddrs()
+
+	client, err := kgo.NewClient(kgo.SeedBrokers(cluster.ListenAddrs()...), kgo.ConsumeTopics(topic))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	producer := NewDeadLetterProducer(client)
+	for i := range 5 {
+		rec := &kgo.Record{Topic: topic, Partition: int32(i % 2), Value: []byte(strconv.Itoa(i))}
+		if err := producer.Send(ctx, rec, errors.New("invalid payload"), 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	replayed, err := ReplayDeadLetters(ctx, cfg, topic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed != 5 {
+		t.Fatalf("replayed %d records, want 5", replayed)
+	}
+
+	var values []string
+	for len(values) < 5 && ctx.Err() == nil {
+		fetches := client.PollFetches(ctx)
+		fetches.EachRecord(func(rec *kgo.Record) {
+			values = append(values, string(rec.Value))
+			if PreviousAttempts(rec) != 1 {
+				t.Errorf("record %s has %d previous attempts, want 1", rec.Value, PreviousAttempts(rec))
+			}
+		})
+	}
+	if len(values) != 5 {
+		t.Fatalf("source topic got %v, want 5 records", values)
+	}
+
+	// The committed offsets reach the high watermarks: nothing is left to
+	// replay, and the replay returns without waiting for records.
+	start := time.Now()
+	if replayed, err = ReplayDeadLetters(ctx, cfg, topic); err != nil || replayed != 0 {
+		t.Fatalf("second replay got %d records, %v, want none", replayed, err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("second replay took %s", elapsed)
+	}
+}
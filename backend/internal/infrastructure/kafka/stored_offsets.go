@@ -0,0 +1,38 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// OffsetLoader returns the next offsets to consume, per partition of topic,
+// stored outside of Kafka for a consumer group.
+type OffsetLoader interface {
+	Load(ctx context.Context, group, topic string) (map[int32]int64, error)
+}
+
+// ResumeFromStoredOffsets makes a consumer client resume assigned partitions
+// from the offsets stored by loader instead of the offsets committed to
+// Kafka. Partitions without stored offsets keep the committed ones. The
+// offsets are adjusted once the group fetched its committed offsets, before
+// fetching records; the assignment fails if they cannot be loaded, so the
+// client never silently falls back to the committed offsets.
+func ResumeFromStoredOffsets(group string, loader OffsetLoader) kgo.Opt {
+	return kgo.AdjustFetchOffsetsFn(func(ctx context.Context, offsets map[string]map[int32]kgo.Offset) (map[string]map[int32]kgo.Offset, error) {
+		for topic, partitions := range offsets {
+			stored, err := loader.Load(ctx, group, topic)
+			if err != nil {
+				return nil, fmt.Errorf("unable to load stored offsets of group %s for topic %s: %w", group, topic, err)
+			}
+
+			for partition := range partitions {
+				if offset, ok := stored[partition]; ok {
+					partitions[partition] = kgo.NewOffset().At(offset).WithEpoch(-1)
+				}
+			}
+		}
+		return offsets, nil
+	})
+}
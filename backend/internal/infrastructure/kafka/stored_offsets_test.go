@@ -0,0 +1,104 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"iiot_system/backend/internal/infrastructure/configs"
+	"iiot_system/backend/internal/infrastructure/topics"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// flakyLoader fails as many loads as failures, then returns offsets.
+type flakyLoader struct {
+	failures int32
+	loads    atomic.Int32
+	offsets  map[int32]int64
+}
+
+func (l *flakyLoader) Load(context.Context, string, string) (map[int32]int64, error) {
+	if l.loads.Add(1) <= l.failures {
+		return nil, errors.New("database unavailable")
+	}
+	return l.offsets, nil
+}
+
+func TestResumeFromStoredOffsets(t *testing.T) {
+	const topic = "oee.test"
+	cluster, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.SeedTopics(1, topic))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cluster.Close)
+
+	cfg := configs.Default()
+	cfg.Kafka.Brokers = cluster.ListenAddrs()
+	cfg.Kafka.OffsetStorage = configs.OffsetStorageDatabase
+	group := ConsumerGroupID(cfg.Kafka.GroupID, "test")
+
+	admin, err := kgo.NewClient(kgo.SeedBrokers(cluster.ListenAddrs()...), kgo.DefaultProduceTopic(topic))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(admin.Close)
+	ctx := context.Background()
+	for i := range 10 {
+		if err := admin.ProduceSync(ctx, kgo.StringRecord(strconv.Itoa(i))).FirstErr(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Kafka holds an older committed offset than the database.
+	var committed kadm.Offsets
+	committed.AddOffset(topic, 0, 2, -1)
+	if err := kadm.NewClient(admin).CommitAllOffsets(ctx, group, committed); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first load fails: the assignment must not fall back to the
+	// committed offset.
+	loader := &flakyLoader{failures: 1, offsets: map[int32]int64{0: 7}}
+	client, err := NewConsumerClient(cfg, "test", []topics.Subscription{{Topic: topic}}, NewAssignment(),
+		ResumeFromStoredOffsets(group, loader))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pollCtx, cancel := context.WithTimeout(ctx, 20*time.Second)
+	defer cancel()
+	var first *kgo.Record
+	for first == nil && pollCtx.Err() == nil {
+		fetches := client.PollFetches(pollCtx)
+		if records := fetches.Records(); len(records) > 0 {
+			first = records[0]
+		}
+		client.AllowRebalance()
+	}
+	client.Close()
+
+	if first == nil {
+		t.Fatal("no record consumed")
+	}
+	if first.Offset != 7 {
+		t.Errorf("resumed from offset %d, want the stored offset 7", first.Offset)
+	}
+	if loader.loads.Load() < 2 {
+		t.Errorf("stored offsets loaded %d times, want a retry after the failure", loader.loads.Load())
+	}
+
+	// Nothing is committed to Kafka when offsets are stored in the database.
+	offsets, err := kadm.NewClient(admin).FetchOffsets(ctx, group)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o, _ := offsets.Lookup(topic, 0); o.At != 2 {
+		t.Errorf("committed offset %d, want 2", o.At)
+	}
+}
@@ -0,0 +1,34 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"iiot_system/backend/internal/infrastructure/configs"
+)
+
+// New returns a logger writing to w as described by cfg.
+func New(cfg configs.LoggingConfig, w io.Writer) (*slog.Logger, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q: %w", cfg.Level, err)
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case "", "text":
+		handler = slog.NewTextHandler(w, opts)
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("invalid log format %q", cfg.Format)
+	}
+
+	if cfg.RateLimitBurst > 0 {
+		handler = NewRateLimitHandler(handler, cfg.RateLimitBurst, cfg.RateLimitInterval)
+	}
+	return slog.New(handler), nil
+}
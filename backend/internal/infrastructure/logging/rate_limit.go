@@ -0,0 +1,126 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimitHandler suppresses warnings and errors repeated more than burst
+// times within an interval, so a failing broker or database does not flood
+// the logs. Records repeat when they have the same level, message and logger
+// attributes, such as the consumer they come from. The first record logged
+// after some were suppressed reports how many in a "suppressed" attribute.
+type RateLimitHandler struct {
+	next     slog.Handler
+	burst    int
+	interval time.Duration
+	// key identifies the attributes and groups added to the handler.
+	key   string
+	state *rateLimitState
+}
+
+type rateLimitState struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	windows map[string]*rateLimitWindow
+}
+
+type rateLimitWindow struct {
+	started    time.Time
+	logged     int
+	suppressed int
+}
+
+// NewRateLimitHandler passes the records of next through, logging at most
+// burst records of each kind of warning or error per interval.
+func NewRateLimitHandler(next slog.Handler, burst int, interval time.Duration) *RateLimitHandler {
+	return &RateLimitHandler{
+		next:     next,
+		burst:    burst,
+		interval: interval,
+		state: &rateLimitState{
+			now:     time.Now,
+			windows: make(map[string]*rateLimitWindow),
+		},
+	}
+}
+
+func (h *RateLimitHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *RateLimitHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < slog.LevelWarn {
+		return h.next.Handle(ctx, r)
+	}
+
+	suppressed, ok := h.allow(h.key + "\x00" + r.Level.String() + "\x00" + r.Message)
+	if !ok {
+		return nil
+	}
+	if suppressed > 0 {
+		r = r.Clone()
+		r.AddAttrs(slog.Int("suppressed", suppressed))
+	}
+	return h.next.Handle(ctx, r)
+}
+
+// allow reports whether a record of key is logged, and how many were
+// suppressed since the last one that was.
+func (h *RateLimitHandler) allow(key string) (int, bool) {
+	s := h.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	w, ok := s.windows[key]
+	if !ok || now.Sub(w.started) >= h.interval {
+		var suppressed int
+		if ok {
+			suppressed = w.suppressed
+		}
+		s.windows[key] = &rateLimitWindow{started: now, logged: 1}
+		s.evict(now, h.interval)
+		return suppressed, true
+	}
+
+	if w.logged >= h.burst {
+		w.suppressed++
+		return 0, false
+	}
+	w.logged++
+	return 0, true
+}
+
+// evict forgets the windows that ended without suppressing records, so keys
+// holding ids do not grow the map forever.
+func (s *rateLimitState) evict(now time.Time, interval time.Duration) {
+	for key, w := range s.windows {
+		if w.suppressed == 0 && now.Sub(w.started) >= interval {
+			delete(s.windows, key)
+		}
+	}
+}
+
+func (h *RateLimitHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var key strings.Builder
+	key.WriteString(h.key)
+	for _, a := range attrs {
+		key.WriteString("\x00" + a.String())
+	}
+
+	clone := *h
+	clone.next = h.next.WithAttrs(attrs)
+	clone.key = key.String()
+	return &clone
+}
+
+func (h *RateLimitHandler) WithGroup(name string) slog.Handler {
+	clone := *h
+	clone.next = h.next.WithGroup(name)
+	clone.key = h.key + "\x00" + name + "."
+	return &clone
+}
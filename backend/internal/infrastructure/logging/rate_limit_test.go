@@ -0,0 +1,48 @@
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimitHandler(t *testing.T) {
+	var out bytes.Buffer
+	handler := NewRateLimitHandler(slog.NewTextHandler(&out, nil), 2, time.Minute)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	handler.state.now = func() time.Time { return now }
+	logger := slog.New(handler)
+
+	for range 5 {
+		logger.Error("unable to store batch", "batch_id", 1)
+		logger.Info("resuming consumer")
+	}
+	logger.With("consumer", "alerts").Error("unable to store batch")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if got := count(lines, "unable to store batch"); got != 3 {
+		t.Fatalf("logged %d errors, want 2 plus 1 of another logger:\n%s", got, out.String())
+	}
+	if got := count(lines, "resuming consumer"); got != 5 {
+		t.Fatalf("logged %d infos, want all 5", got)
+	}
+
+	out.Reset()
+	now = now.Add(time.Minute)
+	logger.Error("unable to store batch", "batch_id", 2)
+	if !strings.Contains(out.String(), "suppressed=3") {
+		t.Fatalf("first error of the next interval does not report the suppressed ones: %s", out.String())
+	}
+}
+
+func count(lines []string, msg string) int {
+	var n int
+	for _, line := range lines {
+		if strings.Contains(line, msg) {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,181 @@
+package repositories
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"iiot_system/backend/gen/models"
+	iotalerts "iiot_system/backend/internal/domain/iot/iot_alerts"
+
+	"github.com/stephenafamo/bob"
+	"github.com/stephenafamo/bob/dialect/psql"
+	"github.com/stephenafamo/bob/dialect/psql/dialect"
+	"github.com/stephenafamo/bob/dialect/psql/sm"
+	bobpgx "github.com/stephenafamo/bob/drivers/pgx"
+	"github.com/stephenafamo/scan"
+)
+
+// likeEscaper escapes the wildcards of a LIKE pattern.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// IotAlertRepository reads the alerts stored in iot_alert_events.
+type IotAlertRepository struct {
+	db bobpgx.Pool
+}
+
+var _ iotalerts.IotAlertRepository = (*IotAlertRepository)(nil)
+
+func NewIotAlertRepository(db bobpgx.Pool) *IotAlertRepository {
+	return &IotAlertRepository{
+		db: db,
+	}
+}
+
+func (r *IotAlertRepository) FindBySpec(ctx context.Context, spec iotalerts.AlertSpec, page iotalerts.AlertPage) ([]*iotalerts.IotAlert, error) {
+	events, err := findAlertsQuery(spec, page).All(ctx, r.db)
+	if err != nil {
+		return nil, err
+	}
+
+	alerts := make([]*iotalerts.IotAlert, len(events))
+	for i, e := range events {
+		alerts[i] = &iotalerts.IotAlert{
+			Time:      e.Time,
+			DeviceID:  e.DeviceID,
+			AlertType: e.AlertType,
+			Severity:  e.Severity,
+			Message:   e.Message,
+		}
+		if v, ok := e.CurrentValue.Get(); ok {
+			value := v.InexactFloat64()
+			alerts[i].CurrentValue = &value
+		}
+	}
+	return alerts, nil
+}
+
+func (r *IotAlertRepository) CountBySpec(ctx context.Context, spec iotalerts.AlertSpec) (iotalerts.AlertCounts, error) {
+	type row struct {
+		Severity  string `db:"severity"`
+		AlertType string `db:"alert_type"`
+		Count     int64  `db:"count"`
+	}
+	rows, err := bob.All(ctx, r.db, countAlertsQuery(spec), scan.StructMapper[row]())
+	if err != nil {
+		return iotalerts.AlertCounts{}, err
+	}
+
+	counts := iotalerts.AlertCounts{
+		BySeverity: make(map[string]int64),
+		ByType:     make(map[string]int64),
+	}
+	for _, c := range rows {
+		counts.Total += c.Count
+		counts.BySeverity[c.Severity] += c.Count
+		counts.ByType[c.AlertType] += c.Count
+	}
+	return counts, nil
+}
+
+// findAlertsQuery returns the query selecting the page of the alerts of
+// spec.
+func findAlertsQuery(spec iotalerts.AlertSpec, page iotalerts.AlertPage) models.IotAlertEventsQuery {
+	mods := alertSpecMods(spec)
+	mods = append(mods, alertOrderMods(page)...)
+	if page.Limit > 0 {
+		mods = append(mods, sm.Limit(page.Limit))
+	}
+	if page.Offset > 0 {
+		mods = append(mods, sm.Offset(page.Offset))
+	}
+	return models.IotAlertEvents.Query(mods...)
+}
+
+// countAlertsQuery returns the query counting the alerts of spec by severity
+// and alert type.
+func countAlertsQuery(spec iotalerts.AlertSpec) bob.BaseQuery[*dialect.SelectQuery] {
+	cols := models.IotAlertEvents.Columns
+	mods := []bob.Mod[*dialect.SelectQuery]{
+		sm.Columns(cols.Severity, cols.AlertType, psql.Raw("count(*)").As("count")),
+		sm.From(models.IotAlertEvents.NameAs()),
+		sm.GroupBy(cols.Severity),
+		sm.GroupBy(cols.AlertType),
+	}
+	return psql.Select(append(mods, alertSpecMods(spec)...)...)
+}
+
+// alertSpecMods returns the conditions selecting the alerts of spec.
+func alertSpecMods(spec iotalerts.AlertSpec) []bob.Mod[*dialect.SelectQuery] {
+	where := models.SelectWhere.IotAlertEvents
+	var mods []bob.Mod[*dialect.SelectQuery]
+	if spec.DeviceID != "" {
+		mods = append(mods, where.DeviceID.EQ(spec.DeviceID))
+	}
+	if len(spec.AlertTypes) > 0 {
+		types := make([]string, len(spec.AlertTypes))
+		for i, t := range spec.AlertTypes {
+			types[i] = t.String()
+		}
+		mods = append(mods, where.AlertType.In(types...))
+	}
+	if len(spec.Severities) > 0 {
+		severities := make([]string, len(spec.Severities))
+		for i, s := range spec.Severities {
+			severities[i] = s.String()
+		}
+		mods = append(mods, where.Severity.In(severities...))
+	}
+	if !spec.From.IsZero() {
+		mods = append(mods, where.Time.GTE(spec.From))
+	}
+	if !spec.To.IsZero() {
+		mods = append(mods, where.Time.LT(spec.To))
+	}
+	if spec.Text != "" {
+		mods = append(mods, where.Message.ILike("%"+likeEscaper.Replace(spec.Text)+"%"))
+	}
+	return mods
+}
+
+// alertOrderMods sorts alerts as asked by page, then by time, latest first,
+// device and type so that pages do not overlap.
+func alertOrderMods(page iotalerts.AlertPage) []bob.Mod[*dialect.SelectQuery] {
+	cols := models.IotAlertEvents.Columns
+
+	var by any
+	switch page.SortBy {
+	case iotalerts.SortBySeverity:
+		// Severities sort by rank rather than by name.
+		by = severityRank(cols.Severity)
+	case iotalerts.SortByDeviceID:
+		by = cols.DeviceID
+	case iotalerts.SortByAlertType:
+		by = cols.AlertType
+	default:
+		by = cols.Time
+	}
+
+	order := sm.OrderBy(by)
+	if page.Descending {
+		order = order.Desc()
+	} else {
+		order = order.Asc()
+	}
+	return []bob.Mod[*dialect.SelectQuery]{
+		order,
+		sm.OrderBy(cols.Time).Desc(),
+		sm.OrderBy(cols.DeviceID).Asc(),
+		sm.OrderBy(cols.AlertType).Asc(),
+	}
+}
+
+// severityRank returns the rank of the severity in column, from LOW to
+// CRITICAL.
+func severityRank(column psql.Expression) psql.Expression {
+	rank := psql.Case()
+	for _, s := range []iotalerts.Severity{iotalerts.LOW, iotalerts.MEDIUM, iotalerts.HIGH, iotalerts.CRITICAL} {
+		rank = rank.When(column.EQ(psql.S(s.String())), psql.Raw(strconv.Itoa(int(s))))
+	}
+	return rank.End()
+}
@@ -0,0 +1,142 @@
+package repositories
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	iotalerts "iiot_system/backend/internal/domain/iot/iot_alerts"
+)
+
+func TestAlertSpecQueries(t *testing.T) {
+	from := time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+
+	for _, tt := range []struct {
+		name  string
+		spec  iotalerts.AlertSpec
+		where string
+		args  []any
+	}{
+		{
+			name: "everything",
+		},
+		{
+			name: "device and severities",
+			spec: iotalerts.AlertSpec{
+				DeviceID:   "press-1",
+				Severities: []iotalerts.Severity{iotalerts.HIGH, iotalerts.CRITICAL},
+			},
+			where: `WHERE ("iot_alert_events"."device_id" = $1) AND ("iot_alert_events"."severity" IN ($2, $3))`,
+			args:  []any{"press-1", "HIGH", "CRITICAL"},
+		},
+		{
+			name: "alert types and time range",
+			spec: iotalerts.AlertSpec{
+				AlertTypes: []iotalerts.AlertType{iotalerts.VIBRATION_EXCEEDED_THRESHOLD},
+				From:       from,
+				To:         to,
+			},
+			where: `WHERE ("iot_alert_events"."alert_type" IN ($1)) AND ("iot_alert_events"."time" >= $2) AND ("iot_alert_events"."time" < $3)`,
+			args:  []any{"VIBRATION_EXCEEDED_THRESHOLD", from, to},
+		},
+		{
+			name:  "open range",
+			spec:  iotalerts.AlertSpec{From: from},
+			where: `WHERE ("iot_alert_events"."time" >= $1)`,
+			args:  []any{from},
+		},
+		{
+			name:  "escaped text",
+			spec:  iotalerts.AlertSpec{Text: `50%_x\`},
+			where: `WHERE "iot_alert_events"."message" ILIKE $1`,
+			args:  []any{`%50\%\_x\\%`},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			find, findArgs, err := findAlertsQuery(tt.spec, iotalerts.AlertPage{}).Build(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			count, countArgs, err := countAlertsQuery(tt.spec).Build(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, sql := range []string{find, count} {
+				if tt.where == "" && strings.Contains(sql, "WHERE") {
+					t.Errorf("unrestricted query has a WHERE clause:\n%s", sql)
+				}
+				if !strings.Contains(sql, tt.where) {
+					t.Errorf("query does not contain %s:\n%s", tt.where, sql)
+				}
+			}
+			if !reflect.DeepEqual(findArgs, tt.args) || !reflect.DeepEqual(countArgs, tt.args) {
+				t.Errorf("arguments %v and %v, want %v", findArgs, countArgs, tt.args)
+			}
+			if !strings.Contains(count, `GROUP BY "iot_alert_events"."severity", "iot_alert_events"."alert_type"`) {
+				t.Errorf("count is not grouped by severity and alert type:\n%s", count)
+			}
+		})
+	}
+}
+
+func TestAlertPageQueries(t *testing.T) {
+	// Ties are broken the same whatever the alerts are sorted by.
+	const ties = `"iot_alert_events"."time" DESC, "iot_alert_events"."device_id" ASC, "iot_alert_events"."alert_type" ASC`
+
+	for _, tt := range []struct {
+		name  string
+		page  iotalerts.AlertPage
+		order string
+		limit string
+	}{
+		{
+			name:  "default",
+			order: `ORDER BY "iot_alert_events"."time" ASC, ` + ties,
+		},
+		{
+			name:  "latest first",
+			page:  iotalerts.AlertPage{SortBy: iotalerts.SortByTime, Descending: true, Limit: 50},
+			order: `ORDER BY "iot_alert_events"."time" DESC, ` + ties,
+			limit: "LIMIT 50",
+		},
+		{
+			name: "severity rank",
+			page: iotalerts.AlertPage{SortBy: iotalerts.SortBySeverity, Descending: true, Limit: 10, Offset: 20},
+			order: `ORDER BY (CASE WHEN ("iot_alert_events"."severity" = 'LOW') THEN 0` +
+				` WHEN ("iot_alert_events"."severity" = 'MEDIUM') THEN 1` +
+				` WHEN ("iot_alert_events"."severity" = 'HIGH') THEN 2` +
+				` WHEN ("iot_alert_events"."severity" = 'CRITICAL') THEN 3 END) DESC, ` + ties,
+			limit: "LIMIT 10\nOFFSET 20",
+		},
+		{
+			name:  "device",
+			page:  iotalerts.AlertPage{SortBy: iotalerts.SortByDeviceID},
+			order: `ORDER BY "iot_alert_events"."device_id" ASC, ` + ties,
+		},
+		{
+			name:  "alert type",
+			page:  iotalerts.AlertPage{SortBy: iotalerts.SortByAlertType, Descending: true},
+			order: `ORDER BY "iot_alert_events"."alert_type" DESC, ` + ties,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, _, err := findAlertsQuery(iotalerts.AlertSpec{}, tt.page).Build(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(sql, tt.order) {
+				t.Errorf("query does not contain %s:\n%s", tt.order, sql)
+			}
+			if tt.limit == "" && (strings.Contains(sql, "LIMIT") || strings.Contains(sql, "OFFSET")) {
+				t.Errorf("unpaged query is limited:\n%s", sql)
+			}
+			if !strings.Contains(sql, tt.limit) {
+				t.Errorf("query does not contain %s:\n%s", tt.limit, sql)
+			}
+		})
+	}
+}
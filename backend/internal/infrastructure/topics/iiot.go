@@ -1,8 +1,44 @@
 package topics
 
+// Pipelines consuming device events.
 const (
-	AlertsTopic       = "iiot.alerts"
-	ProductionTopic   = "iiot.production"
-	StatusUpdateTopic = "iiot.status_update"
-	TelemetryTopic    = "iiot.telemetry"
+	AlertsPipeline       = "alerts"
+	ProductionPipeline   = "production"
+	StatusUpdatePipeline = "status_update"
+	TelemetryPipeline    = "telemetry"
 )
+
+// Pipelines lists every pipeline a Mapping must subscribe.
+var Pipelines = []string{
+	AlertsPipeline,
+	ProductionPipeline,
+	StatusUpdatePipeline,
+	TelemetryPipeline,
+}
+
+// Default topics the EMQX rules in emqx_broker/bootstrap_emqx.sh produce the
+// events of each pipeline to.
+const (
+	AlertsTopic       = "oee.alerts"
+	ProductionTopic   = "oee.production"
+	StatusUpdateTopic = "oee.status_updates"
+	TelemetryTopic    = "oee.telemetry"
+)
+
+// defaultTopicPipelines maps every default topic to the pipeline consuming it.
+var defaultTopicPipelines = map[string]string{
+	AlertsTopic:       AlertsPipeline,
+	ProductionTopic:   ProductionPipeline,
+	StatusUpdateTopic: StatusUpdatePipeline,
+	TelemetryTopic:    TelemetryPipeline,
+}
+
+// DefaultMapping subscribes every pipeline to its default topic.
+func DefaultMapping() Mapping {
+	return Mapping{
+		AlertsPipeline:       {{Topic: AlertsTopic}},
+		ProductionPipeline:   {{Topic: ProductionTopic}},
+		StatusUpdatePipeline: {{Topic: StatusUpdateTopic}},
+		TelemetryPipeline:    {{Topic: TelemetryTopic}},
+	}
+}
@@ -0,0 +1,139 @@
+package topics
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Subscription is a topic a pipeline consumes, or a regular expression
+// matching the topics it consumes.
+type Subscription struct {
+	Topic string
+	Regex bool
+}
+
+// ParseSubscription parses a topic name, or a regular expression between
+// slashes such as `/^oee\.telemetry\..+$/`.
+func ParseSubscription(s string) (Subscription, error) {
+	s = strings.TrimSpace(s)
+	if len(s) > 2 && strings.HasPrefix(s, "/") && strings.HasSuffix(s, "/") {
+		pattern := s[1 : len(s)-1]
+		if _, err := regexp.Compile(pattern); err != nil {
+			return Subscription{}, fmt.Errorf("invalid topic pattern %s: %w", s, err)
+		}
+		return Subscription{Topic: pattern, Regex: true}, nil
+	}
+
+	if s == "" {
+		return Subscription{}, errors.New("empty topic")
+	}
+	return Subscription{Topic: s}, nil
+}
+
+func (s Subscription) String() string {
+	if s.Regex {
+		return "/" + s.Topic + "/"
+	}
+	return s.Topic
+}
+
+func (s Subscription) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Subscription) UnmarshalText(text []byte) error {
+	sub, err := ParseSubscription(string(text))
+	if err != nil {
+		return err
+	}
+	*s = sub
+	return nil
+}
+
+// Pattern returns the subscription as a regular expression matching whole
+// topic names.
+func (s Subscription) Pattern() string {
+	if s.Regex {
+		return s.Topic
+	}
+	return "^" + regexp.QuoteMeta(s.Topic) + "$"
+}
+
+// Mapping holds the subscriptions of each pipeline.
+type Mapping map[string][]Subscription
+
+// ParseMapping parses comma separated `pipeline=topic` entries. A pipeline
+// listed several times consumes all of its topics, for example
+// `telemetry=oee.telemetry,telemetry=/^site\d+\.telemetry$/`. A default topic
+// without a pipeline, as in the plain topic lists KAFKA_TOPICS used to hold,
+// is consumed by its default pipeline.
+func ParseMapping(s string) (Mapping, error) {
+	m := make(Mapping)
+	var errs []error
+	for entry := range strings.SplitSeq(s, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		pipeline, topic, ok := strings.Cut(entry, "=")
+		if !ok {
+			topic = strings.TrimSpace(entry)
+			if pipeline, ok = defaultTopicPipelines[topic]; !ok {
+				errs = append(errs, fmt.Errorf("topic %q is not mapped to a pipeline, expected pipeline=topic entries such as %s=%s", topic, TelemetryPipeline, TelemetryTopic))
+				continue
+			}
+		}
+
+		sub, err := ParseSubscription(topic)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("pipeline %s: %w", strings.TrimSpace(pipeline), err))
+			continue
+		}
+
+		pipeline = strings.TrimSpace(pipeline)
+		m[pipeline] = append(m[pipeline], sub)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return m, m.Validate()
+}
+
+// Validate checks that every pipeline is subscribed to a topic and that no
+// unknown pipeline is mapped.
+func (m Mapping) Validate() error {
+	var errs []error
+	for _, pipeline := range Pipelines {
+		if len(m[pipeline]) == 0 {
+			errs = append(errs, fmt.Errorf("pipeline %s is not mapped to a topic", pipeline))
+		}
+	}
+	for pipeline := range m {
+		if !slices.Contains(Pipelines, pipeline) {
+			errs = append(errs, fmt.Errorf("unknown pipeline %s", pipeline))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m Mapping) String() string {
+	var entries []string
+	for _, pipeline := range slices.Sorted(maps.Keys(m)) {
+		for _, sub := range m[pipeline] {
+			entries = append(entries, pipeline+"="+sub.String())
+		}
+	}
+	return strings.Join(entries, ",")
+}
+
+// HasRegex reports whether any of subs is a regular expression.
+func HasRegex(subs []Subscription) bool {
+	return slices.ContainsFunc(subs, func(s Subscription) bool {
+		return s.Regex
+	})
+}
@@ -0,0 +1,139 @@
+package topics
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSubscription(t *testing.T) {
+	for _, tt := range []struct {
+		in      string
+		want    Subscription
+		pattern string
+		err     string
+	}{
+		{in: "oee.telemetry", want: Subscription{Topic: "oee.telemetry"}, pattern: `^oee\.telemetry$`},
+		{in: "  oee.alerts ", want: Subscription{Topic: "oee.alerts"}, pattern: `^oee\.alerts$`},
+		{in: `/^site\d+\.telemetry$/`, want: Subscription{Topic: `^site\d+\.telemetry$`, Regex: true}, pattern: `^site\d+\.telemetry$`},
+		// A lone slash is a topic name, not an empty pattern.
+		{in: "/", want: Subscription{Topic: "/"}, pattern: `^/$`},
+		{in: "/[/", err: "invalid topic pattern"},
+		{in: " ", err: "empty topic"},
+	} {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseSubscription(tt.in)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("ParseSubscription(%q) = %v, want an error containing %q", tt.in, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want || got.Pattern() != tt.pattern {
+				t.Errorf("ParseSubscription(%q) = %+v with pattern %s, want %+v with pattern %s", tt.in, got, got.Pattern(), tt.want, tt.pattern)
+			}
+			if again, err := ParseSubscription(got.String()); err != nil || again != got {
+				t.Errorf("%s does not parse back: %+v, %v", got, again, err)
+			}
+		})
+	}
+}
+
+func TestParseMapping(t *testing.T) {
+	const defaults = "alerts=oee.alerts,production=oee.production,status_update=oee.status_updates,telemetry=oee.telemetry"
+
+	for _, tt := range []struct {
+		name string
+		in   string
+		want Mapping
+		errs []string
+	}{
+		{
+			name: "topics",
+			in:   defaults,
+			want: DefaultMapping(),
+		},
+		{
+			name: "regex",
+			in:   `alerts=oee.alerts, production=oee.production, status_update=oee.status_updates, telemetry=/^site\d+\.telemetry$/`,
+			want: Mapping{
+				AlertsPipeline:       {{Topic: AlertsTopic}},
+				ProductionPipeline:   {{Topic: ProductionTopic}},
+				StatusUpdatePipeline: {{Topic: StatusUpdateTopic}},
+				TelemetryPipeline:    {{Topic: `^site\d+\.telemetry$`, Regex: true}},
+			},
+		},
+		{
+			name: "pipeline listed twice",
+			in:   defaults + `,telemetry=/^site\d+\.telemetry$/`,
+			want: Mapping{
+				AlertsPipeline:       {{Topic: AlertsTopic}},
+				ProductionPipeline:   {{Topic: ProductionTopic}},
+				StatusUpdatePipeline: {{Topic: StatusUpdateTopic}},
+				TelemetryPipeline:    {{Topic: TelemetryTopic}, {Topic: `^site\d+\.telemetry$`, Regex: true}},
+			},
+		},
+		{
+			name: "legacy topic list",
+			in:   "oee.alerts,oee.production,oee.status_updates,oee.telemetry",
+			want: DefaultMapping(),
+		},
+		{
+			name: "legacy and pipeline entries",
+			in:   "oee.alerts,oee.production,oee.status_updates,telemetry=site1.telemetry",
+			want: Mapping{
+				AlertsPipeline:       {{Topic: AlertsTopic}},
+				ProductionPipeline:   {{Topic: ProductionTopic}},
+				StatusUpdatePipeline: {{Topic: StatusUpdateTopic}},
+				TelemetryPipeline:    {{Topic: "site1.telemetry"}},
+			},
+		},
+		{
+			name: "unmapped topic",
+			in:   defaults + ",site1.telemetry",
+			errs: []string{`topic "site1.telemetry" is not mapped to a pipeline, expected pipeline=topic entries such as telemetry=oee.telemetry`},
+		},
+		{
+			name: "unknown pipeline",
+			in:   defaults + ",energy=oee.energy",
+			errs: []string{"unknown pipeline energy"},
+		},
+		{
+			name: "missing pipelines",
+			in:   "telemetry=oee.telemetry",
+			errs: []string{"pipeline alerts is not mapped", "pipeline production is not mapped", "pipeline status_update is not mapped"},
+		},
+		{
+			name: "invalid entries",
+			in:   defaults + ",telemetry=,alerts=/[/",
+			errs: []string{"pipeline telemetry: empty topic", "pipeline alerts: invalid topic pattern"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMapping(tt.in)
+			if len(tt.errs) > 0 {
+				if err == nil {
+					t.Fatalf("ParseMapping(%q) = %v, want an error", tt.in, got)
+				}
+				for _, want := range tt.errs {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("error does not report %q:\n%v", want, err)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseMapping(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+			if again, err := ParseMapping(got.String()); err != nil || !reflect.DeepEqual(again, got) {
+				t.Errorf("%s does not parse back: %v, %v", got, again, err)
+			}
+		})
+	}
+}
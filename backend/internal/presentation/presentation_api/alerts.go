@@ -0,0 +1,121 @@
+package presentation_api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	application_iot "iiot_system/backend/internal/application/iot"
+	iotalerts "iiot_system/backend/internal/domain/iot/iot_alerts"
+
+	"github.com/labstack/echo/v4"
+)
+
+// AlertsQuerier searches the alert history, typically an application query
+// handler.
+type AlertsQuerier interface {
+	Handle(ctx context.Context, q application_iot.AlertsQuery) (application_iot.AlertsPage, error)
+}
+
+// AlertsHandler serves the alert history of the devices.
+type AlertsHandler struct {
+	alerts AlertsQuerier
+}
+
+func NewAlertsHandler(alerts AlertsQuerier) *AlertsHandler {
+	return &AlertsHandler{
+		alerts: alerts,
+	}
+}
+
+// Register adds the alert routes to g.
+func (h *AlertsHandler) Register(g *echo.Group) {
+	g.GET("/alerts", h.list)
+}
+
+type alertResponse struct {
+	Time         time.Time `json:"time"`
+	DeviceID     string    `json:"device_id"`
+	AlertType    string    `json:"alert_type"`
+	Severity     string    `json:"severity"`
+	Message      string    `json:"message"`
+	CurrentValue *float64  `json:"current_value"`
+}
+
+type alertCountsResponse struct {
+	BySeverity map[string]int64 `json:"by_severity"`
+	ByType     map[string]int64 `json:"by_type"`
+}
+
+type alertsResponse struct {
+	Alerts []alertResponse     `json:"alerts"`
+	Total  int64               `json:"total"`
+	Limit  int                 `json:"limit"`
+	Offset int                 `json:"offset"`
+	Counts alertCountsResponse `json:"counts"`
+}
+
+// list returns a page of the alerts matching the device_id, type, severity,
+// from, to and q query parameters. Types and severities may be lists. The
+// page is sorted by the sort parameter, such as severity or -time for the
+// latest first, and the counts cover every matching alert.
+func (h *AlertsHandler) list(c echo.Context) error {
+	var q application_iot.AlertsQuery
+	q.Spec.DeviceID = c.QueryParam("device_id")
+	q.Spec.Text = c.QueryParam("q")
+	for _, name := range listParam(c, "type") {
+		t, err := iotalerts.AlertTypeFromString(strings.ToUpper(name))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		q.Spec.AlertTypes = append(q.Spec.AlertTypes, t)
+	}
+	for _, name := range listParam(c, "severity") {
+		s, err := iotalerts.SeverityFromString(strings.ToUpper(name))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		q.Spec.Severities = append(q.Spec.Severities, s)
+	}
+
+	var err error
+	if q.Spec.From, err = timeParam(c, "from"); err != nil {
+		return err
+	}
+	if q.Spec.To, err = timeParam(c, "to"); err != nil {
+		return err
+	}
+	if q.Page.Limit, err = intParam(c, "limit"); err != nil {
+		return err
+	}
+	if q.Page.Offset, err = intParam(c, "offset"); err != nil {
+		return err
+	}
+	if sort := c.QueryParam("sort"); sort != "" {
+		q.Page.Descending = strings.HasPrefix(sort, "-")
+		if q.Page.SortBy, err = iotalerts.AlertSortFieldFromString(strings.TrimPrefix(sort, "-")); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+	}
+
+	page, err := h.alerts.Handle(c.Request().Context(), q)
+	if err != nil {
+		return queryError(c, err)
+	}
+
+	res := alertsResponse{
+		Alerts: make([]alertResponse, len(page.Alerts)),
+		Total:  page.Counts.Total,
+		Limit:  page.Limit,
+		Offset: page.Offset,
+		Counts: alertCountsResponse{
+			BySeverity: page.Counts.BySeverity,
+			ByType:     page.Counts.ByType,
+		},
+	}
+	for i, a := range page.Alerts {
+		res.Alerts[i] = alertResponse(*a)
+	}
+	return c.JSON(http.StatusOK, res)
+}
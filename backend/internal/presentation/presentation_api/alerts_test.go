@@ -0,0 +1,115 @@
+package presentation_api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	application_iot "iiot_system/backend/internal/application/iot"
+	iotalerts "iiot_system/backend/internal/domain/iot/iot_alerts"
+
+	"github.com/labstack/echo/v4"
+)
+
+// fakeAlertRepository holds alerts in memory, filtering them by device and
+// severity only.
+type fakeAlertRepository struct {
+	alerts []*iotalerts.IotAlert
+	page   iotalerts.AlertPage
+}
+
+func (r *fakeAlertRepository) FindBySpec(_ context.Context, spec iotalerts.AlertSpec, page iotalerts.AlertPage) ([]*iotalerts.IotAlert, error) {
+	r.page = page
+	found := r.matching(spec)
+	found = found[min(page.Offset, len(found)):]
+	return found[:min(page.Limit, len(found))], nil
+}
+
+func (r *fakeAlertRepository) CountBySpec(_ context.Context, spec iotalerts.AlertSpec) (iotalerts.AlertCounts, error) {
+	counts := iotalerts.AlertCounts{BySeverity: map[string]int64{}, ByType: map[string]int64{}}
+	for _, a := range r.matching(spec) {
+		counts.Total++
+		counts.BySeverity[a.Severity]++
+		counts.ByType[a.AlertType]++
+	}
+	return counts, nil
+}
+
+func (r *fakeAlertRepository) matching(spec iotalerts.AlertSpec) []*iotalerts.IotAlert {
+	var found []*iotalerts.IotAlert
+	for _, a := range r.alerts {
+		if spec.DeviceID != "" && a.DeviceID != spec.DeviceID {
+			continue
+		}
+		severity, _ := iotalerts.SeverityFromString(a.Severity)
+		if len(spec.Severities) > 0 && !slices.Contains(spec.Severities, severity) {
+			continue
+		}
+		found = append(found, a)
+	}
+	return found
+}
+
+func TestAlerts(t *testing.T) {
+	now := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	value := 12.5
+	repo := &fakeAlertRepository{alerts: []*iotalerts.IotAlert{
+		{Time: now, DeviceID: "press-1", AlertType: "TEMPERATURE_CRITICAL", Severity: "CRITICAL", Message: "overheating", CurrentValue: &value},
+		{Time: now, DeviceID: "press-1", AlertType: "VIBRATION_EXCEEDED_THRESHOLD", Severity: "HIGH", Message: "shaking"},
+		{Time: now, DeviceID: "press-1", AlertType: "SENSOR_OFFLINE", Severity: "LOW", Message: "offline"},
+		{Time: now, DeviceID: "press-2", AlertType: "SENSOR_OFFLINE", Severity: "HIGH", Message: "offline"},
+	}}
+	e := echo.New()
+	NewAlertsHandler(application_iot.NewAlertsQueryHandler(repo)).Register(e.Group("/api/v1"))
+
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	rec := get("/api/v1/alerts?device_id=press-1&severity=critical,high&limit=1&offset=1&sort=severity")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var res alertsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 2 || len(res.Alerts) != 1 || res.Limit != 1 || res.Offset != 1 {
+		t.Fatalf("got %s", rec.Body)
+	}
+	if res.Counts.BySeverity["CRITICAL"] != 1 || res.Counts.BySeverity["HIGH"] != 1 || res.Counts.ByType["SENSOR_OFFLINE"] != 0 {
+		t.Fatalf("counts %+v", res.Counts)
+	}
+	if repo.page.SortBy != iotalerts.SortBySeverity || repo.page.Descending {
+		t.Fatalf("sorted by %+v", repo.page)
+	}
+
+	rec = get("/api/v1/alerts")
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 4 || res.Limit != application_iot.DefaultQueryLimit {
+		t.Fatalf("got %s", rec.Body)
+	}
+	if repo.page.SortBy != iotalerts.SortByTime || !repo.page.Descending {
+		t.Fatalf("not sorted by time, latest first, by default: %+v", repo.page)
+	}
+
+	for _, query := range []string{
+		"severity=urgent",
+		"type=smoke",
+		"sort=message",
+		"from=2026-10-02T00:00:00Z&to=2026-10-01T00:00:00Z",
+		"limit=100000",
+	} {
+		if rec := get("/api/v1/alerts?" + query); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want %d", query, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
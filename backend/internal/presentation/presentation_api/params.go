@@ -0,0 +1,154 @@
+package presentation_api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	application_iot "iiot_system/backend/internal/application/iot"
+
+	"github.com/labstack/echo/v4"
+)
+
+// defaultRange is how far back a query without a start looks.
+const defaultRange = 24 * time.Hour
+
+// mimeNDJSON streams one JSON document per line.
+const mimeNDJSON = "application/x-ndjson"
+
+// nextCursorHeader holds the cursor of the next page of NDJSON responses,
+// which have no envelope to hold it.
+const nextCursorHeader = "X-Next-Cursor"
+
+// timeRange parses the from and to query parameters, RFC 3339 times. The
+// range ends now and spans defaultRange unless they say otherwise.
+func timeRange(c echo.Context) (application_iot.TimeRange, error) {
+	from, err := timeParam(c, "from")
+	if err != nil {
+		return application_iot.TimeRange{}, err
+	}
+	to, err := timeParam(c, "to")
+	if err != nil {
+		return application_iot.TimeRange{}, err
+	}
+
+	if to.IsZero() {
+		to = time.Now().UTC()
+	}
+	if from.IsZero() {
+		from = to.Add(-defaultRange)
+	}
+	return application_iot.TimeRange{From: from, To: to}, nil
+}
+
+// timeParam parses the query parameter name, an RFC 3339 time, zero when
+// absent.
+func timeParam(c echo.Context, name string) (time.Time, error) {
+	s := c.QueryParam(name)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid %s: %s", name, s))
+	}
+	return t, nil
+}
+
+// intParam parses the query parameter name, zero when absent.
+func intParam(c echo.Context, name string) (int, error) {
+	s := c.QueryParam(name)
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid %s: %s", name, s))
+	}
+	return n, nil
+}
+
+// listParam returns the comma separated values of the query parameter name,
+// which may be repeated.
+func listParam(c echo.Context, name string) []string {
+	var values []string
+	for _, param := range c.QueryParams()[name] {
+		for v := range strings.SplitSeq(param, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+	return values
+}
+
+// encodeCursor returns the opaque cursor resuming a page after t.
+func encodeCursor(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(t.UTC().Format(time.RFC3339Nano)))
+}
+
+// decodeCursor parses the cursor query parameter, zero when absent.
+func decodeCursor(c echo.Context) (time.Time, error) {
+	s := c.QueryParam("cursor")
+	if s == "" {
+		return time.Time{}, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return time.Time{}, echo.NewHTTPError(http.StatusBadRequest, "invalid cursor")
+	}
+	t, err := time.Parse(time.RFC3339Nano, string(b))
+	if err != nil {
+		return time.Time{}, echo.NewHTTPError(http.StatusBadRequest, "invalid cursor")
+	}
+	return t, nil
+}
+
+// wantsNDJSON reports whether the client asked for NDJSON, with the format
+// query parameter or the Accept header.
+func wantsNDJSON(c echo.Context) bool {
+	if format := c.QueryParam("format"); format != "" {
+		return format == "ndjson"
+	}
+	return strings.Contains(c.Request().Header.Get(echo.HeaderAccept), mimeNDJSON)
+}
+
+// writeNDJSON streams docs, one per line.
+func writeNDJSON[T any](c echo.Context, docs []T) error {
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, mimeNDJSON)
+	res.WriteHeader(http.StatusOK)
+
+	enc := json.NewEncoder(res)
+	for _, doc := range docs {
+		if err := enc.Encode(doc); err != nil {
+			return err
+		}
+	}
+	res.Flush()
+	return nil
+}
+
+// queryError turns the error of a query into the response to send: invalid
+// queries are the fault of the client, missing resources are not found and
+// anything else is logged.
+func queryError(c echo.Context, err error) error {
+	if errors.Is(err, application_iot.ErrInvalidQuery) {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if errors.Is(err, application_iot.ErrNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	slog.ErrorContext(c.Request().Context(), "query failed",
+		"method", c.Request().Method, "route", c.Path(), "error", err)
+	return echo.NewHTTPError(http.StatusInternalServerError, "query failed")
+}
@@ -0,0 +1,212 @@
+package presentation_api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	application_iot "iiot_system/backend/internal/application/iot"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ProductionEventsQuerier searches the production events, typically an
+// application query handler.
+type ProductionEventsQuerier interface {
+	Handle(ctx context.Context, q application_iot.ProductionEventsQuery) (application_iot.ProductionEventsPage, error)
+}
+
+// ProductionBatchQuerier traces a production batch, typically an application
+// query handler.
+type ProductionBatchQuerier interface {
+	Handle(ctx context.Context, batchID string) (application_iot.ProductionBatch, error)
+}
+
+// ProductionRollupQuerier sums the production by period, typically an
+// application query handler.
+type ProductionRollupQuerier interface {
+	Handle(ctx context.Context, q application_iot.ProductionRollupQuery) ([]application_iot.ProductionRollup, error)
+}
+
+// ProductionHandler serves the production output of the devices.
+type ProductionHandler struct {
+	events  ProductionEventsQuerier
+	batches ProductionBatchQuerier
+	rollups ProductionRollupQuerier
+}
+
+func NewProductionHandler(events ProductionEventsQuerier, batches ProductionBatchQuerier, rollups ProductionRollupQuerier) *ProductionHandler {
+	return &ProductionHandler{
+		events:  events,
+		batches: batches,
+		rollups: rollups,
+	}
+}
+
+// Register adds the production routes to g.
+func (h *ProductionHandler) Register(g *echo.Group) {
+	g.GET("/production", h.list)
+	g.GET("/production/batches/:batch_id", h.batch)
+	g.GET("/production/rollup", h.rollup)
+}
+
+type productionEventResponse struct {
+	Time           time.Time `json:"time"`
+	DeviceID       string    `json:"device_id"`
+	ProductionType string    `json:"production_type"`
+	SKU            string    `json:"sku"`
+	UnitCount      int32     `json:"unit_count"`
+	BatchID        string    `json:"batch_id"`
+	QualityStatus  string    `json:"quality_status"`
+}
+
+type productionEventsResponse struct {
+	From   time.Time                 `json:"from"`
+	To     time.Time                 `json:"to"`
+	Events []productionEventResponse `json:"events"`
+	Total  int64                     `json:"total"`
+	Limit  int                       `json:"limit"`
+	Offset int                       `json:"offset"`
+}
+
+// list returns a page of the production events within from and to, latest
+// first, matching the device_id, sku, batch_id and quality query parameters.
+func (h *ProductionHandler) list(c echo.Context) error {
+	q := application_iot.ProductionEventsQuery{
+		DeviceID:      c.QueryParam("device_id"),
+		SKU:           c.QueryParam("sku"),
+		BatchID:       c.QueryParam("batch_id"),
+		QualityStatus: c.QueryParam("quality"),
+	}
+
+	var err error
+	if q.Range, err = timeRange(c); err != nil {
+		return err
+	}
+	if q.Limit, err = intParam(c, "limit"); err != nil {
+		return err
+	}
+	if q.Offset, err = intParam(c, "offset"); err != nil {
+		return err
+	}
+
+	page, err := h.events.Handle(c.Request().Context(), q)
+	if err != nil {
+		return queryError(c, err)
+	}
+
+	res := productionEventsResponse{
+		From:   q.Range.From,
+		To:     q.Range.To,
+		Events: make([]productionEventResponse, len(page.Events)),
+		Total:  page.Total,
+		Limit:  page.Limit,
+		Offset: page.Offset,
+	}
+	for i, e := range page.Events {
+		res.Events[i] = productionEventResponse{
+			Time:           e.Time,
+			DeviceID:       e.DeviceID,
+			ProductionType: e.ProductionType,
+			SKU:            e.ProductSku,
+			UnitCount:      e.UnitCount,
+			BatchID:        e.BatchID,
+			QualityStatus:  e.QualityStatus,
+		}
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+type productionBatchDeviceResponse struct {
+	DeviceID string           `json:"device_id"`
+	First    time.Time        `json:"first"`
+	Last     time.Time        `json:"last"`
+	Events   int64            `json:"events"`
+	Units    int64            `json:"units"`
+	Quality  map[string]int64 `json:"quality"`
+}
+
+type productionBatchResponse struct {
+	BatchID    string                          `json:"batch_id"`
+	SKUs       []string                        `json:"skus"`
+	First      time.Time                       `json:"first"`
+	Last       time.Time                       `json:"last"`
+	Events     int64                           `json:"events"`
+	TotalUnits int64                           `json:"total_units"`
+	Quality    map[string]int64                `json:"quality"`
+	Devices    []productionBatchDeviceResponse `json:"devices"`
+}
+
+// batch returns the devices that produced a batch, when and how many units
+// of each quality.
+func (h *ProductionHandler) batch(c echo.Context) error {
+	b, err := h.batches.Handle(c.Request().Context(), c.Param("batch_id"))
+	if err != nil {
+		return queryError(c, err)
+	}
+
+	res := productionBatchResponse{
+		BatchID:    b.BatchID,
+		SKUs:       b.SKUs,
+		First:      b.First,
+		Last:       b.Last,
+		Events:     b.Events,
+		TotalUnits: b.TotalUnits,
+		Quality:    b.Quality,
+		Devices:    make([]productionBatchDeviceResponse, len(b.Devices)),
+	}
+	for i, d := range b.Devices {
+		res.Devices[i] = productionBatchDeviceResponse(d)
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+type productionRollupResponse struct {
+	PeriodStart time.Time        `json:"period_start"`
+	Shift       int              `json:"shift,omitempty"`
+	DeviceID    string           `json:"device_id"`
+	SKU         string           `json:"sku"`
+	Events      int64            `json:"events"`
+	Units       int64            `json:"units"`
+	Quality     map[string]int64 `json:"quality"`
+}
+
+type productionRollupsResponse struct {
+	From    time.Time                        `json:"from"`
+	To      time.Time                        `json:"to"`
+	Period  application_iot.ProductionPeriod `json:"period"`
+	Rollups []productionRollupResponse       `json:"rollups"`
+}
+
+// rollup returns the output of every SKU of every device within from and
+// to, by day or by shift as asked by the period query parameter.
+func (h *ProductionHandler) rollup(c echo.Context) error {
+	q := application_iot.ProductionRollupQuery{
+		DeviceID: c.QueryParam("device_id"),
+		SKU:      c.QueryParam("sku"),
+	}
+
+	var err error
+	if q.Range, err = timeRange(c); err != nil {
+		return err
+	}
+	if q.Period, err = application_iot.ParseProductionPeriod(c.QueryParam("period")); err != nil {
+		return queryError(c, err)
+	}
+
+	rollups, err := h.rollups.Handle(c.Request().Context(), q)
+	if err != nil {
+		return queryError(c, err)
+	}
+
+	res := productionRollupsResponse{
+		From:    q.Range.From,
+		To:      q.Range.To,
+		Period:  q.Period,
+		Rollups: make([]productionRollupResponse, len(rollups)),
+	}
+	for i, r := range rollups {
+		res.Rollups[i] = productionRollupResponse(r)
+	}
+	return c.JSON(http.StatusOK, res)
+}
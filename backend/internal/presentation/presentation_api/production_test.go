@@ -0,0 +1,134 @@
+package presentation_api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"iiot_system/backend/gen/models"
+	application_iot "iiot_system/backend/internal/application/iot"
+
+	"github.com/labstack/echo/v4"
+)
+
+type fakeProductionEvents struct {
+	q application_iot.ProductionEventsQuery
+}
+
+func (f *fakeProductionEvents) Handle(_ context.Context, q application_iot.ProductionEventsQuery) (application_iot.ProductionEventsPage, error) {
+	f.q = q
+	return application_iot.ProductionEventsPage{
+		Events: models.IotProductionEventSlice{
+			{Time: q.Range.From, DeviceID: "press-1", ProductSku: "SKU-1", UnitCount: 10, BatchID: "B-1", QualityStatus: "PASS"},
+		},
+		Total:  1,
+		Limit:  application_iot.DefaultQueryLimit,
+		Offset: q.Offset,
+	}, nil
+}
+
+// fakeProductionBatches knows batch B-1 only.
+type fakeProductionBatches struct{}
+
+func (fakeProductionBatches) Handle(_ context.Context, batchID string) (application_iot.ProductionBatch, error) {
+	if batchID != "B-1" {
+		return application_iot.ProductionBatch{}, fmt.Errorf("%w: batch %s", application_iot.ErrNotFound, batchID)
+	}
+	return application_iot.ProductionBatch{
+		BatchID:    batchID,
+		SKUs:       []string{"SKU-1"},
+		Events:     3,
+		TotalUnits: 30,
+		Quality:    map[string]int64{"PASS": 25, "FAIL": 5},
+		Devices: []application_iot.ProductionBatchDevice{
+			{DeviceID: "press-1", Events: 2, Units: 20, Quality: map[string]int64{"PASS": 20}},
+			{DeviceID: "press-2", Events: 1, Units: 10, Quality: map[string]int64{"PASS": 5, "FAIL": 5}},
+		},
+	}, nil
+}
+
+type fakeProductionRollups struct {
+	q application_iot.ProductionRollupQuery
+}
+
+func (f *fakeProductionRollups) Handle(_ context.Context, q application_iot.ProductionRollupQuery) ([]application_iot.ProductionRollup, error) {
+	f.q = q
+	return []application_iot.ProductionRollup{
+		{PeriodStart: q.Range.From, Shift: 1, DeviceID: "press-1", SKU: "SKU-1", Events: 2, Units: 20, Quality: map[string]int64{"PASS": 20}},
+	}, nil
+}
+
+func TestProduction(t *testing.T) {
+	events, rollups := &fakeProductionEvents{}, &fakeProductionRollups{}
+	e := echo.New()
+	NewProductionHandler(events, fakeProductionBatches{}, rollups).Register(e.Group("/api/v1"))
+
+	get := func(target string, v any) int {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rec.Code
+	}
+
+	var list productionEventsResponse
+	if code := get("/api/v1/production?sku=SKU-1&quality=PASS&offset=5&from=2026-10-01T00:00:00Z&to=2026-10-02T00:00:00Z", &list); code != http.StatusOK {
+		t.Fatalf("status %d", code)
+	}
+	if events.q.SKU != "SKU-1" || events.q.QualityStatus != "PASS" || events.q.Offset != 5 {
+		t.Fatalf("queried %+v", events.q)
+	}
+	if list.Total != 1 || len(list.Events) != 1 || list.Events[0].BatchID != "B-1" || list.Offset != 5 {
+		t.Fatalf("got %+v", list)
+	}
+
+	var batch productionBatchResponse
+	if code := get("/api/v1/production/batches/B-1", &batch); code != http.StatusOK {
+		t.Fatalf("status %d", code)
+	}
+	if batch.TotalUnits != 30 || len(batch.Devices) != 2 || batch.Devices[1].Quality["FAIL"] != 5 {
+		t.Fatalf("got %+v", batch)
+	}
+	if code := get("/api/v1/production/batches/B-2", nil); code != http.StatusNotFound {
+		t.Errorf("unknown batch: status %d, want %d", code, http.StatusNotFound)
+	}
+
+	var rollup productionRollupsResponse
+	if code := get("/api/v1/production/rollup?period=shift&device_id=press-1", &rollup); code != http.StatusOK {
+		t.Fatalf("status %d", code)
+	}
+	if rollups.q.Period != application_iot.ProductionByShift || rollups.q.DeviceID != "press-1" {
+		t.Fatalf("queried %+v", rollups.q)
+	}
+	if len(rollup.Rollups) != 1 || rollup.Rollups[0].Shift != 1 || rollup.Rollups[0].Units != 20 {
+		t.Fatalf("got %+v", rollup)
+	}
+	if code := get("/api/v1/production/rollup?period=week", nil); code != http.StatusBadRequest {
+		t.Errorf("unknown period: status %d, want %d", code, http.StatusBadRequest)
+	}
+}
+
+func TestProductionRollupDefaultsToDays(t *testing.T) {
+	rollups := &fakeProductionRollups{}
+	e := echo.New()
+	NewProductionHandler(&fakeProductionEvents{}, fakeProductionBatches{}, rollups).Register(e.Group("/api/v1"))
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/production/rollup", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if rollups.q.Period != application_iot.ProductionByDay {
+		t.Errorf("period %q, want %q", rollups.q.Period, application_iot.ProductionByDay)
+	}
+	if got := rollups.q.Range.To.Sub(rollups.q.Range.From); got != 24*time.Hour {
+		t.Errorf("range of %s, want the last day", got)
+	}
+}
@@ -0,0 +1,136 @@
+package presentation_api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	application_iot "iiot_system/backend/internal/application/iot"
+
+	"github.com/labstack/echo/v4"
+)
+
+// StatusTimelineQuerier returns the status intervals of a device, typically
+// an application query handler.
+type StatusTimelineQuerier interface {
+	Handle(ctx context.Context, q application_iot.StatusTimelineQuery) (application_iot.StatusTimeline, error)
+}
+
+// StatusSummaryQuerier summarizes the time spent in each status by the
+// devices, typically an application query handler.
+type StatusSummaryQuerier interface {
+	Handle(ctx context.Context, q application_iot.StatusSummaryQuery) ([]application_iot.StatusSummary, error)
+}
+
+// StatusHandler serves the machine status history of the devices.
+type StatusHandler struct {
+	timelines StatusTimelineQuerier
+	summaries StatusSummaryQuerier
+}
+
+func NewStatusHandler(timelines StatusTimelineQuerier, summaries StatusSummaryQuerier) *StatusHandler {
+	return &StatusHandler{
+		timelines: timelines,
+		summaries: summaries,
+	}
+}
+
+// Register adds the status routes to g.
+func (h *StatusHandler) Register(g *echo.Group) {
+	g.GET("/devices/status-summary", h.summary)
+	g.GET("/devices/:id/status-timeline", h.timeline)
+}
+
+type statusIntervalResponse struct {
+	Status          string    `json:"status"`
+	From            time.Time `json:"from"`
+	To              time.Time `json:"to"`
+	DurationSeconds float64   `json:"duration_seconds"`
+}
+
+type statusTimelineResponse struct {
+	DeviceID  string                   `json:"device_id"`
+	From      time.Time                `json:"from"`
+	To        time.Time                `json:"to"`
+	Intervals []statusIntervalResponse `json:"intervals"`
+}
+
+// timeline returns the contiguous status intervals of a device within from
+// and to, oldest first.
+func (h *StatusHandler) timeline(c echo.Context) error {
+	q := application_iot.StatusTimelineQuery{DeviceID: c.Param("id")}
+
+	var err error
+	if q.Range, err = timeRange(c); err != nil {
+		return err
+	}
+
+	timeline, err := h.timelines.Handle(c.Request().Context(), q)
+	if err != nil {
+		return queryError(c, err)
+	}
+
+	res := statusTimelineResponse{
+		DeviceID:  timeline.DeviceID,
+		From:      timeline.Range.From,
+		To:        timeline.Range.To,
+		Intervals: make([]statusIntervalResponse, len(timeline.Intervals)),
+	}
+	for i, interval := range timeline.Intervals {
+		res.Intervals[i] = statusIntervalResponse{
+			Status:          interval.Status,
+			From:            interval.From,
+			To:              interval.To,
+			DurationSeconds: interval.Duration.Seconds(),
+		}
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+type statusSummaryResponse struct {
+	DeviceID        string             `json:"device_id"`
+	ObservedSeconds float64            `json:"observed_seconds"`
+	Seconds         map[string]float64 `json:"seconds"`
+	Percent         map[string]float64 `json:"percent"`
+}
+
+type statusSummariesResponse struct {
+	From    time.Time               `json:"from"`
+	To      time.Time               `json:"to"`
+	Devices []statusSummaryResponse `json:"devices"`
+}
+
+// summary returns the time every device spent in each status within from
+// and to, in seconds and as a percentage of the period.
+func (h *StatusHandler) summary(c echo.Context) error {
+	var q application_iot.StatusSummaryQuery
+
+	var err error
+	if q.Range, err = timeRange(c); err != nil {
+		return err
+	}
+
+	summaries, err := h.summaries.Handle(c.Request().Context(), q)
+	if err != nil {
+		return queryError(c, err)
+	}
+
+	res := statusSummariesResponse{
+		From:    q.Range.From,
+		To:      q.Range.To,
+		Devices: make([]statusSummaryResponse, len(summaries)),
+	}
+	for i, s := range summaries {
+		seconds := make(map[string]float64, len(s.Durations))
+		for status, d := range s.Durations {
+			seconds[status] = d.Seconds()
+		}
+		res.Devices[i] = statusSummaryResponse{
+			DeviceID:        s.DeviceID,
+			ObservedSeconds: s.Observed.Seconds(),
+			Seconds:         seconds,
+			Percent:         s.Percent,
+		}
+	}
+	return c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,82 @@
+package presentation_api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	application_iot "iiot_system/backend/internal/application/iot"
+
+	"github.com/labstack/echo/v4"
+)
+
+type fakeStatusTimelines struct {
+	q application_iot.StatusTimelineQuery
+}
+
+func (f *fakeStatusTimelines) Handle(_ context.Context, q application_iot.StatusTimelineQuery) (application_iot.StatusTimeline, error) {
+	f.q = q
+	middle := q.Range.From.Add(90 * time.Minute)
+	return application_iot.StatusTimeline{
+		DeviceID: q.DeviceID,
+		Range:    q.Range,
+		Intervals: []application_iot.StatusInterval{
+			{Status: "running", From: q.Range.From, To: middle, Duration: 90 * time.Minute},
+			{Status: "fault", From: middle, To: q.Range.To, Duration: q.Range.To.Sub(middle)},
+		},
+	}, nil
+}
+
+type fakeStatusSummaries struct{}
+
+func (fakeStatusSummaries) Handle(context.Context, application_iot.StatusSummaryQuery) ([]application_iot.StatusSummary, error) {
+	return []application_iot.StatusSummary{{
+		DeviceID:  "press-1",
+		Observed:  2 * time.Hour,
+		Durations: map[string]time.Duration{"running": 90 * time.Minute, "idle": 30 * time.Minute},
+		Percent:   map[string]float64{"running": 75, "idle": 25},
+	}}, nil
+}
+
+func TestStatus(t *testing.T) {
+	timelines := &fakeStatusTimelines{}
+	e := echo.New()
+	NewStatusHandler(timelines, fakeStatusSummaries{}).Register(e.Group("/api/v1"))
+
+	get := func(target string, v any) int {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rec.Code
+	}
+
+	var timeline statusTimelineResponse
+	if code := get("/api/v1/devices/press-1/status-timeline?from=2026-10-01T00:00:00Z&to=2026-10-01T02:00:00Z", &timeline); code != http.StatusOK {
+		t.Fatalf("status %d", code)
+	}
+	if timelines.q.DeviceID != "press-1" || timelines.q.Range.To.Sub(timelines.q.Range.From) != 2*time.Hour {
+		t.Fatalf("queried %+v", timelines.q)
+	}
+	if len(timeline.Intervals) != 2 || timeline.Intervals[0].DurationSeconds != 5400 || timeline.Intervals[1].Status != "fault" {
+		t.Fatalf("got %+v", timeline)
+	}
+
+	var summary statusSummariesResponse
+	if code := get("/api/v1/devices/status-summary", &summary); code != http.StatusOK {
+		t.Fatalf("status %d", code)
+	}
+	if len(summary.Devices) != 1 || summary.Devices[0].Seconds["idle"] != 1800 || summary.Devices[0].Percent["running"] != 75 {
+		t.Fatalf("got %+v", summary)
+	}
+
+	if code := get("/api/v1/devices/press-1/status-timeline?from=yesterday", nil); code != http.StatusBadRequest {
+		t.Errorf("invalid from: status %d, want %d", code, http.StatusBadRequest)
+	}
+}
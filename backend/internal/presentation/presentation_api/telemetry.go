@@ -0,0 +1,185 @@
+package presentation_api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	application_iot "iiot_system/backend/internal/application/iot"
+
+	"github.com/labstack/echo/v4"
+)
+
+// TelemetryQuerier pages through the telemetry events of a device, typically
+// an application query handler.
+type TelemetryQuerier interface {
+	Handle(ctx context.Context, q application_iot.TelemetryQuery) (application_iot.TelemetryPage, error)
+}
+
+// TelemetryAggregator downsamples the telemetry of a device, typically an
+// application query handler.
+type TelemetryAggregator interface {
+	Handle(ctx context.Context, q application_iot.TelemetryAggregateQuery) (application_iot.TelemetrySeries, error)
+}
+
+// TelemetryHandler serves the telemetry time series of the devices.
+type TelemetryHandler struct {
+	events     TelemetryQuerier
+	aggregates TelemetryAggregator
+}
+
+func NewTelemetryHandler(events TelemetryQuerier, aggregates TelemetryAggregator) *TelemetryHandler {
+	return &TelemetryHandler{
+		events:     events,
+		aggregates: aggregates,
+	}
+}
+
+// Register adds the telemetry routes to g.
+func (h *TelemetryHandler) Register(g *echo.Group) {
+	g.GET("/devices/:id/telemetry", h.list)
+	g.GET("/devices/:id/telemetry/aggregate", h.aggregate)
+}
+
+type telemetryResponse struct {
+	DeviceID   string                           `json:"device_id"`
+	From       time.Time                        `json:"from"`
+	To         time.Time                        `json:"to"`
+	Fields     []application_iot.TelemetryField `json:"fields"`
+	Events     []map[string]any                 `json:"events"`
+	NextCursor string                           `json:"next_cursor,omitempty"`
+}
+
+// list returns the telemetry events of a device within from and to, oldest
+// first, holding only the requested fields. The next page is requested with
+// the returned cursor.
+func (h *TelemetryHandler) list(c echo.Context) error {
+	q := application_iot.TelemetryQuery{DeviceID: c.Param("id")}
+
+	var err error
+	if q.Range, err = timeRange(c); err != nil {
+		return err
+	}
+	if q.Limit, err = intParam(c, "limit"); err != nil {
+		return err
+	}
+	if q.After, err = decodeCursor(c); err != nil {
+		return err
+	}
+	for _, name := range listParam(c, "fields") {
+		f, err := application_iot.ParseTelemetryField(name)
+		if err != nil {
+			return queryError(c, err)
+		}
+		q.Fields = append(q.Fields, f)
+	}
+
+	page, err := h.events.Handle(c.Request().Context(), q)
+	if err != nil {
+		return queryError(c, err)
+	}
+
+	events := make([]map[string]any, len(page.Events))
+	for i, e := range page.Events {
+		event := make(map[string]any, len(page.Fields)+1)
+		event["time"] = e.Time
+		for _, f := range page.Fields {
+			event[string(f)] = f.Value(e)
+		}
+		events[i] = event
+	}
+
+	next := encodeCursor(page.Next)
+	if wantsNDJSON(c) {
+		if next != "" {
+			c.Response().Header().Set(nextCursorHeader, next)
+		}
+		return writeNDJSON(c, events)
+	}
+	return c.JSON(http.StatusOK, telemetryResponse{
+		DeviceID:   q.DeviceID,
+		From:       q.Range.From,
+		To:         q.Range.To,
+		Fields:     page.Fields,
+		Events:     events,
+		NextCursor: next,
+	})
+}
+
+type telemetrySeriesResponse struct {
+	DeviceID   string                               `json:"device_id"`
+	From       time.Time                            `json:"from"`
+	To         time.Time                            `json:"to"`
+	Bucket     string                               `json:"bucket"`
+	Fill       application_iot.GapFill              `json:"fill"`
+	Fields     []application_iot.TelemetryField     `json:"fields"`
+	Aggregates []application_iot.TelemetryAggregate `json:"aggregates"`
+	Buckets    []map[string]any                     `json:"buckets"`
+}
+
+// aggregate returns the telemetry of a device within from and to aggregated
+// over buckets of time. The bucket size is either given, such as 5m, or
+// picked to split the range into about the requested points.
+func (h *TelemetryHandler) aggregate(c echo.Context) error {
+	q := application_iot.TelemetryAggregateQuery{DeviceID: c.Param("id")}
+
+	var err error
+	if q.Range, err = timeRange(c); err != nil {
+		return err
+	}
+	if s := c.QueryParam("bucket"); s != "" && s != "auto" {
+		if q.Bucket, err = time.ParseDuration(s); err != nil || q.Bucket <= 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid bucket: %s", s))
+		}
+	}
+	if q.Points, err = intParam(c, "points"); err != nil {
+		return err
+	}
+	if q.Fill, err = application_iot.ParseGapFill(c.QueryParam("fill")); err != nil {
+		return queryError(c, err)
+	}
+	for _, name := range listParam(c, "fields") {
+		f, err := application_iot.ParseTelemetryField(name)
+		if err != nil {
+			return queryError(c, err)
+		}
+		q.Fields = append(q.Fields, f)
+	}
+	for _, name := range listParam(c, "agg") {
+		a, err := application_iot.ParseTelemetryAggregate(name)
+		if err != nil {
+			return queryError(c, err)
+		}
+		q.Aggregates = append(q.Aggregates, a)
+	}
+
+	series, err := h.aggregates.Handle(c.Request().Context(), q)
+	if err != nil {
+		return queryError(c, err)
+	}
+
+	buckets := make([]map[string]any, len(series.Buckets))
+	for i, b := range series.Buckets {
+		bucket := make(map[string]any, len(b.Values)+1)
+		bucket["time"] = b.Time
+		for f, values := range b.Values {
+			bucket[string(f)] = values
+		}
+		buckets[i] = bucket
+	}
+
+	if wantsNDJSON(c) {
+		return writeNDJSON(c, buckets)
+	}
+	return c.JSON(http.StatusOK, telemetrySeriesResponse{
+		DeviceID:   q.DeviceID,
+		From:       q.Range.From,
+		To:         q.Range.To,
+		Bucket:     series.Bucket.String(),
+		Fill:       series.Fill,
+		Fields:     series.Fields,
+		Aggregates: series.Aggregates,
+		Buckets:    buckets,
+	})
+}
@@ -0,0 +1,226 @@
+package presentation_api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"iiot_system/backend/gen/models"
+	application_iot "iiot_system/backend/internal/application/iot"
+
+	"github.com/labstack/echo/v4"
+	"github.com/shopspring/decimal"
+)
+
+// fakeTelemetry serves pages of events one second apart from start.
+type fakeTelemetry struct {
+	start  time.Time
+	events int
+	last   application_iot.TelemetryQuery
+}
+
+func (f *fakeTelemetry) Handle(_ context.Context, q application_iot.TelemetryQuery) (application_iot.TelemetryPage, error) {
+	f.last = q
+	if !q.Range.From.Before(q.Range.To) {
+		return application_iot.TelemetryPage{}, fmt.Errorf("%w: empty range", application_iot.ErrInvalidQuery)
+	}
+
+	page := application_iot.TelemetryPage{Fields: q.Fields}
+	if len(page.Fields) == 0 {
+		page.Fields = application_iot.TelemetryFields
+	}
+	for i := range f.events {
+		t := f.start.Add(time.Duration(i) * time.Second)
+		if !t.After(q.After) {
+			continue
+		}
+		if len(page.Events) == q.Limit {
+			page.Next = page.Events[len(page.Events)-1].Time
+			break
+		}
+		page.Events = append(page.Events, &models.IotTelemetryEvent{
+			Time:               t,
+			DeviceID:           q.DeviceID,
+			TemperatureCelcius: decimal.NewFromFloat(21.5),
+			MotorRPM:           int32(i),
+			MachineStatus:      "running",
+		})
+	}
+	return page, nil
+}
+
+func serve(t *testing.T, h *TelemetryHandler, target string, header http.Header) *httptest.ResponseRecorder {
+	t.Helper()
+	e := echo.New()
+	h.Register(e.Group("/api/v1"))
+
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestTelemetryPagination(t *testing.T) {
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	fake := &fakeTelemetry{start: start, events: 5}
+	h := NewTelemetryHandler(fake, nil)
+
+	var rpms []float64
+	target := "/api/v1/devices/press-1/telemetry?from=2026-10-01T00:00:00Z&to=2026-10-02T00:00:00Z&fields=motor_rpm&limit=2"
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("pagination does not end")
+		}
+		rec := serve(t, h, target, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", rec.Code, rec.Body)
+		}
+
+		var res struct {
+			DeviceID   string           `json:"device_id"`
+			Events     []map[string]any `json:"events"`
+			NextCursor string           `json:"next_cursor"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		if res.DeviceID != "press-1" || fake.last.DeviceID != "press-1" {
+			t.Fatalf("queried device %q, responded %q", fake.last.DeviceID, res.DeviceID)
+		}
+		for _, e := range res.Events {
+			if _, ok := e["temperature_celcius"]; ok {
+				t.Fatalf("event holds a field that was not requested: %v", e)
+			}
+			rpms = append(rpms, e["motor_rpm"].(float64))
+		}
+		if res.NextCursor == "" {
+			break
+		}
+		target = "/api/v1/devices/press-1/telemetry?from=2026-10-01T00:00:00Z&to=2026-10-02T00:00:00Z&fields=motor_rpm&limit=2&cursor=" + res.NextCursor
+	}
+
+	if want := []float64{0, 1, 2, 3, 4}; !slices.Equal(rpms, want) {
+		t.Fatalf("paged through %v, want %v", rpms, want)
+	}
+}
+
+func TestTelemetryNDJSON(t *testing.T) {
+	fake := &fakeTelemetry{start: time.Now().Add(-time.Hour), events: 3}
+	h := NewTelemetryHandler(fake, nil)
+
+	rec := serve(t, h, "/api/v1/devices/press-1/telemetry?limit=2", http.Header{"Accept": {mimeNDJSON}})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get(echo.HeaderContentType); ct != mimeNDJSON {
+		t.Fatalf("content type %q", ct)
+	}
+	if rec.Header().Get(nextCursorHeader) == "" {
+		t.Fatal("no cursor to the next page")
+	}
+
+	var lines int
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		var event map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("line %d: %v", lines, err)
+		}
+		if event["machine_status"] != "running" {
+			t.Fatalf("event without every field: %v", event)
+		}
+		lines++
+	}
+	if lines != 2 {
+		t.Fatalf("got %d lines, want 2", lines)
+	}
+}
+
+func TestTelemetryInvalidQueries(t *testing.T) {
+	h := NewTelemetryHandler(&fakeTelemetry{}, nil)
+
+	for _, query := range []string{
+		"from=yesterday",
+		"from=2026-10-02T00:00:00Z&to=2026-10-01T00:00:00Z",
+		"fields=pressure",
+		"limit=-1",
+		"cursor=%21",
+	} {
+		rec := serve(t, h, "/api/v1/devices/press-1/telemetry?"+query, nil)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want %d", query, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+// fakeAggregates returns a single bucket holding 1 for every aggregate.
+type fakeAggregates struct {
+	last application_iot.TelemetryAggregateQuery
+}
+
+func (f *fakeAggregates) Handle(_ context.Context, q application_iot.TelemetryAggregateQuery) (application_iot.TelemetrySeries, error) {
+	f.last = q
+	one := 1.0
+	values := make(map[application_iot.TelemetryAggregate]*float64)
+	for _, a := range q.Aggregates {
+		values[a] = &one
+	}
+	return application_iot.TelemetrySeries{
+		Bucket:     q.Bucket,
+		Fields:     q.Fields,
+		Aggregates: q.Aggregates,
+		Fill:       q.Fill,
+		Buckets: []application_iot.TelemetryBucket{{
+			Time:   q.Range.From,
+			Values: map[application_iot.TelemetryField]map[application_iot.TelemetryAggregate]*float64{q.Fields[0]: values},
+		}},
+	}, nil
+}
+
+func TestTelemetryAggregate(t *testing.T) {
+	fake := &fakeAggregates{}
+	h := NewTelemetryHandler(nil, fake)
+
+	rec := serve(t, h, "/api/v1/devices/press-1/telemetry/aggregate?bucket=5m&agg=avg,max&agg=p95&fields=vibration_hz&fill=interpolate", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	q := fake.last
+	if q.Bucket != 5*time.Minute || q.Fill != application_iot.GapFillInterpolate || len(q.Aggregates) != 3 {
+		t.Fatalf("queried %+v", q)
+	}
+
+	var res struct {
+		Bucket  string `json:"bucket"`
+		Buckets []struct {
+			Vibration map[string]float64 `json:"vibration_hz"`
+		} `json:"buckets"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Bucket != "5m0s" || len(res.Buckets) != 1 || res.Buckets[0].Vibration["p95"] != 1 {
+		t.Fatalf("got %s", rec.Body)
+	}
+
+	rec = serve(t, h, "/api/v1/devices/press-1/telemetry/aggregate?points=200&fields=vibration_hz", nil)
+	if rec.Code != http.StatusOK || fake.last.Bucket != 0 || fake.last.Points != 200 {
+		t.Fatalf("status %d, queried %+v", rec.Code, fake.last)
+	}
+
+	for _, query := range []string{"bucket=5", "bucket=-5m", "agg=median", "fill=zero"} {
+		rec := serve(t, h, "/api/v1/devices/press-1/telemetry/aggregate?fields=vibration_hz&"+query, nil)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want %d", query, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package presentation_health
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"iiot_system/backend/internal/presentation/presentation_iot"
+
+	"github.com/labstack/echo/v4"
+)
+
+// checkTimeout bounds how long the readiness checks may take altogether.
+const checkTimeout = 2 * time.Second
+
+// Check returns an error when a dependency cannot be used.
+type Check func(ctx context.Context) error
+
+// Consumer is a pipeline consumer whose readiness and progress are reported.
+type Consumer interface {
+	Name() string
+	Ready(ctx context.Context) error
+	Status() presentation_iot.ConsumerStatus
+}
+
+// Handler serves the liveness, readiness and status endpoints used by the
+// orchestrator and operators.
+type Handler struct {
+	checks    map[string]Check
+	consumers []Consumer
+	started   time.Time
+}
+
+// NewHandler returns a Handler reporting on consumers and the dependencies
+// checked by checks, by name.
+func NewHandler(checks map[string]Check, consumers ...Consumer) *Handler {
+	return &Handler{
+		checks:    checks,
+		consumers: consumers,
+		started:   time.Now(),
+	}
+}
+
+// Register adds the /healthz, /readyz and /status routes to e.
+func (h *Handler) Register(e *echo.Echo) {
+	e.GET("/healthz", h.healthz)
+	e.GET("/readyz", h.readyz)
+	e.GET("/status", h.status)
+}
+
+type readiness struct {
+	Ready bool `json:"ready"`
+	// Checks holds the error of each failed check, or "ok".
+	Checks map[string]string `json:"checks"`
+}
+
+type status struct {
+	readiness
+	Uptime    string                            `json:"uptime"`
+	Consumers []presentation_iot.ConsumerStatus `json:"consumers"`
+}
+
+// healthz reports the process is alive, whatever the state of its
+// dependencies, so it is only restarted when it stops serving.
+func (h *Handler) healthz(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// readyz reports whether the database and Kafka are reachable and every
+// consumer has partitions assigned, so traffic is only routed once ingesting.
+func (h *Handler) readyz(c echo.Context) error {
+	r := h.readiness(c.Request().Context())
+	code := http.StatusOK
+	if !r.Ready {
+		code = http.StatusServiceUnavailable
+	}
+	return c.JSON(code, r)
+}
+
+// status reports the readiness along with the progress of every consumer.
+func (h *Handler) status(c echo.Context) error {
+	s := status{
+		readiness: h.readiness(c.Request().Context()),
+		Uptime:    time.Since(h.started).Round(time.Second).String(),
+		Consumers: make([]presentation_iot.ConsumerStatus, len(h.consumers)),
+	}
+	for i, consumer := range h.consumers {
+		s.Consumers[i] = consumer.Status()
+	}
+	return c.JSON(http.StatusOK, s)
+}
+
+func (h *Handler) readiness(ctx context.Context) readiness {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	r := readiness{
+		Ready:  true,
+		Checks: make(map[string]string, len(h.checks)+len(h.consumers)),
+	}
+	record := func(name string, err error) {
+		if err != nil {
+			r.Ready = false
+			r.Checks[name] = err.Error()
+			return
+		}
+		r.Checks[name] = "ok"
+	}
+
+	for name, check := range h.checks {
+		record(name, check(ctx))
+	}
+	for _, consumer := range h.consumers {
+		record(consumer.Name(), consumer.Ready(ctx))
+	}
+	return r
+}
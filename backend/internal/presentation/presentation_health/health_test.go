@@ -0,0 +1,150 @@
+package presentation_health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"iiot_system/backend/internal/presentation/presentation_iot"
+
+	"github.com/labstack/echo/v4"
+)
+
+type fakePinger struct {
+	err      error
+	deadline bool
+}
+
+func (p *fakePinger) Ping(ctx context.Context) error {
+	_, p.deadline = ctx.Deadline()
+	return p.err
+}
+
+type fakeConsumer struct {
+	name   string
+	ready  error
+	status presentation_iot.ConsumerStatus
+}
+
+func (c fakeConsumer) Name() string                            { return c.name }
+func (c fakeConsumer) Ready(context.Context) error             { return c.ready }
+func (c fakeConsumer) Status() presentation_iot.ConsumerStatus { return c.status }
+
+func TestHealth(t *testing.T) {
+	telemetry := fakeConsumer{
+		name:   "telemetry",
+		status: presentation_iot.ConsumerStatus{Name: "telemetry", Lag: 12},
+	}
+	stalled := fakeConsumer{
+		name:  "alerts",
+		ready: errors.New("no partitions assigned"),
+	}
+	failed := fakeConsumer{
+		name:   "production",
+		ready:  errors.New("fetch failed: broker unreachable"),
+		status: presentation_iot.ConsumerStatus{Name: "production", LastError: "broker unreachable"},
+	}
+
+	for _, tt := range []struct {
+		name      string
+		database  error
+		consumers []Consumer
+		ready     bool
+		failed    map[string]string
+	}{
+		{
+			name:      "ready",
+			consumers: []Consumer{telemetry},
+			ready:     true,
+		},
+		{
+			name:      "database down",
+			database:  errors.New("connection refused"),
+			consumers: []Consumer{telemetry},
+			failed:    map[string]string{"database": "connection refused"},
+		},
+		{
+			name:      "stalled consumer",
+			consumers: []Consumer{telemetry, stalled},
+			failed:    map[string]string{"alerts": "no partitions assigned"},
+		},
+		{
+			name:      "failed consumer",
+			consumers: []Consumer{failed, telemetry},
+			failed:    map[string]string{"production": "fetch failed: broker unreachable"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			pinger := &fakePinger{err: tt.database}
+			e := echo.New()
+			NewHandler(map[string]Check{"database": pinger.Ping}, tt.consumers...).Register(e)
+
+			get := func(target string, v any) int {
+				rec := httptest.NewRecorder()
+				e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+				if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+					t.Fatal(err)
+				}
+				return rec.Code
+			}
+
+			// Liveness does not depend on the dependencies.
+			var live map[string]string
+			if code := get("/healthz", &live); code != http.StatusOK || live["status"] != "ok" {
+				t.Errorf("healthz: status %d, %v", code, live)
+			}
+
+			wantCode := http.StatusOK
+			if !tt.ready {
+				wantCode = http.StatusServiceUnavailable
+			}
+			var r readiness
+			if code := get("/readyz", &r); code != wantCode {
+				t.Errorf("readyz: status %d, want %d", code, wantCode)
+			}
+			if r.Ready != tt.ready {
+				t.Errorf("ready %t, want %t", r.Ready, tt.ready)
+			}
+			if len(r.Checks) != 1+len(tt.consumers) {
+				t.Errorf("checks %v", r.Checks)
+			}
+			for name, result := range r.Checks {
+				want, failed := tt.failed[name]
+				if !failed {
+					want = "ok"
+				}
+				if result != want {
+					t.Errorf("check %s: got %q, want %q", name, result, want)
+				}
+			}
+			if !pinger.deadline {
+				t.Error("the database was pinged without a deadline")
+			}
+
+			// The status is served whatever the readiness.
+			var s struct {
+				Ready     bool                              `json:"ready"`
+				Checks    map[string]string                 `json:"checks"`
+				Uptime    string                            `json:"uptime"`
+				Consumers []presentation_iot.ConsumerStatus `json:"consumers"`
+			}
+			if code := get("/status", &s); code != http.StatusOK {
+				t.Errorf("status: status %d", code)
+			}
+			if s.Ready != tt.ready || len(s.Checks) != len(r.Checks) || s.Uptime == "" {
+				t.Errorf("status %+v", s)
+			}
+			if len(s.Consumers) != len(tt.consumers) {
+				t.Fatalf("consumers %+v", s.Consumers)
+			}
+			for i, c := range tt.consumers {
+				if want := c.Status(); s.Consumers[i].Name != want.Name || s.Consumers[i].Lag != want.Lag || s.Consumers[i].LastError != want.LastError {
+					t.Errorf("consumer %d: got %+v, want %+v", i, s.Consumers[i], want)
+				}
+			}
+		})
+	}
+}
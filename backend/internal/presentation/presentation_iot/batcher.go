@@ -0,0 +1,124 @@
+package presentation_iot
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Defaults of BatchOptions.
+const (
+	defaultBatchMaxRows     = 1000
+	defaultBatchMaxWait     = 500 * time.Millisecond
+	defaultBatchMaxBuffered = 10000
+)
+
+// BatchOptions controls how a consumer accumulates commands before handing
+// them to its sink.
+type BatchOptions struct {
+	// MaxRows flushes a batch once it holds that many records.
+	MaxRows int
+	// MaxWait flushes a batch once its oldest record waited that long.
+	MaxWait time.Duration
+	// MaxBuffered pauses fetching while that many records wait to be
+	// flushed, so a database falling behind does not grow memory unboundedly.
+	MaxBuffered int
+}
+
+func (o BatchOptions) withDefaults() BatchOptions {
+	if o.MaxRows <= 0 {
+		o.MaxRows = defaultBatchMaxRows
+	}
+	if o.MaxWait <= 0 {
+		o.MaxWait = defaultBatchMaxWait
+	}
+	if o.MaxBuffered <= 0 {
+		o.MaxBuffered = defaultBatchMaxBuffered
+	}
+	o.MaxBuffered = max(o.MaxBuffered, o.MaxRows)
+	return o
+}
+
+// FlushLimiter bounds the number of batches written concurrently by the
+// consumers sharing it. A nil limiter does not bound them.
+type FlushLimiter struct {
+	slots chan struct{}
+}
+
+func NewFlushLimiter(maxInFlight int) *FlushLimiter {
+	return &FlushLimiter{
+		slots: make(chan struct{}, max(maxInFlight, 1)),
+	}
+}
+
+// acquire waits for a free slot. It reports false if ctx is done first.
+func (l *FlushLimiter) acquire(ctx context.Context) bool {
+	if l == nil {
+		return true
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (l *FlushLimiter) release() {
+	if l == nil {
+		return
+	}
+	<-l.slots
+}
+
+// batch holds the commands accumulated from the records of one topic.
+type batch[C any] struct {
+	// id identifies the batch in the logs of its consumer.
+	id       uint64
+	topic    string
+	started  time.Time
+	commands []C
+	// decoded are the records of commands, by index.
+	decoded []*kgo.Record
+	// records are every record of the batch, including the dead-lettered
+	// ones, marked once the batch is flushed.
+	records []*kgo.Record
+	// links are the trace contexts propagated by the decoded records.
+	links []trace.Link
+}
+
+// ready reports whether b must be flushed.
+func (b *batch[C]) ready(opts BatchOptions, now time.Time) bool {
+	return len(b.records) >= opts.MaxRows || now.Sub(b.started) >= opts.MaxWait
+}
+
+// flush is a batch being handed to the sink.
+type flush[C any] struct {
+	batch *batch[C]
+	// done is closed once the batch is handled, ok reporting whether its
+	// records were marked.
+	done chan struct{}
+	ok   bool
+	// stalled is set while the sink fails to store the batch.
+	stalled atomic.Bool
+}
+
+// partitions returns the partitions of records.
+func partitions(records []*kgo.Record) map[string][]int32 {
+	seen := make(map[string]map[int32]bool)
+	byTopic := make(map[string][]int32)
+	for _, rec := range records {
+		if seen[rec.Topic] == nil {
+			seen[rec.Topic] = make(map[int32]bool)
+		}
+		if !seen[rec.Topic][rec.Partition] {
+			seen[rec.Topic][rec.Partition] = true
+			byTopic[rec.Topic] = append(byTopic[rec.Topic], rec.Partition)
+		}
+	}
+	return byTopic
+}
@@ -0,0 +1,73 @@
+package presentation_iot
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	application_iot "iiot_system/backend/internal/application/iot"
+
+	"github.com/twmb/franz-go/pkg/kfake"
+)
+
+// overlapSink is a testSink that counts the batches handled concurrently by
+// every overlapSink sharing active.
+type overlapSink struct {
+	*testSink
+	active  *atomic.Int32
+	maxSeen *atomic.Int32
+}
+
+func (s overlapSink) Handle(ctx context.Context, commands ...string) (application_iot.InsertResult, error) {
+	n := s.active.Add(1)
+	defer s.active.Add(-1)
+	for seen := s.maxSeen.Load(); n > seen && !s.maxSeen.CompareAndSwap(seen, n); seen = s.maxSeen.Load() {
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	return s.testSink.Handle(ctx, commands...)
+}
+
+func TestFlushLimiterBoundsConsumers(t *testing.T) {
+	const n = 50
+	limiter := NewFlushLimiter(1)
+	var active, maxSeen atomic.Int32
+
+	var sinks []*testSink
+	var stops []func() error
+	for range 2 {
+		cluster, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.SeedTopics(1, testTopic))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(cluster.Close)
+		produce(t, cluster, testRecords(n))
+
+		sink := newTestSink()
+		consumer, _, _ := newTestConsumerOn(t, cluster, overlapSink{sink, &active, &maxSeen}, nil,
+			WithBatching(BatchOptions{MaxRows: 5, MaxWait: 10 * time.Millisecond}),
+			WithFlushLimiter(limiter),
+		)
+		cancel, errs := startConsumer(consumer)
+		defer cancel()
+
+		sinks = append(sinks, sink)
+		stops = append(stops, func() error {
+			cancel()
+			return waitStopped(t, errs)
+		})
+	}
+
+	waitFor(t, "records to be stored", func() bool {
+		return sinks[0].stored() == n && sinks[1].stored() == n
+	})
+	for _, stop := range stops {
+		if err := stop(); err != nil {
+			t.Errorf("Start() = %v, want nil", err)
+		}
+	}
+	if got := maxSeen.Load(); got != 1 {
+		t.Errorf("%d batches handled at once, want 1", got)
+	}
+}
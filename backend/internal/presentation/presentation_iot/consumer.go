@@ -0,0 +1,751 @@
+package presentation_iot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"slices"
+	"time"
+
+	application_iot "iiot_system/backend/internal/application/iot"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.opentelemetry.io/otel/codes"
+)
+
+const (
+	// handleRetryDelay is how long a consumer waits before retrying a batch the
+	// sink failed to store, doubling with every attempt up to
+	// maxHandleRetryDelay.
+	handleRetryDelay    = time.Second
+	maxHandleRetryDelay = 30 * time.Second
+	// defaultDrainTimeout is how long a stopping consumer waits for its
+	// buffered batches to be stored.
+	defaultDrainTimeout = 20 * time.Second
+)
+
+// Decoder turns a raw Kafka record into a typed payload.
+type Decoder[P any] func(rec *kgo.Record) (P, error)
+
+// Mapper builds the command to store from a decoded payload.
+type Mapper[P, C any] func(payload P) C
+
+// Sink stores a batch of commands, typically an application command handler.
+// Commands it rejects are reported by their index in the batch.
+type Sink[C any] interface {
+	Handle(ctx context.Context, command ...C) (application_iot.InsertResult, error)
+}
+
+// DeadLetterSink receives the records a consumer gives up on.
+type DeadLetterSink interface {
+	Send(ctx context.Context, rec *kgo.Record, cause error, attempts int) error
+}
+
+// ConsumerMetrics observes the records of a consumer. Rejected records are
+// reported by the stage refusing them: decode or validate. Spoofed records
+// carry a device id not matching the MQTT client id they were published with,
+// and the broker latency of a record is the time between its device timestamp
+// and its receipt by the MQTT broker.
+type ConsumerMetrics interface {
+	RecordsConsumed(topic string, n int)
+	RecordRejected(topic string, stage string)
+	RecordSpoofed(topic string)
+	BrokerLatency(topic string, latency time.Duration)
+	BatchFlushed(topic string, size int)
+}
+
+type consumerOptions struct {
+	deadLetter   DeadLetterSink
+	offsetGroup  string
+	strict       bool
+	timestamps   TimestampPolicy
+	spoofing     bool
+	maxPoll      int
+	batch        BatchOptions
+	limiter      *FlushLimiter
+	drainTimeout time.Duration
+	assignment   PartitionAssignment
+	metrics      ConsumerMetrics
+	logger       *slog.Logger
+}
+
+type ConsumerOption func(*consumerOptions)
+
+// WithDeadLetter routes undecodable and invalid records, and records the
+// database refuses permanently, to dlq instead of retrying them forever.
+// Batches failing for any other reason, such as a database outage, are
+// retried until they are stored.
+func WithDeadLetter(dlq DeadLetterSink) ConsumerOption {
+	return func(o *consumerOptions) {
+		o.deadLetter = dlq
+	}
+}
+
+// WithStoredOffsets stores the consumed offsets of the consumer group in the
+// same database transaction as the events, for exactly-once ingestion. The
+// client must resume from those offsets, see kafka.ResumeFromStoredOffsets.
+func WithStoredOffsets(group string) ConsumerOption {
+	return func(o *consumerOptions) {
+		o.offsetGroup = group
+	}
+}
+
+// WithStrictPayloads rejects payloads holding fields their type does not
+// declare.
+func WithStrictPayloads(strict bool) ConsumerOption {
+	return func(o *consumerOptions) {
+		o.strict = strict
+	}
+}
+
+// WithTimestampPolicy sets how event times are resolved from payloads.
+func WithTimestampPolicy(policy TimestampPolicy) ConsumerOption {
+	return func(o *consumerOptions) {
+		o.timestamps = policy
+	}
+}
+
+// WithRejectSpoofedDevices rejects payloads whose device id does not match the
+// MQTT client id they were published with. They are only logged otherwise.
+func WithRejectSpoofedDevices(reject bool) ConsumerOption {
+	return func(o *consumerOptions) {
+		o.spoofing = reject
+	}
+}
+
+// WithMaxPollRecords caps the records handled per poll. Zero handles whatever
+// has been fetched.
+func WithMaxPollRecords(n int) ConsumerOption {
+	return func(o *consumerOptions) {
+		o.maxPoll = n
+	}
+}
+
+// WithBatching sets how commands are accumulated before being handed to the
+// sink. Zero options keep their defaults.
+func WithBatching(batch BatchOptions) ConsumerOption {
+	return func(o *consumerOptions) {
+		o.batch = batch
+	}
+}
+
+// WithFlushLimiter bounds the batches written concurrently by the consumers
+// sharing limiter.
+func WithFlushLimiter(limiter *FlushLimiter) ConsumerOption {
+	return func(o *consumerOptions) {
+		o.limiter = limiter
+	}
+}
+
+// WithDrainTimeout sets how long a stopping consumer waits for its buffered
+// batches to be stored and their offsets committed.
+func WithDrainTimeout(timeout time.Duration) ConsumerOption {
+	return func(o *consumerOptions) {
+		o.drainTimeout = timeout
+	}
+}
+
+// WithMetrics makes the consumer report the records it consumes to metrics.
+func WithMetrics(metrics ConsumerMetrics) ConsumerOption {
+	return func(o *consumerOptions) {
+		o.metrics = metrics
+	}
+}
+
+// WithLogger logs the events of the consumer to logger instead of the default
+// logger.
+func WithLogger(logger *slog.Logger) ConsumerOption {
+	return func(o *consumerOptions) {
+		o.logger = logger
+	}
+}
+
+// Consumer is a typed pipeline that polls records from its own Kafka client,
+// decodes them into P, validates them, maps them to commands C and hands them
+// to a sink in batches bounded by size and age. While the sink falls behind,
+// fetching is paused rather than buffering records without bound.
+//
+// Adding a new event stream only requires a payload type, a mapping function
+// and a sink:
+//
+//	energyPayloads := NewPayloadRegistry[EnergyPayload]("energy_meter").
+//		Register(1, DecodeEnvelopeJSON[EnergyPayload])
+//	NewConsumer("energy meter", client, energyPayloads.Decode, validateEnergy, mapEnergy, handler)
+type Consumer[P, C any] struct {
+	name     string
+	client   *kgo.Client
+	decode   Decoder[P]
+	validate Validator[P]
+	mapTo    Mapper[P, C]
+	sink     Sink[C]
+	opts     consumerOptions
+	log      *slog.Logger
+
+	// The batching state is only used by the goroutine running Start.
+	pending  []*batch[C]
+	buffered int
+	inFlight *flush[C]
+	paused   map[string][]int32
+	batches  uint64
+
+	fetchErrors fetchErrors
+	progress    progress
+}
+
+func NewConsumer[P, C any](name string, client *kgo.Client, decode Decoder[P], validate Validator[P], mapTo Mapper[P, C], sink Sink[C], opts ...ConsumerOption) *Consumer[P, C] {
+	o := consumerOptions{
+		drainTimeout: defaultDrainTimeout,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	o.batch = o.batch.withDefaults()
+	if o.logger == nil {
+		o.logger = slog.Default()
+	}
+
+	return &Consumer[P, C]{
+		name:     name,
+		client:   client,
+		decode:   decode,
+		validate: validate,
+		mapTo:    mapTo,
+		sink:     sink,
+		opts:     o,
+		log:      o.logger.With("consumer", name),
+	}
+}
+
+// Start consumes records until ctx is done, then stops polling and drains:
+// the buffered batches are stored and their offsets committed within the
+// drain timeout. It returns nil once drained, records left unstored at the
+// deadline are consumed again after a restart.
+func (c *Consumer[P, C]) Start(ctx context.Context) error {
+	if err := c.client.Ping(ctx); err != nil {
+		return fmt.Errorf("unable to ping kafka: %v", err)
+	}
+
+	// Batches are flushed with a context that outlives ctx, so those in
+	// flight at shutdown are stored rather than abandoned.
+	flushCtx, cancelFlush := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelFlush()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return c.drain(flushCtx, cancelFlush)
+		default:
+			c.step(ctx, flushCtx)
+		}
+	}
+}
+
+// step polls records, unless fetching is paused until the in-flight batch is
+// stored, then starts flushing the next batch that is due.
+func (c *Consumer[P, C]) step(ctx, flushCtx context.Context) {
+	c.collect()
+	if c.paused != nil {
+		c.waitFlush(ctx)
+	} else {
+		c.poll(ctx)
+	}
+	c.collect()
+	c.dispatch(flushCtx, false)
+	c.applyBackpressure()
+}
+
+// drain flushes every buffered batch and commits the marked offsets, giving
+// up once the drain timeout cancels flushCtx.
+func (c *Consumer[P, C]) drain(flushCtx context.Context, cancelFlush context.CancelFunc) error {
+	c.log.Info("draining consumer", "buffered", c.buffered)
+	deadline := time.AfterFunc(c.opts.drainTimeout, cancelFlush)
+	defer deadline.Stop()
+
+	for c.inFlight != nil || len(c.pending) > 0 {
+		f := c.inFlight
+		c.waitFlush(flushCtx)
+		c.collect()
+		if flushCtx.Err() != nil {
+			return fmt.Errorf("%s consumer drain timed out with %d records not stored", c.name, c.buffered)
+		}
+		// Later batches must not be marked past a batch that was not stored.
+		if f != nil && c.inFlight == nil && !f.ok {
+			return fmt.Errorf("unable to store %s batch while draining", c.name)
+		}
+		c.dispatch(flushCtx, true)
+	}
+
+	if err := c.client.CommitMarkedOffsets(flushCtx); err != nil {
+		return fmt.Errorf("unable to commit %s offsets: %w", c.name, err)
+	}
+	return nil
+}
+
+func (c *Consumer[P, C]) poll(ctx context.Context) {
+	pollCtx, cancel := c.pollContext(ctx)
+	defer cancel()
+
+	var fetches kgo.Fetches
+	if c.opts.maxPoll > 0 {
+		fetches = c.client.PollRecords(pollCtx, c.opts.maxPoll)
+	} else {
+		fetches = c.client.PollFetches(pollCtx)
+	}
+	// Records are only marked once their batch is stored, so a rebalance
+	// never commits the offsets of buffered records. A batch stored after its
+	// partition moved to another member is inserted again idempotently.
+	defer c.client.AllowRebalance()
+
+	if fetches.IsClientClosed() {
+		return
+	}
+
+	// An error is tied to its partition, the records of the others are
+	// still consumed. The client keeps retrying the failed fetches.
+	var failed bool
+	now := time.Now()
+	fetches.EachError(func(topic string, partition int32, err error) {
+		// The poll returns early when a batch is due or has been flushed.
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return
+		}
+		failed = true
+		c.progress.failed(err, now)
+		e := c.fetchErrors.observe(topic, partition, err, now)
+		if e.Count == 1 || e.Count%fetchErrorLogEvery == 0 {
+			level := slog.LevelError
+			if e.Class == FetchErrorRetriable {
+				level = slog.LevelWarn
+			}
+			c.log.Log(ctx, level, "fetch error",
+				"topic", topic, "partition", partition, "class", e.Class, "count", e.Count, "error", err)
+		}
+	})
+	fetches.EachPartition(func(p kgo.FetchTopicPartition) {
+		c.progress.fetched(p)
+		if c.opts.metrics != nil && len(p.Records) > 0 {
+			c.opts.metrics.RecordsConsumed(p.Topic, len(p.Records))
+		}
+		if len(p.Records) > 0 {
+			c.fetchErrors.recovered(p.Topic, p.Partition)
+		}
+	})
+
+	records := fetches.Records()
+	if failed && len(records) == 0 {
+		select {
+		case <-pollCtx.Done():
+		case <-time.After(fetchErrorBackoff):
+		}
+		return
+	}
+
+	for _, rec := range records {
+		if !c.add(ctx, rec) {
+			return
+		}
+	}
+}
+
+// pollContext bounds a poll to the moment the oldest pending batch is due or
+// the in-flight batch has been flushed, so batches are flushed on time even
+// when no record arrives.
+func (c *Consumer[P, C]) pollContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if f := c.inFlight; f != nil {
+		pollCtx, cancel := context.WithCancel(ctx)
+		go func() {
+			select {
+			case <-f.done:
+				cancel()
+			case <-pollCtx.Done():
+			}
+		}()
+		return pollCtx, cancel
+	}
+
+	var deadline time.Time
+	for _, b := range c.pending {
+		if due := b.started.Add(c.opts.batch.MaxWait); deadline.IsZero() || due.Before(deadline) {
+			deadline = due
+		}
+	}
+	if deadline.IsZero() {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, deadline)
+}
+
+// add decodes, validates and maps rec into the pending batch of its topic.
+// Records that cannot be decoded are dead-lettered right away but still
+// marked with their batch, so offsets are committed in order. It reports
+// false if rec could not be dead-lettered, and the rest of the poll must be
+// dropped.
+func (c *Consumer[P, C]) add(ctx context.Context, rec *kgo.Record) bool {
+	ctx = recordContext(ctx, rec)
+
+	_, span := startRecordSpan(ctx, c.name+" decode", rec)
+	payload, err := c.decode(rec)
+	endSpan(span, err)
+	if err != nil {
+		c.log.Warn("unable to decode record", recordAttrs(rec, "error", err)...)
+		if c.opts.metrics != nil {
+			c.opts.metrics.RecordRejected(rec.Topic, "decode")
+		}
+		if !c.deadLetter(ctx, err, 1, rec) {
+			return false
+		}
+		c.buffer(rec)
+		return true
+	}
+
+	_, span = startRecordSpan(ctx, c.name+" validate", rec)
+	err = c.validatePayload(rec, &payload)
+	endSpan(span, err)
+	if err != nil {
+		c.log.Warn("invalid record", recordAttrs(rec, "device_id", deviceID(&payload), "error", err)...)
+		if c.opts.metrics != nil {
+			c.opts.metrics.RecordRejected(rec.Topic, "validate")
+		}
+		if !c.deadLetter(ctx, err, 1, rec) {
+			return false
+		}
+		c.buffer(rec)
+		return true
+	}
+
+	b := c.buffer(rec)
+	b.commands = append(b.commands, c.mapTo(payload))
+	b.decoded = append(b.decoded, rec)
+	b.links = link(b.links, ctx)
+	return true
+}
+
+// buffer adds rec to the last pending batch of its topic, or to a new batch
+// once that one is full, and returns the batch.
+func (c *Consumer[P, C]) buffer(rec *kgo.Record) *batch[C] {
+	var b *batch[C]
+	for i := len(c.pending) - 1; i >= 0; i-- {
+		if c.pending[i].topic == rec.Topic {
+			b = c.pending[i]
+			break
+		}
+	}
+	if b == nil || len(b.records) >= c.opts.batch.MaxRows {
+		c.batches++
+		b = &batch[C]{id: c.batches, topic: rec.Topic, started: time.Now()}
+		c.pending = append(c.pending, b)
+	}
+
+	b.records = append(b.records, rec)
+	c.buffered++
+	return b
+}
+
+// dispatch starts flushing the oldest due batch, unless a batch is already in
+// flight: flushing one batch at a time keeps the offsets of each partition
+// marked and stored in order. A batch is due once full or old enough, when
+// too many records are buffered, or when forced.
+func (c *Consumer[P, C]) dispatch(ctx context.Context, force bool) {
+	if c.inFlight != nil || len(c.pending) == 0 {
+		return
+	}
+
+	// Pending batches are ordered by age, and a batch is only followed by
+	// another of its topic once full.
+	now := time.Now()
+	next := -1
+	for i, b := range c.pending {
+		if b.ready(c.opts.batch, now) {
+			next = i
+			break
+		}
+	}
+	if next < 0 {
+		if !force && c.buffered < c.opts.batch.MaxBuffered {
+			return
+		}
+		next = 0
+	}
+
+	b := c.pending[next]
+	c.pending = slices.Delete(c.pending, next, next+1)
+	f := &flush[C]{batch: b, done: make(chan struct{})}
+	c.inFlight = f
+	go c.flush(ctx, f)
+}
+
+// flush hands a batch to the sink once the flush limiter allows it, then
+// marks its records.
+func (c *Consumer[P, C]) flush(ctx context.Context, f *flush[C]) {
+	defer close(f.done)
+
+	if !c.opts.limiter.acquire(ctx) {
+		return
+	}
+	defer c.opts.limiter.release()
+
+	b := f.batch
+	ctx, span := startBatchSpan(ctx, c.name+" process", b.topic, len(b.records), b.links)
+	defer span.End()
+	if c.opts.metrics != nil {
+		c.opts.metrics.BatchFlushed(b.topic, len(b.records))
+	}
+	if c.opts.offsetGroup != "" {
+		ctx = application_iot.ContextWithSourceOffsets(ctx, c.opts.offsetGroup, nextOffsets(b.records))
+	}
+
+	if !c.handle(ctx, f) {
+		span.SetStatus(codes.Error, "batch not stored")
+		return
+	}
+
+	c.client.MarkCommitRecords(b.records...)
+	c.progress.flushed(b.records, time.Now())
+	f.ok = true
+}
+
+// collect releases the in-flight batch once it has been flushed.
+func (c *Consumer[P, C]) collect() {
+	f := c.inFlight
+	if f == nil {
+		return
+	}
+
+	select {
+	case <-f.done:
+		c.buffered -= len(f.batch.records)
+		c.inFlight = nil
+	default:
+	}
+}
+
+// waitFlush waits for the in-flight batch to be flushed.
+func (c *Consumer[P, C]) waitFlush(ctx context.Context) {
+	if c.inFlight == nil {
+		return
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-c.inFlight.done:
+	}
+}
+
+// applyBackpressure pauses fetching the partitions of the buffered records
+// while too many of them wait to be stored or the sink fails to store the
+// in-flight batch, and resumes it once the database caught up.
+func (c *Consumer[P, C]) applyBackpressure() {
+	behind := c.buffered >= c.opts.batch.MaxBuffered || (c.inFlight != nil && c.inFlight.stalled.Load())
+	switch {
+	case c.paused == nil && behind:
+		var records []*kgo.Record
+		for _, b := range c.pending {
+			records = append(records, b.records...)
+		}
+		if c.inFlight != nil {
+			records = append(records, c.inFlight.batch.records...)
+		}
+
+		c.paused = partitions(records)
+		c.client.PauseFetchPartitions(c.paused)
+		c.log.Info("pausing consumer", "buffered", c.buffered)
+	case c.paused != nil && !behind:
+		c.client.ResumeFetchPartitions(c.paused)
+		c.paused = nil
+		c.log.Info("resuming consumer")
+	}
+}
+
+// validatePayload resolves the event time of payload, checks its device
+// identity and validates it.
+func (c *Consumer[P, C]) validatePayload(rec *kgo.Record, payload *P) error {
+	var errs ValidationErrors
+	if e, ok := any(payload).(envelopeCarrier); ok {
+		env := e.envelope()
+		if err := c.opts.timestamps.resolve(env, rec.Timestamp, time.Now()); err != nil {
+			errs = append(errs, *err)
+		}
+		if err := c.checkIdentity(rec, env); err != nil {
+			errs = append(errs, *err)
+		}
+		if c.opts.strict {
+			errs = append(errs, unknownFields(env.raw, reflect.TypeFor[P](), "")...)
+		}
+	}
+	if c.validate != nil {
+		errs = append(errs, c.validate(*payload)...)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// checkIdentity reports spoofed records and the broker latency of env to the
+// metrics, and a device id that does not match the MQTT client id as an error
+// if spoofed devices are rejected.
+func (c *Consumer[P, C]) checkIdentity(rec *kgo.Record, env *Envelope) *FieldError {
+	clientID := env.ingestion.MQTTClientID
+	spoofed := clientID != "" && clientID != env.DeviceID
+
+	if m := c.opts.metrics; m != nil {
+		if spoofed {
+			m.RecordSpoofed(rec.Topic)
+		}
+		if received := env.ingestion.BrokerReceivedAt; !received.IsZero() && !env.time.IsZero() {
+			m.BrokerLatency(rec.Topic, received.Sub(env.time))
+		}
+	}
+
+	if !spoofed {
+		return nil
+	}
+	if c.opts.spoofing {
+		return &FieldError{Field: "device_id", Message: fmt.Sprintf("does not match MQTT client id %q", clientID)}
+	}
+	c.log.Warn("device id does not match MQTT client id",
+		recordAttrs(rec, "device_id", env.DeviceID, "mqtt_client_id", clientID)...)
+	return nil
+}
+
+// handle hands the commands of the batch of f to the sink, retrying failed
+// batches with backoff until they are stored, since marking later records
+// would commit past them and lose the events. Only a batch the database
+// refuses permanently is dead-lettered, when there is a dead-letter sink.
+// Records of the commands rejected by the sink are quarantined in the
+// dead-letter topic. It reports whether the records can be marked.
+func (c *Consumer[P, C]) handle(ctx context.Context, f *flush[C]) bool {
+	b := f.batch
+	defer f.stalled.Store(false)
+
+	for attempt := 1; ; attempt++ {
+		result, err := c.sink.Handle(ctx, b.commands...)
+		if err == nil {
+			if result.Duplicates > 0 {
+				c.log.Info("dropped duplicate records",
+					"topic", b.topic, "batch_id", b.id, "duplicates", result.Duplicates)
+			}
+			return c.quarantine(ctx, b, result.Rejected, attempt)
+		}
+		c.log.Error("unable to store batch",
+			"topic", b.topic, "batch_id", b.id, "records", len(b.commands), "attempt", attempt, "error", err)
+		c.progress.failed(err, time.Now())
+
+		if c.opts.deadLetter != nil && application_iot.IsPermanent(err) {
+			return c.deadLetter(ctx, err, attempt, b.decoded...) && c.storeOffsets(ctx)
+		}
+
+		// Fetching is paused until the batch is stored.
+		f.stalled.Store(true)
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(retryDelay(attempt)):
+		}
+	}
+}
+
+// retryDelay returns how long to wait after the given failed attempt to store
+// a batch.
+func retryDelay(attempt int) time.Duration {
+	delay := handleRetryDelay
+	for i := 1; i < attempt && delay < maxHandleRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxHandleRetryDelay)
+}
+
+func (c *Consumer[P, C]) quarantine(ctx context.Context, b *batch[C], rejected []application_iot.RejectedCommand, attempts int) bool {
+	for _, r := range rejected {
+		rec := b.decoded[r.Index]
+		c.log.Warn("record rejected", recordAttrs(rec, "batch_id", b.id, "error", r.Err)...)
+		if !c.deadLetter(ctx, r.Err, attempts, rec) {
+			return false
+		}
+	}
+	return true
+}
+
+// storeOffsets stores the offsets of a batch that was dead-lettered instead of
+// being stored, so it is not consumed again. It reports whether the records
+// can be marked.
+func (c *Consumer[P, C]) storeOffsets(ctx context.Context) bool {
+	if c.opts.offsetGroup == "" {
+		return true
+	}
+
+	for {
+		_, err := c.sink.Handle(ctx)
+		if err == nil {
+			return true
+		}
+		c.log.Error("unable to store offsets", "error", err)
+		c.progress.failed(err, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(handleRetryDelay):
+		}
+	}
+}
+
+// deadLetter sends records to the dead-letter sink, retrying until they are
+// produced. It reports whether the records can be marked.
+func (c *Consumer[P, C]) deadLetter(ctx context.Context, cause error, attempts int, records ...*kgo.Record) bool {
+	if c.opts.deadLetter == nil {
+		return true
+	}
+
+	for _, rec := range records {
+		for {
+			err := c.opts.deadLetter.Send(ctx, rec, cause, attempts)
+			if err == nil {
+				break
+			}
+			c.log.Error("unable to dead-letter record", recordAttrs(rec, "error", err)...)
+			c.progress.failed(err, time.Now())
+
+			select {
+			case <-ctx.Done():
+				return false
+			case <-time.After(handleRetryDelay):
+			}
+		}
+	}
+
+	return true
+}
+
+// recordAttrs returns the attributes locating rec in the logs, followed by
+// attrs.
+func recordAttrs(rec *kgo.Record, attrs ...any) []any {
+	return append([]any{"topic", rec.Topic, "partition", rec.Partition, "offset", rec.Offset}, attrs...)
+}
+
+// deviceID returns the device id of payload, if it has an envelope.
+func deviceID[P any](payload *P) string {
+	if e, ok := any(payload).(envelopeCarrier); ok {
+		return e.envelope().DeviceID
+	}
+	return ""
+}
+
+// nextOffsets returns, for every partition of records, the offset following
+// the last record.
+func nextOffsets(records []*kgo.Record) []application_iot.SourceOffset {
+	next := make(map[topicPartition]int64)
+	for _, rec := range records {
+		tp := topicPartition{rec.Topic, rec.Partition}
+		next[tp] = max(next[tp], rec.Offset+1)
+	}
+
+	offsets := make([]application_iot.SourceOffset, 0, len(next))
+	for tp, offset := range next {
+		offsets = append(offsets, application_iot.SourceOffset{Topic: tp.topic, Partition: tp.partition, Offset: offset})
+	}
+	return offsets
+}
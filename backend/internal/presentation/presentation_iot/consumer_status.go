@@ -0,0 +1,166 @@
+package presentation_iot
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// PartitionAssignment reports the partitions assigned to the client of a
+// consumer, by topic.
+type PartitionAssignment interface {
+	Partitions() map[string][]int32
+}
+
+// WithAssignment makes the consumer report its assigned partitions, and not
+// be ready until it has some.
+func WithAssignment(assignment PartitionAssignment) ConsumerOption {
+	return func(o *consumerOptions) {
+		o.assignment = assignment
+	}
+}
+
+// ConsumerStatus describes the progress of a consumer.
+type ConsumerStatus struct {
+	Name               string             `json:"name"`
+	AssignedPartitions map[string][]int32 `json:"assigned_partitions,omitempty"`
+	// Lag is the number of records not stored yet, over the partitions
+	// fetched from.
+	Lag         int64                 `json:"lag"`
+	Partitions  []PartitionStatus     `json:"partitions"`
+	LastFlush   time.Time             `json:"last_flush,omitzero"`
+	LastError   string                `json:"last_error,omitempty"`
+	LastErrorAt time.Time             `json:"last_error_at,omitzero"`
+	FetchErrors []PartitionFetchError `json:"fetch_errors,omitempty"`
+}
+
+// PartitionStatus describes the progress of a consumer on a partition, as of
+// its last fetch from it.
+type PartitionStatus struct {
+	Topic         string `json:"topic"`
+	Partition     int32  `json:"partition"`
+	HighWatermark int64  `json:"high_watermark"`
+	// Offset is the next offset to store, -1 until a batch of the partition
+	// is stored or offsets are committed.
+	Offset int64 `json:"offset"`
+	Lag    int64 `json:"lag"`
+}
+
+// progress tracks what a consumer fetched and stored, for its status. It is
+// safe for concurrent use.
+type progress struct {
+	mu             sync.Mutex
+	highWatermarks map[topicPartition]int64
+	stored         map[topicPartition]int64
+	lastFlush      time.Time
+	lastError      error
+	lastErrorAt    time.Time
+}
+
+func (p *progress) fetched(fp kgo.FetchTopicPartition) {
+	if fp.Err != nil && len(fp.Records) == 0 {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.highWatermarks == nil {
+		p.highWatermarks = make(map[topicPartition]int64)
+	}
+	p.highWatermarks[topicPartition{fp.Topic, fp.Partition}] = fp.HighWatermark
+}
+
+func (p *progress) flushed(records []*kgo.Record, now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.stored == nil {
+		p.stored = make(map[topicPartition]int64)
+	}
+	for _, offset := range nextOffsets(records) {
+		tp := topicPartition{offset.Topic, offset.Partition}
+		p.stored[tp] = max(p.stored[tp], offset.Offset)
+	}
+	p.lastFlush = now
+}
+
+func (p *progress) failed(err error, now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.lastError = err
+	p.lastErrorAt = now
+}
+
+func (c *Consumer[P, C]) Name() string {
+	return c.name
+}
+
+// Status returns the progress of the consumer.
+func (c *Consumer[P, C]) Status() ConsumerStatus {
+	status := ConsumerStatus{
+		Name:        c.name,
+		FetchErrors: c.fetchErrors.list(),
+	}
+	if c.opts.assignment != nil {
+		status.AssignedPartitions = c.opts.assignment.Partitions()
+	}
+	committed := c.client.CommittedOffsets()
+
+	c.progress.mu.Lock()
+	defer c.progress.mu.Unlock()
+
+	status.LastFlush = c.progress.lastFlush
+	if c.progress.lastError != nil {
+		status.LastError = c.progress.lastError.Error()
+		status.LastErrorAt = c.progress.lastErrorAt
+	}
+
+	for tp, hw := range c.progress.highWatermarks {
+		offset, ok := c.progress.stored[tp]
+		if !ok {
+			offset = -1
+			if o, ok := committed[tp.topic][tp.partition]; ok {
+				offset = o.Offset
+			}
+		}
+
+		ps := PartitionStatus{
+			Topic:         tp.topic,
+			Partition:     tp.partition,
+			HighWatermark: hw,
+			Offset:        offset,
+		}
+		if offset >= 0 {
+			ps.Lag = max(hw-offset, 0)
+		}
+		status.Lag += ps.Lag
+		status.Partitions = append(status.Partitions, ps)
+	}
+	slices.SortFunc(status.Partitions, func(a, b PartitionStatus) int {
+		if c := strings.Compare(a.Topic, b.Topic); c != 0 {
+			return c
+		}
+		return int(a.Partition - b.Partition)
+	})
+
+	return status
+}
+
+// Ready returns an error unless the consumer can reach Kafka, has partitions
+// assigned when tracking them and no fetch error retrying does not resolve.
+func (c *Consumer[P, C]) Ready(ctx context.Context) error {
+	if err := c.client.Ping(ctx); err != nil {
+		return fmt.Errorf("%s consumer cannot reach kafka: %w", c.name, err)
+	}
+	if c.opts.assignment != nil && len(c.opts.assignment.Partitions()) == 0 {
+		return fmt.Errorf("%s consumer has no partitions assigned", c.name)
+	}
+	return c.Healthy()
+}
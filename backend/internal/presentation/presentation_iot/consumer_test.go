@@ -0,0 +1,411 @@
+package presentation_iot
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"iiot_system/backend/internal/application/iot"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+const (
+	testTopic = "oee.test"
+	testGroup = "iiot-test"
+)
+
+// testSink records the commands it stores. When block is set, Handle waits for
+// it to be closed or for ctx to be done before storing. Its first calls fail
+// with failures, in order.
+type testSink struct {
+	mu       sync.Mutex
+	commands []string
+	started  chan struct{}
+	once     sync.Once
+	block    chan struct{}
+	failures []error
+}
+
+func newTestSink() *testSink {
+	return &testSink{started: make(chan struct{})}
+}
+
+func (s *testSink) Handle(ctx context.Context, commands ...string) (application_iot.InsertResult, error) {
+	s.once.Do(func() { close(s.started) })
+	if s.block != nil {
+		select {
+		case <-s.block:
+		case <-ctx.Done():
+			return application_iot.InsertResult{}, ctx.Err()
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.failures) > 0 {
+		err := s.failures[0]
+		s.failures = s.failures[1:]
+		return application_iot.InsertResult{}, err
+	}
+	s.commands = append(s.commands, commands...)
+	return application_iot.InsertResult{}, nil
+}
+
+func (s *testSink) stored() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.commands)
+}
+
+// testDeadLetter records the values of the records it receives.
+type testDeadLetter struct {
+	mu     sync.Mutex
+	values []string
+}
+
+func (d *testDeadLetter) Send(_ context.Context, rec *kgo.Record, _ error, _ int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.values = append(d.values, string(rec.Value))
+	return nil
+}
+
+func (d *testDeadLetter) sent() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.values)
+}
+
+// newTestConsumer starts a fake cluster holding n records on testTopic and
+// returns a consumer of them along with the number of records it decoded.
+func newTestConsumer(t *testing.T, n int, sink *testSink, opts ...ConsumerOption) (*Consumer[string, string], *kgo.Client, *atomic.Int64) {
+	t.Helper()
+
+	return newTestConsumerOf(t, testRecords(n), sink, opts...)
+}
+
+// testRecords returns n records holding their index.
+func testRecords(n int) []*kgo.Record {
+	records := make([]*kgo.Record, n)
+	for i := range records {
+		records[i] = kgo.StringRecord(strconv.Itoa(i))
+	}
+	return records
+}
+
+// newTestConsumerOf is newTestConsumer for the given records.
+func newTestConsumerOf(t *testing.T, records []*kgo.Record, sink *testSink, opts ...ConsumerOption) (*Consumer[string, string], *kgo.Client, *atomic.Int64) {
+	t.Helper()
+
+	cluster, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.SeedTopics(1, testTopic))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cluster.Close)
+
+	produce(t, cluster, records)
+	return newTestConsumerOn(t, cluster, sink, nil, opts...)
+}
+
+// produce produces records to testTopic on cluster.
+func produce(t *testing.T, cluster *kfake.Cluster, records []*kgo.Record) {
+	t.Helper()
+
+	producer, err := kgo.NewClient(kgo.SeedBrokers(cluster.ListenAddrs()...), kgo.DefaultProduceTopic(testTopic))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer producer.Close()
+	for _, rec := range records {
+		if err := producer.ProduceSync(context.Background(), rec).FirstErr(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// newTestConsumerOn returns a consumer of testTopic on cluster, created with
+// the additional client options, along with the number of records it
+// decoded.
+func newTestConsumerOn(t *testing.T, cluster *kfake.Cluster, sink Sink[string], clientOpts []kgo.Opt, opts ...ConsumerOption) (*Consumer[string, string], *kgo.Client, *atomic.Int64) {
+	t.Helper()
+
+	client, err := kgo.NewClient(append([]kgo.Opt{
+		kgo.SeedBrokers(cluster.ListenAddrs()...),
+		kgo.ConsumerGroup(testGroup),
+		kgo.ConsumeTopics(testTopic),
+		kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()),
+		kgo.AutoCommitMarks(),
+		kgo.BlockRebalanceOnPoll(),
+	}, clientOpts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Close)
+
+	var decoded atomic.Int64
+	decode := func(rec *kgo.Record) (string, error) {
+		decoded.Add(1)
+		return string(rec.Value), nil
+	}
+	validate := func(string) ValidationErrors { return nil }
+	mapTo := func(payload string) string { return payload }
+
+	return NewConsumer("test", client, decode, validate, mapTo, sink, opts...), client, &decoded
+}
+
+// committedOffset returns the offset committed by testGroup, or -1.
+func committedOffset(t *testing.T, client *kgo.Client) int64 {
+	t.Helper()
+
+	offsets, err := kadm.NewClient(client).FetchOffsets(context.Background(), testGroup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	offset, ok := offsets.Lookup(testTopic, 0)
+	if !ok {
+		return -1
+	}
+	return offset.At
+}
+
+// startConsumer runs c until the returned cancel is called, the error of
+// Start being sent on the returned channel.
+func startConsumer(c *Consumer[string, string]) (context.CancelFunc, <-chan error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- c.Start(ctx)
+	}()
+	return cancel, errs
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func waitStopped(t *testing.T, errs <-chan error) error {
+	t.Helper()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-time.After(10 * time.Second):
+		t.Fatal("consumer did not stop")
+		return nil
+	}
+}
+
+func TestConsumerShutdownFlushesPendingBatch(t *testing.T) {
+	sink := newTestSink()
+	consumer, client, decoded := newTestConsumer(t, 10, sink,
+		WithBatching(BatchOptions{MaxRows: 100, MaxWait: time.Hour}),
+	)
+
+	cancel, errs := startConsumer(consumer)
+	waitFor(t, "records to be polled", func() bool { return decoded.Load() == 10 })
+	if got := sink.stored(); got != 0 {
+		t.Fatalf("stored %d records before the batch was due", got)
+	}
+	cancel()
+
+	if err := waitStopped(t, errs); err != nil {
+		t.Fatalf("Start() = %v, want nil", err)
+	}
+	if got := sink.stored(); got != 10 {
+		t.Errorf("stored %d records, want 10", got)
+	}
+	if got := committedOffset(t, client); got != 10 {
+		t.Errorf("committed offset %d, want 10", got)
+	}
+}
+
+func TestConsumerShutdownFinishesInFlightBatch(t *testing.T) {
+	sink := newTestSink()
+	sink.block = make(chan struct{})
+	consumer, client, decoded := newTestConsumer(t, 10, sink,
+		WithBatching(BatchOptions{MaxRows: 4, MaxWait: time.Hour}),
+	)
+
+	cancel, errs := startConsumer(consumer)
+	<-sink.started
+	waitFor(t, "records to be polled", func() bool { return decoded.Load() == 10 })
+	cancel()
+	// The batch being stored when shutdown starts is not abandoned.
+	time.Sleep(50 * time.Millisecond)
+	close(sink.block)
+
+	if err := waitStopped(t, errs); err != nil {
+		t.Fatalf("Start() = %v, want nil", err)
+	}
+	if got := sink.stored(); got != 10 {
+		t.Errorf("stored %d records, want 10", got)
+	}
+	if got := committedOffset(t, client); got != 10 {
+		t.Errorf("committed offset %d, want 10", got)
+	}
+}
+
+func TestConsumerShutdownDrainTimeout(t *testing.T) {
+	sink := newTestSink()
+	sink.block = make(chan struct{})
+	consumer, client, _ := newTestConsumer(t, 10, sink,
+		WithBatching(BatchOptions{MaxRows: 4, MaxWait: time.Hour}),
+		WithDrainTimeout(100*time.Millisecond),
+	)
+
+	cancel, errs := startConsumer(consumer)
+	<-sink.started
+	cancel()
+
+	err := waitStopped(t, errs)
+	if err == nil {
+		t.Fatal("Start() = nil, want a drain timeout error")
+	}
+	if got := sink.stored(); got != 0 {
+		t.Errorf("stored %d records, want 0", got)
+	}
+	if got := committedOffset(t, client); got != -1 {
+		t.Errorf("committed offset %d, want none", got)
+	}
+}
+
+func TestConsumerStatus(t *testing.T) {
+	sink := newTestSink()
+	consumer, _, _ := newTestConsumer(t, 10, sink,
+		WithBatching(BatchOptions{MaxRows: 10, MaxWait: time.Hour}),
+	)
+
+	cancel, errs := startConsumer(consumer)
+	defer cancel()
+	waitFor(t, "records to be stored", func() bool { return !consumer.Status().LastFlush.IsZero() })
+
+	status := consumer.Status()
+	if len(status.Partitions) != 1 {
+		t.Fatalf("Status().Partitions = %+v, want one partition", status.Partitions)
+	}
+	if p := status.Partitions[0]; p.HighWatermark != 10 || p.Offset != 10 || p.Lag != 0 {
+		t.Errorf("Status().Partitions[0] = %+v, want a high watermark and offset of 10", p)
+	}
+	if status.Lag != 0 || status.LastError != "" {
+		t.Errorf("Status() = %+v, want no lag nor error", status)
+	}
+
+	cancel()
+	if err := waitStopped(t, errs); err != nil {
+		t.Errorf("Start() = %v, want nil", err)
+	}
+}
+
+func TestConsumerRetriesTransientErrors(t *testing.T) {
+	sink := newTestSink()
+	// A lost connection is transient, however long it lasts.
+	sink.failures = []error{errors.New("connection refused"), errors.New("connection refused")}
+	dlq := &testDeadLetter{}
+	consumer, client, _ := newTestConsumer(t, 10, sink,
+		WithBatching(BatchOptions{MaxRows: 10, MaxWait: 10 * time.Millisecond}),
+		WithDeadLetter(dlq),
+	)
+
+	cancel, errs := startConsumer(consumer)
+	waitFor(t, "records to be stored", func() bool { return sink.stored() == 10 })
+	cancel()
+	if err := waitStopped(t, errs); err != nil {
+		t.Fatalf("Start() = %v, want nil", err)
+	}
+
+	if got := dlq.sent(); got != 0 {
+		t.Errorf("dead-lettered %d records, want none", got)
+	}
+	if got := committedOffset(t, client); got != 10 {
+		t.Errorf("committed offset %d, want 10", got)
+	}
+}
+
+func TestConsumerDeadLettersPermanentErrors(t *testing.T) {
+	sink := newTestSink()
+	sink.failures = []error{&pgconn.PgError{Code: "22001", Message: "value too long"}}
+	dlq := &testDeadLetter{}
+	consumer, client, _ := newTestConsumer(t, 10, sink,
+		WithBatching(BatchOptions{MaxRows: 10, MaxWait: 10 * time.Millisecond}),
+		WithDeadLetter(dlq),
+	)
+
+	cancel, errs := startConsumer(consumer)
+	waitFor(t, "records to be dead-lettered", func() bool { return dlq.sent() == 10 })
+	cancel()
+	if err := waitStopped(t, errs); err != nil {
+		t.Fatalf("Start() = %v, want nil", err)
+	}
+
+	if got := sink.stored(); got != 0 {
+		t.Errorf("stored %d records, want 0", got)
+	}
+	if got := committedOffset(t, client); got != 10 {
+		t.Errorf("committed offset %d, want 10", got)
+	}
+}
+
+func TestConsumerPausesWhileBehind(t *testing.T) {
+	const n = 200
+	sink := newTestSink()
+	sink.block = make(chan struct{})
+	consumer, client, decoded := newTestConsumer(t, n, sink,
+		WithMaxPollRecords(5),
+		WithBatching(BatchOptions{MaxRows: 5, MaxWait: 10 * time.Millisecond, MaxBuffered: 10}),
+	)
+
+	cancel, errs := startConsumer(consumer)
+	defer cancel()
+
+	// The sink holds the first batch while the next ones fill the buffer.
+	<-sink.started
+	waitFor(t, "the partition to be paused", func() bool {
+		return len(client.PauseFetchPartitions(nil)[testTopic]) == 1
+	})
+	paused := decoded.Load()
+	time.Sleep(200 * time.Millisecond)
+	if got := decoded.Load(); got != paused || got >= n {
+		t.Errorf("decoded %d records then %d while paused, want it to stop below %d", paused, got, n)
+	}
+
+	// Fetching resumes once the sink stores the batches.
+	close(sink.block)
+	waitFor(t, "records to be stored", func() bool { return sink.stored() == n })
+	waitFor(t, "the partition to be resumed", func() bool {
+		return len(client.PauseFetchPartitions(nil)) == 0
+	})
+
+	cancel()
+	if err := waitStopped(t, errs); err != nil {
+		t.Errorf("Start() = %v, want nil", err)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	for attempt, want := range map[int]time.Duration{
+		1:  handleRetryDelay,
+		2:  2 * handleRetryDelay,
+		3:  4 * handleRetryDelay,
+		20: maxHandleRetryDelay,
+	} {
+		if got := retryDelay(attempt); got != want {
+			t.Errorf("retryDelay(%d) = %s, want %s", attempt, got, want)
+		}
+	}
+}
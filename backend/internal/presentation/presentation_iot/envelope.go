@@ -0,0 +1,222 @@
+package presentation_iot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	application_iot "iiot_system/backend/internal/application/iot"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// Payload types sent by the devices in the envelope `payload_type` field.
+const (
+	PayloadTypeTelemetry    = "telemetry"
+	PayloadTypeProduction   = "event_production"
+	PayloadTypeAlert        = "event_alert"
+	PayloadTypeStatusUpdate = "event_status_update"
+)
+
+// defaultSchemaVersion is assumed for messages that predate the
+// `schema_version` field.
+const defaultSchemaVersion = 1
+
+var (
+	ErrUnknownPayloadType       = errors.New("unknown payload type")
+	ErrUnsupportedSchemaVersion = errors.New("unsupported schema version")
+)
+
+// Envelope holds the fields shared by every device message. Data is left raw
+// until the payload type and schema version select its decoder.
+type Envelope struct {
+	SchemaVersion int             `json:"schema_version"`
+	PayloadType   string          `json:"payload_type"`
+	DeviceID      string          `json:"device_id"`
+	Timestamp     Timestamp       `json:"timestamp"`
+	TimestampUnit TimeUnit        `json:"timestamp_unit,omitempty"`
+	Data          json.RawMessage `json:"data"`
+
+	// raw is the whole message the envelope was decoded from.
+	raw []byte
+	// time is the event time resolved by the consumer TimestampPolicy.
+	time time.Time
+	// ingestion is read from the EMQX message and the Kafka record.
+	ingestion application_iot.IngestionMetadata
+}
+
+// Time returns the time of the event.
+func (e Envelope) Time() time.Time {
+	return e.time
+}
+
+// Ingestion returns how the event reached the backend.
+func (e Envelope) Ingestion() application_iot.IngestionMetadata {
+	return e.ingestion
+}
+
+// envelopeCarrier is implemented by payloads embedding Envelope.
+type envelopeCarrier interface {
+	envelope() *Envelope
+}
+
+func (e *Envelope) envelope() *Envelope {
+	return e
+}
+
+func decodeEnvelope(raw []byte) (Envelope, error) {
+	env := Envelope{SchemaVersion: defaultSchemaVersion}
+	if err := json.Unmarshal(raw, &env); err != nil {
+		return Envelope{}, fmt.Errorf("error unmarshaling envelope JSON: %w", err)
+	}
+	env.raw = raw
+	return env, nil
+}
+
+// PayloadDecoder decodes the message of a given schema version.
+type PayloadDecoder[P any] func(env Envelope) (P, error)
+
+// Upcaster converts the data of a message to the next schema version.
+type Upcaster func(data json.RawMessage) (json.RawMessage, error)
+
+// DecodeEnvelopeJSON decodes the whole message into P, which usually embeds
+// Envelope next to a typed Data field.
+func DecodeEnvelopeJSON[P any](env Envelope) (P, error) {
+	var payload P
+	if err := json.Unmarshal(env.raw, &payload); err != nil {
+		return payload, fmt.Errorf("error unmarshaling %s payload JSON: %w", env.PayloadType, err)
+	}
+
+	if c, ok := any(&payload).(envelopeCarrier); ok {
+		e := c.envelope()
+		e.SchemaVersion = env.SchemaVersion
+		e.raw = env.raw
+		e.ingestion = env.ingestion
+	}
+	return payload, nil
+}
+
+// PayloadRegistry decodes the messages of one payload type. Messages are
+// routed to the decoder registered for their schema version; older versions
+// without a decoder are upcast step by step until one is found. Messages of
+// another payload type or of a version that cannot be reached are rejected.
+type PayloadRegistry[P any] struct {
+	payloadType string
+	decoders    map[int]PayloadDecoder[P]
+	upcasters   map[int]Upcaster
+}
+
+func NewPayloadRegistry[P any](payloadType string) *PayloadRegistry[P] {
+	return &PayloadRegistry[P]{
+		payloadType: payloadType,
+		decoders:    make(map[int]PayloadDecoder[P]),
+		upcasters:   make(map[int]Upcaster),
+	}
+}
+
+// Register sets the decoder of a schema version.
+func (r *PayloadRegistry[P]) Register(version int, decode PayloadDecoder[P]) *PayloadRegistry[P] {
+	r.decoders[version] = decode
+	return r
+}
+
+// RegisterUpcaster sets the upcaster converting version `from` to `from+1`.
+func (r *PayloadRegistry[P]) RegisterUpcaster(from int, upcast Upcaster) *PayloadRegistry[P] {
+	r.upcasters[from] = upcast
+	return r
+}
+
+// Decode is a Decoder for records produced by the EMQX Kafka bridge.
+func (r *PayloadRegistry[P]) Decode(rec *kgo.Record) (P, error) {
+	var payload P
+
+	msg, err := decodeEMQXMessage(rec)
+	if err != nil {
+		return payload, err
+	}
+
+	env, err := decodeEnvelope([]byte(msg.Payload))
+	if err != nil {
+		return payload, err
+	}
+	env.ingestion = msg.ingestion(rec)
+
+	if env.PayloadType != r.payloadType {
+		return payload, fmt.Errorf("%w: %q, expected %q", ErrUnknownPayloadType, env.PayloadType, r.payloadType)
+	}
+
+	for {
+		if decode, ok := r.decoders[env.SchemaVersion]; ok {
+			return decode(env)
+		}
+
+		upcast, ok := r.upcasters[env.SchemaVersion]
+		if !ok {
+			return payload, fmt.Errorf("%w: %s version %d", ErrUnsupportedSchemaVersion, env.PayloadType, env.SchemaVersion)
+		}
+
+		if env, err = upcastEnvelope(env, upcast); err != nil {
+			return payload, err
+		}
+	}
+}
+
+// upcastEnvelope rewrites the data and schema version of the raw message,
+// leaving its other fields, including those only a payload type knows of,
+// unchanged.
+func upcastEnvelope(env Envelope, upcast Upcaster) (Envelope, error) {
+	data, err := upcast(env.Data)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("error upcasting %s version %d: %w", env.PayloadType, env.SchemaVersion, err)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(env.raw, &fields); err != nil {
+		return Envelope{}, fmt.Errorf("error unmarshaling %s payload JSON: %w", env.PayloadType, err)
+	}
+
+	env.Data = data
+	env.SchemaVersion++
+	fields["data"] = data
+	if fields["schema_version"], err = json.Marshal(env.SchemaVersion); err != nil {
+		return Envelope{}, fmt.Errorf("error marshaling upcast %s payload: %w", env.PayloadType, err)
+	}
+	if env.raw, err = json.Marshal(fields); err != nil {
+		return Envelope{}, fmt.Errorf("error marshaling upcast %s payload: %w", env.PayloadType, err)
+	}
+	return env, nil
+}
+
+// emqxMessage is a record produced by the EMQX Kafka bridge, holding the
+// columns selected by the rules in emqx_broker/bootstrap_emqx.sh.
+type emqxMessage struct {
+	// Topic is the MQTT topic the device published to.
+	Topic string `json:"topic"`
+	// Payload is the device message JSON as a string.
+	Payload  string `json:"payload"`
+	ClientID string `json:"clientid"`
+	// Timestamp is when the broker received the message, in milliseconds.
+	Timestamp int64 `json:"timestamp"`
+}
+
+func decodeEMQXMessage(rec *kgo.Record) (emqxMessage, error) {
+	var msg emqxMessage
+	if err := json.Unmarshal(rec.Value, &msg); err != nil {
+		return msg, fmt.Errorf("error unmarshaling outer JSON: %w", err)
+	}
+	return msg, nil
+}
+
+func (m emqxMessage) ingestion(rec *kgo.Record) application_iot.IngestionMetadata {
+	metadata := application_iot.IngestionMetadata{
+		MQTTTopic:      m.Topic,
+		MQTTClientID:   m.ClientID,
+		KafkaPartition: rec.Partition,
+		KafkaOffset:    rec.Offset,
+	}
+	if m.Timestamp > 0 {
+		metadata.BrokerReceivedAt = time.UnixMilli(m.Timestamp).UTC()
+	}
+	return metadata
+}
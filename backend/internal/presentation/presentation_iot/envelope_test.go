@@ -0,0 +1,52 @@
+package presentation_iot
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestUpcastKeepsPayloadFields(t *testing.T) {
+	type measurementPayload struct {
+		Envelope
+		Measurement string `json:"measurement"`
+		Data        struct {
+			Value float64 `json:"value"`
+		} `json:"data"`
+	}
+
+	// Version 1 sent the value as `reading`.
+	registry := NewPayloadRegistry[measurementPayload](PayloadTypeTelemetry).
+		Register(2, DecodeEnvelopeJSON[measurementPayload]).
+		RegisterUpcaster(1, func(data json.RawMessage) (json.RawMessage, error) {
+			var v1 struct {
+				Reading float64 `json:"reading"`
+			}
+			if err := json.Unmarshal(data, &v1); err != nil {
+				return nil, err
+			}
+			return json.Marshal(map[string]float64{"value": v1.Reading})
+		})
+
+	payload := `{"payload_type": "telemetry", "device_id": "press-1", "timestamp": 1700000000000,
+		"measurement": "spindle", "data": {"reading": 21.5}}`
+	value, err := json.Marshal(emqxMessage{Topic: "oee/press-1/telemetry", Payload: payload})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := registry.Decode(&kgo.Record{Value: value})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Measurement != "spindle" {
+		t.Errorf("measurement %q, want %q", got.Measurement, "spindle")
+	}
+	if got.Data.Value != 21.5 {
+		t.Errorf("value %v, want 21.5", got.Data.Value)
+	}
+	if got.SchemaVersion != 2 || got.DeviceID != "press-1" {
+		t.Errorf("envelope %+v, want version 2 from press-1", got.Envelope)
+	}
+}
@@ -0,0 +1,190 @@
+package presentation_iot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+const (
+	// fetchErrorBackoff is how long a consumer waits before polling again
+	// when a poll returned errors but no records.
+	fetchErrorBackoff = time.Second
+	// fetchErrorLogEvery logs only every that many errors of a partition once
+	// the first one is logged.
+	fetchErrorLogEvery = 100
+)
+
+// FetchErrorClass tells how a consumer reacts to a fetch error.
+type FetchErrorClass string
+
+const (
+	// FetchErrorRetriable errors, such as a broker being unreachable or a
+	// partition leader moving, are logged and the fetch retried.
+	FetchErrorRetriable FetchErrorClass = "retriable"
+	// FetchErrorAuthorization errors are raised when the credentials of the
+	// client are rejected or lack an ACL. They need an operator to act.
+	FetchErrorAuthorization FetchErrorClass = "authorization"
+	// FetchErrorUnknownTopic errors are raised when a consumed topic or
+	// partition does not exist, usually because it was deleted.
+	FetchErrorUnknownTopic FetchErrorClass = "unknown_topic"
+	// FetchErrorUnexpected errors are any other error. They are retried too
+	// but reported as unhealthy since retrying may not resolve them.
+	FetchErrorUnexpected FetchErrorClass = "unexpected"
+)
+
+// ClassifyFetchError returns the class of an error returned by a poll.
+func ClassifyFetchError(err error) FetchErrorClass {
+	switch {
+	case errors.Is(err, kerr.TopicAuthorizationFailed),
+		errors.Is(err, kerr.GroupAuthorizationFailed),
+		errors.Is(err, kerr.ClusterAuthorizationFailed),
+		errors.Is(err, kerr.SaslAuthenticationFailed):
+		return FetchErrorAuthorization
+	// Unknown topics are retriable for Kafka since metadata may be stale, but
+	// one that stays unknown is reported.
+	case errors.Is(err, kerr.UnknownTopicOrPartition),
+		errors.Is(err, kerr.UnknownTopicID):
+		return FetchErrorUnknownTopic
+	}
+
+	// Network errors cover unreachable brokers, which the client keeps
+	// dialing, and data loss is only a notice that consuming went on from
+	// another offset.
+	var netErr net.Error
+	var dataLoss *kgo.ErrDataLoss
+	if kerr.IsRetriable(err) || kgo.IsRetryableBrokerErr(err) || errors.As(err, &netErr) || errors.As(err, &dataLoss) {
+		return FetchErrorRetriable
+	}
+	return FetchErrorUnexpected
+}
+
+// Healthy reports whether a consumer keeps flowing despite errors of class.
+func (c FetchErrorClass) Healthy() bool {
+	return c == FetchErrorRetriable
+}
+
+// PartitionFetchError is the last fetch error of a partition, kept until
+// records are fetched from it again. Errors not tied to a partition, such as
+// group session errors, have an empty Topic and a Partition of -1.
+type PartitionFetchError struct {
+	Topic     string          `json:"topic"`
+	Partition int32           `json:"partition"`
+	Class     FetchErrorClass `json:"class"`
+	Err       error           `json:"-"`
+	// Count is the number of errors since the partition last fetched records.
+	Count int       `json:"count"`
+	First time.Time `json:"first"`
+	Last  time.Time `json:"last"`
+}
+
+func (e PartitionFetchError) MarshalJSON() ([]byte, error) {
+	type fields PartitionFetchError
+	return json.Marshal(struct {
+		fields
+		Error string `json:"error"`
+	}{fields(e), e.Err.Error()})
+}
+
+// fetchErrors tracks the fetch errors of a consumer by partition, so an error
+// on one partition is reported without stopping the others. It is safe for
+// concurrent use.
+type fetchErrors struct {
+	mu     sync.Mutex
+	errors map[topicPartition]PartitionFetchError
+}
+
+type topicPartition struct {
+	topic     string
+	partition int32
+}
+
+// observe records err for a partition and returns the updated error.
+func (f *fetchErrors) observe(topic string, partition int32, err error, now time.Time) PartitionFetchError {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.errors == nil {
+		f.errors = make(map[topicPartition]PartitionFetchError)
+	}
+	key := topicPartition{topic, partition}
+	e, ok := f.errors[key]
+	if !ok {
+		e = PartitionFetchError{Topic: topic, Partition: partition, First: now}
+	}
+	e.Class = ClassifyFetchError(err)
+	e.Err = err
+	e.Count++
+	e.Last = now
+	f.errors[key] = e
+	return e
+}
+
+// recovered clears the errors of a partition records were fetched from, and
+// those not tied to a partition.
+func (f *fetchErrors) recovered(topic string, partition int32) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.errors) == 0 {
+		return
+	}
+	delete(f.errors, topicPartition{topic, partition})
+	delete(f.errors, topicPartition{topic, -1})
+	delete(f.errors, topicPartition{"", -1})
+}
+
+// list returns the outstanding errors sorted by topic and partition.
+func (f *fetchErrors) list() []PartitionFetchError {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	list := make([]PartitionFetchError, 0, len(f.errors))
+	for _, e := range f.errors {
+		list = append(list, e)
+	}
+	slices.SortFunc(list, func(a, b PartitionFetchError) int {
+		if c := strings.Compare(a.Topic, b.Topic); c != 0 {
+			return c
+		}
+		return int(a.Partition - b.Partition)
+	})
+	return list
+}
+
+// FetchErrors returns the fetch errors of the partitions that have not
+// fetched records since.
+func (c *Consumer[P, C]) FetchErrors() []PartitionFetchError {
+	return c.fetchErrors.list()
+}
+
+// Healthy returns an error describing the outstanding fetch errors that
+// retrying does not resolve, such as authorization or unknown topic errors.
+func (c *Consumer[P, C]) Healthy() error {
+	var errs []error
+	for _, e := range c.fetchErrors.list() {
+		if e.Class.Healthy() {
+			continue
+		}
+		errs = append(errs, fmt.Errorf("%s consumer: %s error on %s: %w", c.name, e.Class, e.location(), e.Err))
+	}
+	return errors.Join(errs...)
+}
+
+func (e PartitionFetchError) location() string {
+	if e.Topic == "" {
+		return "client"
+	}
+	if e.Partition < 0 {
+		return e.Topic
+	}
+	return fmt.Sprintf("%s[%d]", e.Topic, e.Partition)
+}
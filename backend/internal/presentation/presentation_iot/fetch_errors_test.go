@@ -0,0 +1,108 @@
+package presentation_iot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestClassifyFetchError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want FetchErrorClass
+	}{
+		{kerr.NotLeaderForPartition, FetchErrorRetriable},
+		{kerr.RequestTimedOut, FetchErrorRetriable},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, FetchErrorRetriable},
+		{&kgo.ErrDataLoss{Topic: "oee.telemetry", ConsumedTo: 10, ResetTo: 5}, FetchErrorRetriable},
+		{kerr.TopicAuthorizationFailed, FetchErrorAuthorization},
+		{&kgo.ErrGroupSession{Err: kerr.GroupAuthorizationFailed}, FetchErrorAuthorization},
+		{kerr.SaslAuthenticationFailed, FetchErrorAuthorization},
+		{kerr.UnknownTopicOrPartition, FetchErrorUnknownTopic},
+		{fmt.Errorf("fetching: %w", kerr.UnknownTopicID), FetchErrorUnknownTopic},
+		{kerr.UnsupportedVersion, FetchErrorUnexpected},
+		{errors.New("boom"), FetchErrorUnexpected},
+	}
+	for _, tt := range tests {
+		if got := ClassifyFetchError(tt.err); got != tt.want {
+			t.Errorf("ClassifyFetchError(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestConsumerReportsFetchErrors(t *testing.T) {
+	const missingTopic = "oee.missing"
+
+	cluster, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.SeedTopics(1, testTopic))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cluster.Close)
+
+	producer, err := kgo.NewClient(kgo.SeedBrokers(cluster.ListenAddrs()...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer producer.Close()
+	produce := func(topic string, n int) {
+		t.Helper()
+		for i := range n {
+			rec := &kgo.Record{Topic: topic, Value: []byte(strconv.Itoa(i))}
+			if err := producer.ProduceSync(context.Background(), rec).FirstErr(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	produce(testTopic, 5)
+
+	sink := newTestSink()
+	consumer, _, _ := newTestConsumerOn(t, cluster, sink, []kgo.Opt{
+		kgo.ConsumeTopics(testTopic, missingTopic),
+		kgo.KeepRetryableFetchErrors(),
+		kgo.MetadataMinAge(50 * time.Millisecond),
+		kgo.MetadataMaxAge(100 * time.Millisecond),
+	}, WithBatching(BatchOptions{MaxRows: 5, MaxWait: 10 * time.Millisecond}))
+
+	cancel, errs := startConsumer(consumer)
+	defer cancel()
+
+	// The missing topic is reported while the other keeps being consumed.
+	waitFor(t, "the missing topic to be reported", func() bool {
+		return errors.Is(consumer.Healthy(), kerr.UnknownTopicOrPartition)
+	})
+	waitFor(t, "records to be stored", func() bool { return sink.stored() == 5 })
+	if err := consumer.Healthy(); !errors.Is(err, kerr.UnknownTopicOrPartition) {
+		t.Errorf("Healthy() = %v, want an unknown topic error", err)
+	}
+	var reported bool
+	for _, e := range consumer.FetchErrors() {
+		if e.Topic == missingTopic && e.Class == FetchErrorUnknownTopic && e.Count > 0 {
+			reported = true
+		}
+	}
+	if !reported {
+		t.Errorf("FetchErrors() = %+v, want an unknown topic error on %s", consumer.FetchErrors(), missingTopic)
+	}
+
+	// Fetching records from the topic once created clears its error.
+	if _, err := kadm.NewClient(producer).CreateTopic(context.Background(), 1, 1, nil, missingTopic); err != nil {
+		t.Fatal(err)
+	}
+	produce(missingTopic, 5)
+	waitFor(t, "records of the created topic to be stored", func() bool { return sink.stored() == 10 })
+	waitFor(t, "the consumer to recover", func() bool { return consumer.Healthy() == nil })
+
+	cancel()
+	if err := waitStopped(t, errs); err != nil {
+		t.Errorf("Start() = %v, want nil", err)
+	}
+}
@@ -1,23 +1,16 @@
 package presentation_iot
 
 import (
-	"context"
-	"encoding/json"
-	"fmt"
-	"time"
-
 	application_iot "iiot_system/backend/internal/application/iot"
-	"iiot_system/backend/internal/infrastructure/topics"
 
 	"github.com/twmb/franz-go/pkg/kgo"
 )
 
-// AlertPayload represents the JSON structure of the `payload` field.
+// ProductionPayload represents the JSON structure of the `payload` field.
 type ProductionPayload struct {
-	Timestamp int64  `json:"timestamp"`
-	DeviceID  string `json:"device_id" `
-	Data      struct {
-		ProductionType string `json:"production_type" `
+	Envelope
+	Data struct {
+		ProductionType string `json:"event_type" `
 		ProductSku     string `json:"product_sku" `
 		UnitCount      int32  `json:"unit_count" `
 		BatchID        string `json:"batch_id" `
@@ -25,81 +18,36 @@ type ProductionPayload struct {
 	} `json:"data"`
 }
 
-type IiotProductionConsumer struct {
-	client  *kgo.Client
-	handler *application_iot.InsertProductionCommandHandler
-}
+var productionPayloads = NewPayloadRegistry[ProductionPayload](PayloadTypeProduction).
+	Register(1, DecodeEnvelopeJSON[ProductionPayload])
 
-func NewIiotProductionConsumer(handler *application_iot.InsertProductionCommandHandler, client *kgo.Client) (*IiotProductionConsumer, error) {
-	c := &IiotProductionConsumer{
-		client:  client,
-		handler: handler,
-	}
-	return c, nil
-}
+type IiotProductionConsumer = Consumer[ProductionPayload, application_iot.InsertProductionCommand]
 
-func (c IiotProductionConsumer) Start(ctx context.Context) error {
-	if err := c.client.Ping(ctx); err != nil {
-		return fmt.Errorf("unable to ping kafka: %v", err)
-	}
-
-	for {
-		select {
-		case <-ctx.Done():
-			return fmt.Errorf("shutting down iiot production consumer")
-		default:
-			fmt.Printf("polling iiot production consumer\n")
-			c.poll(ctx)
-		}
-	}
+func NewIiotProductionConsumer(handler *application_iot.InsertProductionCommandHandler, client *kgo.Client, opts ...ConsumerOption) (*IiotProductionConsumer, error) {
+	return NewConsumer("iiot production", client, productionPayloads.Decode, validateProduction, mapProduction, handler, opts...), nil
 }
 
-func (c IiotProductionConsumer) poll(ctx context.Context) {
-	fetches := c.client.PollFetches(ctx)
-	if fetches.IsClientClosed() {
-		return
-	}
-
-	if fetches.Empty() {
-		return
-	}
-
-	fetches.EachError(func(_ string, _ int32, err error) {
-		panic(err)
-	})
-
-	r := fetches.Records()
-
-	commands := make([]application_iot.InsertProductionCommand, 0, len(r))
-	for _, rec := range r {
-		if rec.Topic == topics.ProductionTopic {
-			var outer outerData
-			if err := json.Unmarshal(rec.Value, &outer); err != nil {
-				fmt.Printf("error unmarshaling outer JSON: %v\n", err)
-				continue
-			}
-
-			var production ProductionPayload
-			if err := json.Unmarshal([]byte(outer.Payload), &production); err != nil {
-				fmt.Printf("error unmarshaling alert JSON: %v\n", err)
-				continue
-			}
+// validateProduction checks the payload fits the iot_production_events columns.
+func validateProduction(production ProductionPayload) ValidationErrors {
+	var v fieldValidator
+	v.envelope(production.Envelope)
+	v.text("data.event_type", production.Data.ProductionType, 50)
+	v.text("data.product_sku", production.Data.ProductSku, 50)
+	v.integer("data.unit_count", int64(production.Data.UnitCount), 1, 1_000_000)
+	v.text("data.batch_id", production.Data.BatchID, 50)
+	v.text("data.quality_status", production.Data.QualityStatus, 50)
+	return v.errs
+}
 
-			commands = append(commands, application_iot.InsertProductionCommand{
-				Time:           time.Unix(production.Timestamp, 0).UTC(),
-				DeviceID:       production.DeviceID,            // Replace with actual device ID from rec.Key or rec.Value
-				ProductionType: production.Data.ProductionType, // Replace with actual alert type from rec.Value
-				ProductSku:     production.Data.ProductSku,     // Replace with actual severity from rec.Value
-				UnitCount:      production.Data.UnitCount,
-				BatchID:        production.Data.BatchID,
-				QualityStatus:  production.Data.QualityStatus,
-			})
-		}
+func mapProduction(production ProductionPayload) application_iot.InsertProductionCommand {
+	return application_iot.InsertProductionCommand{
+		Time:           production.Time(),
+		DeviceID:       production.DeviceID,
+		ProductionType: production.Data.ProductionType,
+		ProductSku:     production.Data.ProductSku,
+		UnitCount:      production.Data.UnitCount,
+		BatchID:        production.Data.BatchID,
+		QualityStatus:  production.Data.QualityStatus,
+		Ingestion:      production.Ingestion(),
 	}
-	if err := c.handler.Handle(ctx, commands...); err != nil {
-		fmt.Printf("error handling insert telemetry command: %v\n", err)
-		return
-	}
-
-	c.client.MarkCommitRecords(r...)
 }
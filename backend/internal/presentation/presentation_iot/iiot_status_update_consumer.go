@@ -1,101 +1,58 @@
 package presentation_iot
 
 import (
-	"context"
-	"encoding/json"
-	"fmt"
-	"time"
-
 	"iiot_system/backend/internal/application/iot"
-	"iiot_system/backend/internal/infrastructure/topics"
+	domain_iot_telemetry "iiot_system/backend/internal/domain/iot/telemetry"
 
 	"github.com/twmb/franz-go/pkg/kgo"
 )
 
-// AlertPayload represents the JSON structure of the `payload` field.
+// StatusUpdatePayload represents the JSON structure of the `payload` field.
 type StatusUpdatePayload struct {
-	Timestamp int64  `json:"timestamp"`
-	DeviceID  string `json:"device_id"`
-	Data      struct {
+	Envelope
+	Data struct {
 		OldStatus string `json:"old_status"`
 		NewStatus string `json:"new_status"`
 		Reason    string `json:"reason"`
 	} `json:"data"`
 }
 
-type IiotStatusUpdateConsumer struct {
-	client  *kgo.Client
-	handler *application_iot.InsertStatusUpdateCommandHandler
-}
-
-func NewIiotStatusUpdateConsumer(handler *application_iot.InsertStatusUpdateCommandHandler, client *kgo.Client) (*IiotStatusUpdateConsumer, error) {
-	c := &IiotStatusUpdateConsumer{
-		client:  client,
-		handler: handler,
-	}
-	return c, nil
-}
+var statusUpdatePayloads = NewPayloadRegistry[StatusUpdatePayload](PayloadTypeStatusUpdate).
+	Register(1, DecodeEnvelopeJSON[StatusUpdatePayload])
 
-func (c IiotStatusUpdateConsumer) Start(ctx context.Context) error {
-	if err := c.client.Ping(ctx); err != nil {
-		return fmt.Errorf("unable to ping kafka: %v", err)
-	}
+type IiotStatusUpdateConsumer = Consumer[StatusUpdatePayload, application_iot.InsertStatusUpdateCommand]
 
-	for {
-		select {
-		case <-ctx.Done():
-			return fmt.Errorf("shutting down iiot status update consumer")
-		default:
-			fmt.Printf("polling iiot status update consumer\n")
-			c.poll(ctx)
-		}
-	}
+func NewIiotStatusUpdateConsumer(handler *application_iot.InsertStatusUpdateCommandHandler, client *kgo.Client, opts ...ConsumerOption) (*IiotStatusUpdateConsumer, error) {
+	return NewConsumer("iiot status update", client, statusUpdatePayloads.Decode, validateStatusUpdate, mapStatusUpdate, handler, opts...), nil
 }
 
-func (c IiotStatusUpdateConsumer) poll(ctx context.Context) {
-	fetches := c.client.PollFetches(ctx)
-	if fetches.IsClientClosed() {
-		return
-	}
-
-	if fetches.Empty() {
-		return
-	}
-
-	fetches.EachError(func(_ string, _ int32, err error) {
-		panic(err)
+// validateStatusUpdate checks the payload fits the iot_status_events columns.
+// Devices report "unknown" as the old status of their first update.
+func validateStatusUpdate(statusUpdate StatusUpdatePayload) ValidationErrors {
+	var v fieldValidator
+	v.envelope(statusUpdate.Envelope)
+	v.enum("data.old_status", statusUpdate.Data.OldStatus, func(s string) error {
+		if s == "unknown" {
+			return nil
+		}
+		_, err := domain_iot_telemetry.MachineStatusFromString(s)
+		return err
 	})
+	v.enum("data.new_status", statusUpdate.Data.NewStatus, func(s string) error {
+		_, err := domain_iot_telemetry.MachineStatusFromString(s)
+		return err
+	})
+	v.text("data.reason", statusUpdate.Data.Reason, 50)
+	return v.errs
+}
 
-	r := fetches.Records()
-
-	commands := make([]application_iot.InsertStatusUpdateCommand, 0, len(r))
-	for _, rec := range r {
-		if rec.Topic == topics.StatusUpdateTopic {
-			var outer outerData
-			if err := json.Unmarshal(rec.Value, &outer); err != nil {
-				fmt.Printf("error unmarshaling outer JSON: %v\n", err)
-				continue
-			}
-
-			var statusUpdate StatusUpdatePayload
-			if err := json.Unmarshal([]byte(outer.Payload), &statusUpdate); err != nil {
-				fmt.Printf("error unmarshaling status update JSON: %v\n", err)
-				continue
-			}
-
-			commands = append(commands, application_iot.InsertStatusUpdateCommand{
-				Time:      time.Unix(statusUpdate.Timestamp, 0).UTC(),
-				DeviceID:  statusUpdate.DeviceID, // Replace with actual device ID from rec.Key or rec.Value
-				OldStatus: statusUpdate.Data.OldStatus,
-				NewStatus: statusUpdate.Data.NewStatus,
-				Reason:    statusUpdate.Data.Reason,
-			})
-		}
+func mapStatusUpdate(statusUpdate StatusUpdatePayload) application_iot.InsertStatusUpdateCommand {
+	return application_iot.InsertStatusUpdateCommand{
+		Time:      statusUpdate.Time(),
+		DeviceID:  statusUpdate.DeviceID,
+		OldStatus: statusUpdate.Data.OldStatus,
+		NewStatus: statusUpdate.Data.NewStatus,
+		Reason:    statusUpdate.Data.Reason,
+		Ingestion: statusUpdate.Ingestion(),
 	}
-	if err := c.handler.Handle(ctx, commands...); err != nil {
-		fmt.Printf("error handling insert status update command: %v\n", err)
-		return
-	}
-
-	c.client.MarkCommitRecords(r...)
 }
@@ -1,111 +1,70 @@
 package presentation_iot
 
 import (
-	"context"
-	"encoding/json"
-	"fmt"
-	"time"
-
 	application_iot "iiot_system/backend/internal/application/iot"
-	"iiot_system/backend/internal/infrastructure/topics"
+	domain_iot_telemetry "iiot_system/backend/internal/domain/iot/telemetry"
 
 	"github.com/aarondl/opt/null"
 	"github.com/shopspring/decimal"
 	"github.com/twmb/franz-go/pkg/kgo"
 )
 
-// AlertPayload represents the JSON structure of the `payload` field.
+// TelemetryPayload represents the JSON structure of the `payload` field.
 type TelemetryPayload struct {
-	Timestamp int64  `json:"timestamp"`
-	DeviceID  string `db:"device_id" `
-	Data      struct {
-		TemperatureCelcius decimal.Decimal  `json:"temperature_celcius" `
-		HumidityPercent    decimal.Decimal  `json:"humidity_percent" `
-		VibrationHZ        decimal.Decimal  `json:"vibration_hz" `
-		MotorRPM           int32            `json:"motor_rpm" `
-		CurrentAmps        decimal.Decimal  `json:"current_amps" `
+	Envelope
+	Data struct {
+		// Measurements are pointers so that a missing field is told apart
+		// from a zero reading.
+		TemperatureCelsius *decimal.Decimal `json:"temperature_celsius" `
+		HumidityPercent    *decimal.Decimal `json:"humidity_percent" `
+		VibrationHZ        *decimal.Decimal `json:"vibration_hz" `
+		MotorRPM           *int32           `json:"motor_rpm" `
+		CurrentAmps        *decimal.Decimal `json:"current_amps" `
 		MachineStatus      string           `json:"machine_status" `
 		ErrorCode          null.Val[string] `json:"error_code" `
 	} `json:"data"`
 }
 
-type IiotTelemetryConsumer struct {
-	client  *kgo.Client
-	handler *application_iot.InsertTelemetryCommandHandler
-}
-
-func NewIiotTelemetryConsumer(handler *application_iot.InsertTelemetryCommandHandler, client *kgo.Client) (*IiotTelemetryConsumer, error) {
-	c := &IiotTelemetryConsumer{
-		client:  client,
-		handler: handler,
-	}
-	return c, nil
-}
+var telemetryPayloads = NewPayloadRegistry[TelemetryPayload](PayloadTypeTelemetry).
+	Register(1, DecodeEnvelopeJSON[TelemetryPayload])
 
-func (c IiotTelemetryConsumer) Start(ctx context.Context) error {
-	if err := c.client.Ping(ctx); err != nil {
-		return fmt.Errorf("unable to ping kafka: %v", err)
-	}
+type IiotTelemetryConsumer = Consumer[TelemetryPayload, application_iot.InsertTelemetryCommand]
 
-	for {
-		select {
-		case <-ctx.Done():
-			return fmt.Errorf("shutting down iiot telemetry consumer")
-		default:
-			fmt.Printf("polling iiot telemetry consumer\n")
-			c.poll(ctx)
-		}
-	}
+func NewIiotTelemetryConsumer(handler *application_iot.InsertTelemetryCommandHandler, client *kgo.Client, opts ...ConsumerOption) (*IiotTelemetryConsumer, error) {
+	return NewConsumer("iiot telemetry", client, telemetryPayloads.Decode, validateTelemetry, mapTelemetry, handler, opts...), nil
 }
 
-func (c IiotTelemetryConsumer) poll(ctx context.Context) {
-	fetches := c.client.PollFetches(ctx)
-	if fetches.IsClientClosed() {
-		return
-	}
-
-	if fetches.Empty() {
-		return
+// validateTelemetry checks the payload fits the iot_telemetry_events columns.
+func validateTelemetry(telemetry TelemetryPayload) ValidationErrors {
+	var v fieldValidator
+	v.envelope(telemetry.Envelope)
+	v.numeric("data.temperature_celsius", telemetry.Data.TemperatureCelsius, 5, 2, -273.15, 999.99)
+	v.numeric("data.humidity_percent", telemetry.Data.HumidityPercent, 5, 2, 0, 100)
+	v.numeric("data.vibration_hz", telemetry.Data.VibrationHZ, 5, 2, 0, 999.99)
+	if v.required("data.motor_rpm", telemetry.Data.MotorRPM != nil) {
+		v.integer("data.motor_rpm", int64(*telemetry.Data.MotorRPM), 0, 100_000)
 	}
-
-	fetches.EachError(func(_ string, _ int32, err error) {
-		panic(err)
+	v.numeric("data.current_amps", telemetry.Data.CurrentAmps, 5, 2, 0, 999.99)
+	v.enum("data.machine_status", telemetry.Data.MachineStatus, func(s string) error {
+		_, err := domain_iot_telemetry.MachineStatusFromString(s)
+		return err
 	})
+	v.optionalText("data.error_code", telemetry.Data.ErrorCode.GetOrZero(), 50)
+	return v.errs
+}
 
-	r := fetches.Records()
-
-	commands := make([]application_iot.InsertTelemetryCommand, 0, len(r))
-	for _, rec := range r {
-		if rec.Topic == topics.TelemetryTopic {
-			var outer outerData
-			if err := json.Unmarshal(rec.Value, &outer); err != nil {
-				fmt.Printf("error unmarshaling outer JSON: %v\n", err)
-				continue
-			}
-
-			var telemetry TelemetryPayload
-			if err := json.Unmarshal([]byte(outer.Payload), &telemetry); err != nil {
-				fmt.Printf("error unmarshaling telemetry JSON: %v\n", err)
-				continue
-			}
-
-			commands = append(commands, application_iot.InsertTelemetryCommand{
-				Time:               time.Unix(telemetry.Timestamp, 0).UTC(),
-				DeviceID:           telemetry.DeviceID, // Replace with actual device ID from rec.Key or rec.Value
-				TemperatureCelcius: telemetry.Data.TemperatureCelcius,
-				HumidityPercent:    telemetry.Data.HumidityPercent,
-				VibrationHZ:        telemetry.Data.VibrationHZ,
-				MotorRPM:           telemetry.Data.MotorRPM,
-				CurrentAmps:        telemetry.Data.CurrentAmps,
-				MachineStatus:      telemetry.Data.MachineStatus,
-				ErrorCode:          telemetry.Data.ErrorCode,
-			})
-		}
+// mapTelemetry maps a validated payload, whose measurements are all present.
+func mapTelemetry(telemetry TelemetryPayload) application_iot.InsertTelemetryCommand {
+	return application_iot.InsertTelemetryCommand{
+		Time:               telemetry.Time(),
+		DeviceID:           telemetry.DeviceID,
+		TemperatureCelcius: *telemetry.Data.TemperatureCelsius,
+		HumidityPercent:    *telemetry.Data.HumidityPercent,
+		VibrationHZ:        *telemetry.Data.VibrationHZ,
+		MotorRPM:           *telemetry.Data.MotorRPM,
+		CurrentAmps:        *telemetry.Data.CurrentAmps,
+		MachineStatus:      telemetry.Data.MachineStatus,
+		ErrorCode:          telemetry.Data.ErrorCode,
+		Ingestion:          telemetry.Ingestion(),
 	}
-	if err := c.handler.Handle(ctx, commands...); err != nil {
-		fmt.Printf("error handling insert telemetry command: %v\n", err)
-		return
-	}
-
-	c.client.MarkCommitRecords(r...)
 }
@@ -0,0 +1,206 @@
+package presentation_iot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// TimeUnit is the unit of a numeric Unix timestamp.
+type TimeUnit string
+
+const (
+	// TimeUnitAuto detects the unit from the magnitude of the timestamp.
+	TimeUnitAuto         TimeUnit = ""
+	TimeUnitSeconds      TimeUnit = "s"
+	TimeUnitMilliseconds TimeUnit = "ms"
+	TimeUnitMicroseconds TimeUnit = "us"
+	TimeUnitNanoseconds  TimeUnit = "ns"
+)
+
+// ParseTimeUnit returns the unit named s, "auto" or "" for auto-detection.
+func ParseTimeUnit(s string) (TimeUnit, error) {
+	switch u := TimeUnit(s); u {
+	case TimeUnitSeconds, TimeUnitMilliseconds, TimeUnitMicroseconds, TimeUnitNanoseconds:
+		return u, nil
+	case TimeUnitAuto, "auto":
+		return TimeUnitAuto, nil
+	}
+	return TimeUnitAuto, fmt.Errorf("unknown time unit: %s", s)
+}
+
+// detectTimeUnit guesses the unit of a Unix timestamp from its magnitude.
+// Seconds stay below 1e11 until the year 5138, so every range is unambiguous
+// for any realistic date.
+func detectTimeUnit(epoch float64) TimeUnit {
+	switch abs := math.Abs(epoch); {
+	case abs < 1e11:
+		return TimeUnitSeconds
+	case abs < 1e14:
+		return TimeUnitMilliseconds
+	case abs < 1e17:
+		return TimeUnitMicroseconds
+	default:
+		return TimeUnitNanoseconds
+	}
+}
+
+// Timestamp is a point in time sent by a device, either as a Unix timestamp
+// in seconds, milliseconds, microseconds or nanoseconds - as a JSON number or
+// a numeric string - or as an RFC 3339 string.
+type Timestamp struct {
+	epoch  json.Number
+	parsed time.Time
+}
+
+func (t *Timestamp) UnmarshalJSON(b []byte) error {
+	*t = Timestamp{}
+	if bytes.Equal(b, []byte("null")) {
+		return nil
+	}
+
+	s := string(b)
+	if b[0] == '"' {
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		if s == "" {
+			return nil
+		}
+		if _, err := strconv.ParseFloat(s, 64); err != nil {
+			parsed, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				return fmt.Errorf("timestamp %q is neither a number nor RFC 3339", s)
+			}
+			t.parsed = parsed
+			return nil
+		}
+	}
+
+	if _, err := strconv.ParseFloat(s, 64); err != nil {
+		return fmt.Errorf("timestamp %s is neither a number nor RFC 3339", s)
+	}
+	t.epoch = json.Number(s)
+	return nil
+}
+
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	switch {
+	case t.epoch != "":
+		return []byte(t.epoch), nil
+	case !t.parsed.IsZero():
+		return json.Marshal(t.parsed.Format(time.RFC3339Nano))
+	default:
+		return []byte("null"), nil
+	}
+}
+
+// IsZero reports whether the timestamp was missing.
+func (t Timestamp) IsZero() bool {
+	return t.epoch == "" && t.parsed.IsZero()
+}
+
+// Time converts the timestamp, reading numeric timestamps in unit.
+func (t Timestamp) Time(unit TimeUnit) (time.Time, error) {
+	if t.epoch == "" {
+		return t.parsed, nil
+	}
+
+	f, err := t.epoch.Float64()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if unit == TimeUnitAuto {
+		unit = detectTimeUnit(f)
+	}
+
+	if n, err := t.epoch.Int64(); err == nil {
+		switch unit {
+		case TimeUnitSeconds:
+			return time.Unix(n, 0), nil
+		case TimeUnitMilliseconds:
+			return time.UnixMilli(n), nil
+		case TimeUnitMicroseconds:
+			return time.UnixMicro(n), nil
+		case TimeUnitNanoseconds:
+			return time.Unix(0, n), nil
+		}
+	}
+
+	var perUnit float64
+	switch unit {
+	case TimeUnitSeconds:
+		perUnit = float64(time.Second)
+	case TimeUnitMilliseconds:
+		perUnit = float64(time.Millisecond)
+	case TimeUnitMicroseconds:
+		perUnit = float64(time.Microsecond)
+	case TimeUnitNanoseconds:
+		perUnit = 1
+	default:
+		return time.Time{}, fmt.Errorf("unknown time unit: %s", unit)
+	}
+
+	ns := f * perUnit
+	if ns > math.MaxInt64 || ns < math.MinInt64 {
+		return time.Time{}, fmt.Errorf("timestamp %s is out of range", t.epoch)
+	}
+	return time.Unix(0, int64(ns)), nil
+}
+
+// TimestampPolicy controls how the time of an event is resolved from its
+// payload.
+type TimestampPolicy struct {
+	// Unit is the unit of numeric timestamps, auto-detected when empty.
+	// Messages can declare their own in the `timestamp_unit` field, as a
+	// unit or "auto".
+	Unit TimeUnit
+	// FallbackToRecordTime uses the broker receive time, or else the Kafka
+	// record timestamp, for messages without a usable timestamp instead of
+	// rejecting them.
+	FallbackToRecordTime bool
+	// MaxFuture and MaxPast reject events further than this from now.
+	// Zero disables the check.
+	MaxFuture time.Duration
+	MaxPast   time.Duration
+}
+
+// resolve sets the time of env, using recordTime as a fallback.
+func (p TimestampPolicy) resolve(env *Envelope, recordTime, now time.Time) *FieldError {
+	unit := p.Unit
+	if env.TimestampUnit != TimeUnitAuto {
+		// A declared "auto" detects the unit whatever the policy unit is.
+		declared, err := ParseTimeUnit(string(env.TimestampUnit))
+		if err != nil {
+			return &FieldError{Field: "timestamp_unit", Message: "must be one of s, ms, us, ns or auto"}
+		}
+		unit = declared
+	}
+
+	t, err := env.Timestamp.Time(unit)
+	if err != nil || t.IsZero() {
+		if received := env.ingestion.BrokerReceivedAt; !received.IsZero() {
+			recordTime = received
+		}
+		if !p.FallbackToRecordTime || recordTime.IsZero() {
+			if err == nil {
+				err = fmt.Errorf("is required")
+			}
+			return &FieldError{Field: "timestamp", Message: err.Error()}
+		}
+		t = recordTime
+	}
+
+	if p.MaxFuture > 0 && t.After(now.Add(p.MaxFuture)) {
+		return &FieldError{Field: "timestamp", Message: fmt.Sprintf("%s is more than %s in the future", t.UTC().Format(time.RFC3339Nano), p.MaxFuture)}
+	}
+	if p.MaxPast > 0 && t.Before(now.Add(-p.MaxPast)) {
+		return &FieldError{Field: "timestamp", Message: fmt.Sprintf("%s is more than %s in the past", t.UTC().Format(time.RFC3339Nano), p.MaxPast)}
+	}
+
+	env.time = t.UTC()
+	return nil
+}
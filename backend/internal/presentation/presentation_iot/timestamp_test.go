@@ -0,0 +1,51 @@
+package presentation_iot
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestResolveTimestampUnit(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	seconds := now.Unix()
+
+	tests := []struct {
+		name      string
+		policy    TimeUnit
+		timestamp int64
+		unit      TimeUnit
+		want      time.Time
+		wantErr   string
+	}{
+		{name: "policy unit", policy: TimeUnitSeconds, timestamp: seconds, want: now},
+		{name: "detected", timestamp: seconds * 1000, want: now},
+		{name: "declared unit", policy: TimeUnitSeconds, timestamp: seconds * 1000, unit: TimeUnitMilliseconds, want: now},
+		{name: "declared auto", policy: TimeUnitSeconds, timestamp: seconds * 1000, unit: "auto", want: now},
+		{name: "declared auto microseconds", policy: TimeUnitMilliseconds, timestamp: seconds * 1e6, unit: "auto", want: now},
+		{name: "unknown unit", timestamp: seconds, unit: "sec", wantErr: "timestamp_unit: must be one of s, ms, us, ns or auto"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var env Envelope
+			if err := json.Unmarshal(fmt.Appendf(nil, `{"timestamp": %d, "timestamp_unit": %q}`, tt.timestamp, tt.unit), &env); err != nil {
+				t.Fatal(err)
+			}
+
+			err := TimestampPolicy{Unit: tt.policy}.resolve(&env, time.Time{}, now)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !env.Time().Equal(tt.want) {
+				t.Errorf("time %s, want %s", env.Time(), tt.want)
+			}
+		})
+	}
+}
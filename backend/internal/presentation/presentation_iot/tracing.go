@@ -0,0 +1,111 @@
+package presentation_iot
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxBatchLinks bounds the records a batch span links to.
+const maxBatchLinks = 128
+
+var tracer = otel.Tracer("iiot_system/backend/internal/presentation/presentation_iot")
+
+// recordCarrier reads and writes the trace context propagated in the
+// headers of a Kafka record.
+type recordCarrier struct {
+	rec *kgo.Record
+}
+
+func (c recordCarrier) Get(key string) string {
+	for _, h := range c.rec.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c recordCarrier) Set(key, value string) {
+	for i, h := range c.rec.Headers {
+		if h.Key == key {
+			c.rec.Headers[i].Value = []byte(value)
+			return
+		}
+	}
+	c.rec.Headers = append(c.rec.Headers, kgo.RecordHeader{Key: key, Value: []byte(value)})
+}
+
+func (c recordCarrier) Keys() []string {
+	keys := make([]string, len(c.rec.Headers))
+	for i, h := range c.rec.Headers {
+		keys[i] = h.Key
+	}
+	return keys
+}
+
+// recordContext returns ctx carrying the trace context propagated in the
+// headers of rec, if any.
+func recordContext(ctx context.Context, rec *kgo.Record) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, recordCarrier{rec})
+}
+
+// startRecordSpan starts the span of a stage processing rec.
+func startRecordSpan(ctx context.Context, name string, rec *kgo.Record) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(
+		semconv.MessagingSystemKafka,
+		semconv.MessagingDestinationName(rec.Topic),
+		semconv.MessagingDestinationPartitionID(strconv.Itoa(int(rec.Partition))),
+		semconv.MessagingKafkaOffset(int(rec.Offset)),
+	))
+}
+
+// startBatchSpan starts the span handing a batch of topic to the sink. A
+// batch of records traced upstream by a single span continues its trace,
+// any other batch starts a root span linking to the traced records.
+func startBatchSpan(ctx context.Context, name, topic string, size int, links []trace.Link) (context.Context, trace.Span) {
+	opts := []trace.SpanStartOption{
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingOperationTypeProcess,
+			semconv.MessagingDestinationName(topic),
+			semconv.MessagingBatchMessageCount(size),
+		),
+	}
+	if len(links) == 1 {
+		ctx = trace.ContextWithRemoteSpanContext(ctx, links[0].SpanContext)
+	} else {
+		opts = append(opts, trace.WithNewRoot(), trace.WithLinks(links...))
+	}
+	return tracer.Start(ctx, name, opts...)
+}
+
+// link adds the trace context of ctx to links, unless it has none or links
+// are full.
+func link(links []trace.Link, ctx context.Context) []trace.Link {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() || len(links) >= maxBatchLinks {
+		return links
+	}
+	for _, l := range links {
+		if l.SpanContext.Equal(sc) {
+			return links
+		}
+	}
+	return append(links, trace.Link{SpanContext: sc})
+}
+
+// endSpan ends span, recording err if not nil.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,64 @@
+package presentation_iot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestConsumerTracesRecords(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	traced := kgo.StringRecord("traced")
+	traced.Headers = []kgo.RecordHeader{{Key: "traceparent", Value: []byte("00-" + traceID + "-00f067aa0ba902b7-01")}}
+
+	sink := newTestSink()
+	consumer, _, _ := newTestConsumerOf(t, []*kgo.Record{traced, kgo.StringRecord("untraced")}, sink,
+		WithBatching(BatchOptions{MaxRows: 2, MaxWait: time.Hour}),
+	)
+	cancel, errs := startConsumer(consumer)
+	defer cancel()
+	waitFor(t, "records to be stored", func() bool { return sink.stored() == 2 })
+	cancel()
+	if err := waitStopped(t, errs); err != nil {
+		t.Fatalf("Start() = %v, want nil", err)
+	}
+
+	byName := make(map[string][]sdktrace.ReadOnlySpan)
+	for _, span := range spans.Ended() {
+		byName[span.Name()] = append(byName[span.Name()], span)
+	}
+	if got := len(byName["test decode"]); got != 2 {
+		t.Errorf("recorded %d decode spans, want 2", got)
+	}
+	if got := len(byName["test validate"]); got != 2 {
+		t.Errorf("recorded %d validate spans, want 2", got)
+	}
+
+	var continued int
+	for _, span := range byName["test decode"] {
+		if span.SpanContext().TraceID().String() == traceID {
+			continued++
+		}
+	}
+	if continued != 1 {
+		t.Errorf("%d decode spans continue the propagated trace, want 1", continued)
+	}
+
+	process := byName["test process"]
+	if len(process) != 1 {
+		t.Fatalf("recorded %d process spans, want 1", len(process))
+	}
+	// The batch only holds one traced record, so it continues its trace.
+	if got := process[0].SpanContext().TraceID().String(); got != traceID {
+		t.Errorf("process span trace = %s, want %s", got, traceID)
+	}
+}
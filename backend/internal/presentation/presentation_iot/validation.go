@@ -0,0 +1,191 @@
+package presentation_iot
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/shopspring/decimal"
+)
+
+// FieldError describes why a single payload field was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidationErrors lists every field a payload was rejected for.
+type ValidationErrors []FieldError
+
+func (v ValidationErrors) Error() string {
+	msgs := make([]string, len(v))
+	for i, e := range v {
+		msgs[i] = e.Error()
+	}
+	return "invalid payload: " + strings.Join(msgs, "; ")
+}
+
+// Validator checks a decoded payload before commands are built from it.
+type Validator[P any] func(payload P) ValidationErrors
+
+// fieldValidator accumulates the field errors of a payload.
+type fieldValidator struct {
+	errs ValidationErrors
+}
+
+func (v *fieldValidator) fail(field, format string, args ...any) {
+	v.errs = append(v.errs, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// text checks a required string stored in a VARCHAR(maxLen) column.
+func (v *fieldValidator) text(field, value string, maxLen int) {
+	if value == "" {
+		v.fail(field, "is required")
+		return
+	}
+	v.optionalText(field, value, maxLen)
+}
+
+// optionalText checks an optional string stored in a VARCHAR(maxLen) column,
+// whose length counts characters rather than bytes.
+func (v *fieldValidator) optionalText(field, value string, maxLen int) {
+	if n := utf8.RuneCountInString(value); n > maxLen {
+		v.fail(field, "must be at most %d characters, got %d", maxLen, n)
+	}
+}
+
+// enum checks value against the known values through parse.
+func (v *fieldValidator) enum(field, value string, parse func(string) error) {
+	if value == "" {
+		v.fail(field, "is required")
+		return
+	}
+	if err := parse(value); err != nil {
+		v.fail(field, "%v", err)
+	}
+}
+
+// required checks a field without a zero value of its own was present in
+// the payload, reporting whether it was.
+func (v *fieldValidator) required(field string, present bool) bool {
+	if !present {
+		v.fail(field, "is required")
+	}
+	return present
+}
+
+// numeric checks a required value fits a NUMERIC(precision, scale) column and
+// lies in [minValue, maxValue]. A missing value is nil rather than zero, which
+// would fit most columns.
+func (v *fieldValidator) numeric(field string, value *decimal.Decimal, precision, scale int32, minValue, maxValue float64) {
+	if v.required(field, value != nil) {
+		v.optionalNumeric(field, value, precision, scale, minValue, maxValue)
+	}
+}
+
+// optionalNumeric checks an optional value fits a NUMERIC(precision, scale)
+// column and lies in [minValue, maxValue].
+func (v *fieldValidator) optionalNumeric(field string, p *decimal.Decimal, precision, scale int32, minValue, maxValue float64) {
+	if p == nil {
+		return
+	}
+	value := *p
+
+	limit := decimal.New(1, precision-scale)
+	if value.Round(scale).Abs().GreaterThanOrEqual(limit) {
+		v.fail(field, "must fit NUMERIC(%d,%d), got %s", precision, scale, value)
+		return
+	}
+	if value.LessThan(decimal.NewFromFloat(minValue)) || value.GreaterThan(decimal.NewFromFloat(maxValue)) {
+		v.fail(field, "must be between %v and %v, got %s", minValue, maxValue, value)
+	}
+}
+
+// integer checks value lies in [minValue, maxValue].
+func (v *fieldValidator) integer(field string, value, minValue, maxValue int64) {
+	if value < minValue || value > maxValue {
+		v.fail(field, "must be between %d and %d, got %d", minValue, maxValue, value)
+	}
+}
+
+func (v *fieldValidator) envelope(env Envelope) {
+	v.text("device_id", env.DeviceID, 50)
+}
+
+var jsonUnmarshalerType = reflect.TypeFor[json.Unmarshaler]()
+
+// unknownFields returns the fields of the JSON object raw that do not map to
+// a field of t, as dotted paths prefixed with prefix.
+func unknownFields(raw []byte, t reflect.Type, prefix string) ValidationErrors {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil
+	}
+
+	fields := jsonFields(t)
+	keys := slices.Sorted(func(yield func(string) bool) {
+		for k := range obj {
+			if !yield(k) {
+				return
+			}
+		}
+	})
+
+	var errs ValidationErrors
+	for _, key := range keys {
+		ft, ok := fields[key]
+		if !ok {
+			errs = append(errs, FieldError{Field: prefix + key, Message: "is not a known field"})
+			continue
+		}
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct && !reflect.PointerTo(ft).Implements(jsonUnmarshalerType) {
+			errs = append(errs, unknownFields(obj[key], ft, prefix+key+".")...)
+		}
+	}
+	return errs
+}
+
+// jsonFields maps the JSON names of the fields of struct t to their types,
+// following encoding/json rules for embedded structs: shallower fields win.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type, t.NumField())
+	var embedded []reflect.Type
+
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			embedded = append(embedded, f.Type)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f.Type
+	}
+
+	for _, et := range embedded {
+		for name, ft := range jsonFields(et) {
+			if _, ok := fields[name]; !ok {
+				fields[name] = ft
+			}
+		}
+	}
+	return fields
+}
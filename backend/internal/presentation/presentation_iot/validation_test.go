@@ -0,0 +1,66 @@
+package presentation_iot
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestValidateTelemetryRequiresMeasurements(t *testing.T) {
+	var payload TelemetryPayload
+	// temperature_celsius is misspelled and motor_rpm is missing.
+	raw := `{"device_id": "press-1", "data": {"temperature_celcius": 21.5, "humidity_percent": 0,
+		"vibration_hz": 12.5, "current_amps": 3.2, "machine_status": "running"}}`
+	if err := json.Unmarshal([]byte(raw), &payload); err != nil {
+		t.Fatal(err)
+	}
+
+	var fields []string
+	for _, e := range validateTelemetry(payload) {
+		if e.Message != "is required" {
+			t.Errorf("unexpected error %s", e)
+		}
+		fields = append(fields, e.Field)
+	}
+	if want := []string{"data.temperature_celsius", "data.motor_rpm"}; !slices.Equal(fields, want) {
+		t.Errorf("required fields %v, want %v", fields, want)
+	}
+}
+
+func TestMapAlertWithoutCurrentValue(t *testing.T) {
+	var payload AlertPayload
+	raw := `{"device_id": "press-1", "data": {"alert_type": "SENSOR_OFFLINE", "severity": "LOW", "message": "offline"}}`
+	if err := json.Unmarshal([]byte(raw), &payload); err != nil {
+		t.Fatal(err)
+	}
+
+	if errs := validateAlert(payload); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if cmd := mapAlert(payload); cmd.CurrentValue.IsValue() {
+		t.Errorf("current value %v, want null", cmd.CurrentValue)
+	}
+}
+
+func TestTextCountsCharacters(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		value string
+		errs  int
+	}{
+		{"ascii", strings.Repeat("a", 10), 0},
+		{"multi-byte at the limit", strings.Repeat("é", 10), 0},
+		{"wide characters at the limit", strings.Repeat("機", 10), 0},
+		{"over the limit", strings.Repeat("機", 11), 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var v fieldValidator
+			v.text("device_id", tt.value, 10)
+			v.optionalText("data.error_code", tt.value, 10)
+			if got := len(v.errs); got != 2*tt.errs {
+				t.Errorf("%d errors for %d bytes of %d characters: %v", got, len(tt.value), len([]rune(tt.value)), v.errs)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package presentation_metrics
+
+import (
+	"strconv"
+
+	"iiot_system/backend/internal/presentation/presentation_iot"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// LagReporter is a consumer whose lag per partition is exported.
+type LagReporter interface {
+	Status() presentation_iot.ConsumerStatus
+}
+
+// lagCollector reads the lag of the consumers when scraped.
+type lagCollector struct {
+	consumers []LagReporter
+	lag       *prometheus.Desc
+}
+
+func newLagCollector(consumers []LagReporter) *lagCollector {
+	return &lagCollector{
+		consumers: consumers,
+		lag: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "consumer_lag_records"),
+			"Records of a partition not stored yet, as of the last fetch, by consumer, topic and partition.",
+			[]string{"consumer", "topic", "partition"}, nil,
+		),
+	}
+}
+
+func (c *lagCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.lag
+}
+
+func (c *lagCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, consumer := range c.consumers {
+		status := consumer.Status()
+		for _, p := range status.Partitions {
+			if p.Offset < 0 {
+				continue
+			}
+			ch <- prometheus.MustNewConstMetric(c.lag, prometheus.GaugeValue, float64(p.Lag),
+				status.Name, p.Topic, strconv.Itoa(int(p.Partition)))
+		}
+	}
+}
+
+// poolCollector reads the stats of a pgxpool when scraped.
+type poolCollector struct {
+	pool *pgxpool.Pool
+
+	acquiredConns        *prometheus.Desc
+	idleConns            *prometheus.Desc
+	constructingConns    *prometheus.Desc
+	totalConns           *prometheus.Desc
+	maxConns             *prometheus.Desc
+	acquires             *prometheus.Desc
+	acquireDuration      *prometheus.Desc
+	emptyAcquires        *prometheus.Desc
+	canceledAcquires     *prometheus.Desc
+	newConns             *prometheus.Desc
+	maxLifetimeDestroyed *prometheus.Desc
+	maxIdleDestroyed     *prometheus.Desc
+}
+
+func newPoolCollector(pool *pgxpool.Pool) *poolCollector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", name), help, nil, nil)
+	}
+	return &poolCollector{
+		pool:                 pool,
+		acquiredConns:        desc("acquired_conns", "Connections currently in use."),
+		idleConns:            desc("idle_conns", "Connections currently idle."),
+		constructingConns:    desc("constructing_conns", "Connections currently being opened."),
+		totalConns:           desc("total_conns", "Connections currently open or being opened."),
+		maxConns:             desc("max_conns", "Maximum size of the pool."),
+		acquires:             desc("acquires_total", "Connections acquired from the pool."),
+		acquireDuration:      desc("acquire_duration_seconds_total", "Time spent acquiring connections."),
+		emptyAcquires:        desc("empty_acquires_total", "Acquires that waited for a connection because none was idle."),
+		canceledAcquires:     desc("canceled_acquires_total", "Acquires canceled before getting a connection."),
+		newConns:             desc("new_conns_total", "Connections opened."),
+		maxLifetimeDestroyed: desc("max_lifetime_destroyed_total", "Connections closed for exceeding their maximum lifetime."),
+		maxIdleDestroyed:     desc("max_idle_destroyed_total", "Connections closed for exceeding their maximum idle time."),
+	}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.pool.Stat()
+	gauge := func(desc *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v)
+	}
+	counter := func(desc *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, v)
+	}
+
+	gauge(c.acquiredConns, float64(s.AcquiredConns()))
+	gauge(c.idleConns, float64(s.IdleConns()))
+	gauge(c.constructingConns, float64(s.ConstructingConns()))
+	gauge(c.totalConns, float64(s.TotalConns()))
+	gauge(c.maxConns, float64(s.MaxConns()))
+	counter(c.acquires, float64(s.AcquireCount()))
+	counter(c.acquireDuration, s.AcquireDuration().Seconds())
+	counter(c.emptyAcquires, float64(s.EmptyAcquireCount()))
+	counter(c.canceledAcquires, float64(s.CanceledAcquireCount()))
+	counter(c.newConns, float64(s.NewConnsCount()))
+	counter(c.maxLifetimeDestroyed, float64(s.MaxLifetimeDestroyCount()))
+	counter(c.maxIdleDestroyed, float64(s.MaxIdleDestroyCount()))
+}
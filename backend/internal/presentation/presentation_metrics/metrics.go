@@ -0,0 +1,207 @@
+package presentation_metrics
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"iiot_system/backend/internal/application/iot"
+	"iiot_system/backend/internal/presentation/presentation_iot"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "iiot"
+
+// Metrics collects the ingestion pipeline metrics served on /metrics. It
+// implements presentation_iot.ConsumerMetrics and
+// application_iot.InsertObserver.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	recordsConsumed *prometheus.CounterVec
+	recordsRejected *prometheus.CounterVec
+	recordsSpoofed  *prometheus.CounterVec
+	brokerLatency   *prometheus.HistogramVec
+	batchSize       *prometheus.HistogramVec
+	rowsInserted    *prometheus.CounterVec
+	rowsDuplicate   *prometheus.CounterVec
+	rowsRejected    *prometheus.CounterVec
+	insertErrors    *prometheus.CounterVec
+	insertLatency   *prometheus.HistogramVec
+	httpRequests    *prometheus.CounterVec
+	httpLatency     *prometheus.HistogramVec
+}
+
+var (
+	_ presentation_iot.ConsumerMetrics = (*Metrics)(nil)
+	_ application_iot.InsertObserver   = (*Metrics)(nil)
+)
+
+// New returns Metrics registering the Go runtime and process metrics and the
+// stats of pool along with its own.
+func New(pool *pgxpool.Pool) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		recordsConsumed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "records_consumed_total",
+			Help:      "Kafka records fetched, by topic.",
+		}, []string{"topic"}),
+		recordsRejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "records_rejected_total",
+			Help:      "Kafka records that failed to decode or validate, by topic and stage.",
+		}, []string{"topic", "stage"}),
+		recordsSpoofed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "records_spoofed_total",
+			Help:      "Kafka records whose device id does not match their MQTT client id, by topic.",
+		}, []string{"topic"}),
+		brokerLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "broker_latency_seconds",
+			Help:      "Time from the device timestamp of a record to its receipt by the MQTT broker, by topic.",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+		}, []string{"topic"}),
+		batchSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "batch_size_records",
+			Help:      "Records per batch handed to a command handler, by topic.",
+			Buckets:   prometheus.ExponentialBuckets(1, 4, 8),
+		}, []string{"topic"}),
+		rowsInserted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rows_inserted_total",
+			Help:      "Rows stored, by table.",
+		}, []string{"table"}),
+		rowsDuplicate: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rows_duplicate_total",
+			Help:      "Rows skipped because already stored, by table.",
+		}, []string{"table"}),
+		rowsRejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rows_rejected_total",
+			Help:      "Rows permanently refused by the database, by table.",
+		}, []string{"table"}),
+		insertErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "insert_errors_total",
+			Help:      "Batches that failed to be stored after retrying, by table.",
+		}, []string{"table"}),
+		insertLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "insert_duration_seconds",
+			Help:      "Time to store a batch, retries included, by table.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 15),
+		}, []string{"table"}),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests served, by method, route and status code.",
+		}, []string{"method", "route", "code"}),
+		httpLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time to serve an HTTP request, by method and route.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.recordsConsumed,
+		m.recordsRejected,
+		m.recordsSpoofed,
+		m.brokerLatency,
+		m.batchSize,
+		m.rowsInserted,
+		m.rowsDuplicate,
+		m.rowsRejected,
+		m.insertErrors,
+		m.insertLatency,
+		m.httpRequests,
+		m.httpLatency,
+	)
+	if pool != nil {
+		m.registry.MustRegister(newPoolCollector(pool))
+	}
+	return m
+}
+
+// TrackLag exports the lag of consumers per partition.
+func (m *Metrics) TrackLag(consumers ...LagReporter) {
+	m.registry.MustRegister(newLagCollector(consumers))
+}
+
+// Register adds the /metrics route to e and measures every request it serves.
+func (m *Metrics) Register(e *echo.Echo) {
+	e.Use(m.middleware)
+	e.GET("/metrics", echo.WrapHandler(promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})))
+}
+
+func (m *Metrics) RecordsConsumed(topic string, n int) {
+	m.recordsConsumed.WithLabelValues(topic).Add(float64(n))
+}
+
+func (m *Metrics) RecordRejected(topic string, stage string) {
+	m.recordsRejected.WithLabelValues(topic, stage).Inc()
+}
+
+func (m *Metrics) RecordSpoofed(topic string) {
+	m.recordsSpoofed.WithLabelValues(topic).Inc()
+}
+
+// BrokerLatency observes latency, counting device clocks running ahead of the
+// broker as no latency.
+func (m *Metrics) BrokerLatency(topic string, latency time.Duration) {
+	m.brokerLatency.WithLabelValues(topic).Observe(max(latency, 0).Seconds())
+}
+
+func (m *Metrics) BatchFlushed(topic string, size int) {
+	m.batchSize.WithLabelValues(topic).Observe(float64(size))
+}
+
+func (m *Metrics) ObserveInsert(table string, result application_iot.InsertResult, duration time.Duration, err error) {
+	m.insertLatency.WithLabelValues(table).Observe(duration.Seconds())
+	if err != nil {
+		m.insertErrors.WithLabelValues(table).Inc()
+		return
+	}
+	m.rowsInserted.WithLabelValues(table).Add(float64(result.Inserted))
+	m.rowsDuplicate.WithLabelValues(table).Add(float64(result.Duplicates))
+	m.rowsRejected.WithLabelValues(table).Add(float64(len(result.Rejected)))
+}
+
+// middleware measures requests by route template rather than path, so device
+// ids do not create a series each.
+func (m *Metrics) middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		started := time.Now()
+		err := next(c)
+
+		code := c.Response().Status
+		var httpErr *echo.HTTPError
+		if errors.As(err, &httpErr) {
+			code = httpErr.Code
+		} else if err != nil {
+			code = http.StatusInternalServerError
+		}
+
+		route := c.Path()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := c.Request().Method
+		m.httpRequests.WithLabelValues(method, route, strconv.Itoa(code)).Inc()
+		m.httpLatency.WithLabelValues(method, route).Observe(time.Since(started).Seconds())
+		return err
+	}
+}
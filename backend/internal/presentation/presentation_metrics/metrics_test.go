@@ -0,0 +1,78 @@
+package presentation_metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"iiot_system/backend/internal/application/iot"
+	"iiot_system/backend/internal/presentation/presentation_iot"
+
+	"github.com/labstack/echo/v4"
+)
+
+type fakeConsumer struct {
+	status presentation_iot.ConsumerStatus
+}
+
+func (f fakeConsumer) Status() presentation_iot.ConsumerStatus {
+	return f.status
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	m := New(nil)
+	m.TrackLag(fakeConsumer{presentation_iot.ConsumerStatus{
+		Name: "iiot telemetry",
+		Partitions: []presentation_iot.PartitionStatus{
+			{Topic: "oee.telemetry", Partition: 2, HighWatermark: 50, Offset: 20, Lag: 30},
+			{Topic: "oee.telemetry", Partition: 3, HighWatermark: 50, Offset: -1},
+		},
+	}})
+
+	e := echo.New()
+	m.Register(e)
+	e.GET("/api/v1/devices/:id", func(c echo.Context) error {
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	m.RecordsConsumed("oee.telemetry", 3)
+	m.RecordRejected("oee.telemetry", "decode")
+	m.RecordSpoofed("oee.telemetry")
+	m.BrokerLatency("oee.telemetry", 30*time.Millisecond)
+	m.BatchFlushed("oee.telemetry", 3)
+	m.ObserveInsert("iot_telemetry_events", application_iot.InsertResult{
+		Inserted:   2,
+		Duplicates: 1,
+	}, 20*time.Millisecond, nil)
+
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/devices/press-01", nil))
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /metrics = %d", rec.Code)
+	}
+
+	body := rec.Body.String()
+	for _, want := range []string{
+		`iiot_records_consumed_total{topic="oee.telemetry"} 3`,
+		`iiot_records_rejected_total{stage="decode",topic="oee.telemetry"} 1`,
+		`iiot_records_spoofed_total{topic="oee.telemetry"} 1`,
+		`iiot_broker_latency_seconds_bucket{topic="oee.telemetry",le="0.04"} 1`,
+		`iiot_batch_size_records_count{topic="oee.telemetry"} 1`,
+		`iiot_rows_inserted_total{table="iot_telemetry_events"} 2`,
+		`iiot_rows_duplicate_total{table="iot_telemetry_events"} 1`,
+		`iiot_insert_duration_seconds_count{table="iot_telemetry_events"} 1`,
+		`iiot_consumer_lag_records{consumer="iiot telemetry",partition="2",topic="oee.telemetry"} 30`,
+		`iiot_http_requests_total{code="204",method="GET",route="/api/v1/devices/:id"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("GET /metrics is missing %s", want)
+		}
+	}
+	if strings.Contains(body, `partition="3"`) {
+		t.Error("GET /metrics reports the lag of a partition without offset")
+	}
+}
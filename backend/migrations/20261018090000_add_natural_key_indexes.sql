@@ -0,0 +1,47 @@
+-- migrate:up
+-- Remove the duplicates ingested before the natural keys were enforced,
+-- keeping one row per key.
+DELETE FROM iot_telemetry_events a USING iot_telemetry_events b
+WHERE
+    a.ctid < b.ctid
+    AND a.device_id = b.device_id
+    AND a.time = b.time;
+
+DELETE FROM iot_production_events a USING iot_production_events b
+WHERE
+    a.ctid < b.ctid
+    AND a.device_id = b.device_id
+    AND a.time = b.time
+    AND a.batch_id = b.batch_id
+    AND a.product_sku = b.product_sku;
+
+DELETE FROM iot_alert_events a USING iot_alert_events b
+WHERE
+    a.ctid < b.ctid
+    AND a.device_id = b.device_id
+    AND a.time = b.time
+    AND a.alert_type = b.alert_type;
+
+DELETE FROM iot_status_events a USING iot_status_events b
+WHERE
+    a.ctid < b.ctid
+    AND a.device_id = b.device_id
+    AND a.time = b.time
+    AND a.new_status = b.new_status;
+
+CREATE UNIQUE INDEX IF NOT EXISTS iot_telemetry_events_natural_key ON iot_telemetry_events (device_id, time);
+
+CREATE UNIQUE INDEX IF NOT EXISTS iot_production_events_natural_key ON iot_production_events (device_id, time, batch_id, product_sku);
+
+CREATE UNIQUE INDEX IF NOT EXISTS iot_alert_events_natural_key ON iot_alert_events (device_id, time, alert_type);
+
+CREATE UNIQUE INDEX IF NOT EXISTS iot_status_events_natural_key ON iot_status_events (device_id, time, new_status);
+
+-- migrate:down
+DROP INDEX IF EXISTS iot_telemetry_events_natural_key;
+
+DROP INDEX IF EXISTS iot_production_events_natural_key;
+
+DROP INDEX IF EXISTS iot_alert_events_natural_key;
+
+DROP INDEX IF EXISTS iot_status_events_natural_key;
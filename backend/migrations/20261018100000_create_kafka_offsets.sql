@@ -0,0 +1,13 @@
+-- migrate:up
+CREATE TABLE
+    IF NOT EXISTS kafka_offsets (
+        consumer_group VARCHAR(255) NOT NULL,
+        topic VARCHAR(255) NOT NULL,
+        partition INTEGER NOT NULL,
+        next_offset BIGINT NOT NULL,
+        updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+        PRIMARY KEY (consumer_group, topic, partition)
+    );
+
+-- migrate:down
+DROP TABLE IF EXISTS kafka_offsets;
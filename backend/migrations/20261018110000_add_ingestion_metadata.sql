@@ -0,0 +1,57 @@
+-- migrate:up
+ALTER TABLE iot_telemetry_events
+ADD COLUMN IF NOT EXISTS mqtt_topic VARCHAR(255),
+ADD COLUMN IF NOT EXISTS mqtt_client_id VARCHAR(255),
+ADD COLUMN IF NOT EXISTS broker_received_at TIMESTAMPTZ,
+ADD COLUMN IF NOT EXISTS kafka_partition INTEGER,
+ADD COLUMN IF NOT EXISTS kafka_offset BIGINT;
+
+ALTER TABLE iot_production_events
+ADD COLUMN IF NOT EXISTS mqtt_topic VARCHAR(255),
+ADD COLUMN IF NOT EXISTS mqtt_client_id VARCHAR(255),
+ADD COLUMN IF NOT EXISTS broker_received_at TIMESTAMPTZ,
+ADD COLUMN IF NOT EXISTS kafka_partition INTEGER,
+ADD COLUMN IF NOT EXISTS kafka_offset BIGINT;
+
+ALTER TABLE iot_alert_events
+ADD COLUMN IF NOT EXISTS mqtt_topic VARCHAR(255),
+ADD COLUMN IF NOT EXISTS mqtt_client_id VARCHAR(255),
+ADD COLUMN IF NOT EXISTS broker_received_at TIMESTAMPTZ,
+ADD COLUMN IF NOT EXISTS kafka_partition INTEGER,
+ADD COLUMN IF NOT EXISTS kafka_offset BIGINT;
+
+ALTER TABLE iot_status_events
+ADD COLUMN IF NOT EXISTS mqtt_topic VARCHAR(255),
+ADD COLUMN IF NOT EXISTS mqtt_client_id VARCHAR(255),
+ADD COLUMN IF NOT EXISTS broker_received_at TIMESTAMPTZ,
+ADD COLUMN IF NOT EXISTS kafka_partition INTEGER,
+ADD COLUMN IF NOT EXISTS kafka_offset BIGINT;
+
+-- migrate:down
+ALTER TABLE iot_telemetry_events
+DROP COLUMN IF EXISTS mqtt_topic,
+DROP COLUMN IF EXISTS mqtt_client_id,
+DROP COLUMN IF EXISTS broker_received_at,
+DROP COLUMN IF EXISTS kafka_partition,
+DROP COLUMN IF EXISTS kafka_offset;
+
+ALTER TABLE iot_production_events
+DROP COLUMN IF EXISTS mqtt_topic,
+DROP COLUMN IF EXISTS mqtt_client_id,
+DROP COLUMN IF EXISTS broker_received_at,
+DROP COLUMN IF EXISTS kafka_partition,
+DROP COLUMN IF EXISTS kafka_offset;
+
+ALTER TABLE iot_alert_events
+DROP COLUMN IF EXISTS mqtt_topic,
+DROP COLUMN IF EXISTS mqtt_client_id,
+DROP COLUMN IF EXISTS broker_received_at,
+DROP COLUMN IF EXISTS kafka_partition,
+DROP COLUMN IF EXISTS kafka_offset;
+
+ALTER TABLE iot_status_events
+DROP COLUMN IF EXISTS mqtt_topic,
+DROP COLUMN IF EXISTS mqtt_client_id,
+DROP COLUMN IF EXISTS broker_received_at,
+DROP COLUMN IF EXISTS kafka_partition,
+DROP COLUMN IF EXISTS kafka_offset;
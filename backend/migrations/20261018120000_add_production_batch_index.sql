@@ -0,0 +1,6 @@
+-- migrate:up
+-- Looks up the events of a production batch without scanning every chunk.
+CREATE INDEX IF NOT EXISTS iot_production_events_batch ON iot_production_events (batch_id, time DESC);
+
+-- migrate:down
+DROP INDEX IF EXISTS iot_production_events_batch;